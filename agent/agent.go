@@ -0,0 +1,340 @@
+// Package agent keeps unlocked secrets in the memory of a background process
+// for a while, so denv doesn't ask for them on every command
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// idleTimeout stops an agent started with ExitWhenIdle once nothing was stored
+// in it, or all its secrets expired, for that long
+const idleTimeout = 10 * time.Second
+
+// currentUID is the only user the agent answers to
+var currentUID = os.Getuid
+
+// Options shape how the agent keeps secrets
+type Options struct {
+	// TTL is how long secrets stored without their own ttl are kept
+	TTL time.Duration
+	// ExitWhenIdle stops the agent when it holds nothing anymore
+	ExitWhenIdle bool
+}
+
+// Entry is a secret held by the agent, without its value
+type Entry struct {
+	Name    string    `json:"name"`
+	Expires time.Time `json:"expires"`
+}
+
+// request is sent by the CLI, one per connection
+type request struct {
+	Op    string `json:"op"`
+	Name  string `json:"name,omitempty"`
+	Value string `json:"value,omitempty"`
+	// TTL is how many seconds the value is kept, zero uses the agent TTL
+	TTL int64 `json:"ttl,omitempty"`
+}
+
+type response struct {
+	Value   string  `json:"value,omitempty"`
+	Found   bool    `json:"found,omitempty"`
+	Entries []Entry `json:"entries,omitempty"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// secret is a value held by the agent until it expires
+type secret struct {
+	value   []byte
+	expires time.Time
+}
+
+// wipe overwrites the value so it doesn't linger in memory
+func (s *secret) wipe() {
+	for i := range s.value {
+		s.value[i] = 0
+	}
+}
+
+type server struct {
+	options  Options
+	mutex    sync.Mutex
+	secrets  map[string]*secret
+	idleFrom time.Time
+	listener net.Listener
+}
+
+// Serve runs the agent on socketPath until it is stopped, or until it holds
+// nothing anymore with ExitWhenIdle
+func Serve(socketPath string, options Options) error {
+	if Running(socketPath) {
+		return errors.New("the agent is already running")
+	}
+
+	// The socket is only created inside a directory no other user can enter,
+	// so it is never reachable before it is protected
+	err := privateDir(filepath.Dir(socketPath))
+	if err != nil {
+		return err
+	}
+
+	// A socket left by an agent that didn't stop cleanly
+	os.Remove(socketPath)
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %v", socketPath, err)
+	}
+	defer os.Remove(socketPath)
+
+	err = os.Chmod(socketPath, 0600)
+	if err != nil {
+		listener.Close()
+		return fmt.Errorf("failed to protect %s: %v", socketPath, err)
+	}
+
+	s := &server{options: options, secrets: make(map[string]*secret), idleFrom: time.Now(), listener: listener}
+	go s.expire()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			// The listener is closed once the agent stops
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+
+		go s.handle(conn)
+	}
+}
+
+// privateDir creates dir readable by the current user only, and fixes the
+// permissions of an existing one
+func privateDir(dir string) error {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", dir, err)
+	}
+
+	err = os.Chmod(dir, 0700)
+	if err != nil {
+		return fmt.Errorf("failed to protect %s: %v", dir, err)
+	}
+
+	return nil
+}
+
+// trustedPeer tells whether conn comes from a process of the current user
+func trustedPeer(conn net.Conn) bool {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return false
+	}
+
+	uid, err := peerUID(unixConn)
+	return err == nil && uid == currentUID()
+}
+
+func (s *server) handle(conn net.Conn) {
+	defer conn.Close()
+
+	// Other users get nothing, not even an error
+	if !trustedPeer(conn) {
+		return
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	var req request
+	err := json.NewDecoder(conn).Decode(&req)
+	if err != nil {
+		return
+	}
+
+	json.NewEncoder(conn).Encode(s.apply(req))
+
+	if req.Op == "stop" {
+		s.listener.Close()
+	}
+}
+
+func (s *server) apply(req request) response {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	switch req.Op {
+	case "ping":
+		return response{Found: true}
+	case "get":
+		stored, found := s.secrets[req.Name]
+		if !found || time.Now().After(stored.expires) {
+			return response{}
+		}
+		return response{Value: string(stored.value), Found: true}
+	case "set":
+		ttl := time.Duration(req.TTL) * time.Second
+		if ttl <= 0 {
+			ttl = s.options.TTL
+		}
+		s.forget(req.Name)
+		s.secrets[req.Name] = &secret{value: []byte(req.Value), expires: time.Now().Add(ttl)}
+		return response{}
+	case "list":
+		entries := make([]Entry, 0, len(s.secrets))
+		for name, stored := range s.secrets {
+			entries = append(entries, Entry{Name: name, Expires: stored.expires})
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+		return response{Entries: entries, Found: true}
+	case "forget":
+		s.forget(req.Name)
+		return response{}
+	case "forget-all":
+		s.forgetAll()
+		return response{}
+	case "stop":
+		// The listener is closed once the answer is sent
+		s.forgetAll()
+		return response{}
+	default:
+		return response{Error: "unknown operation " + req.Op}
+	}
+}
+
+// forget wipes a secret, the caller holds the mutex
+func (s *server) forget(name string) {
+	if stored, found := s.secrets[name]; found {
+		stored.wipe()
+		delete(s.secrets, name)
+	}
+
+	if len(s.secrets) == 0 {
+		s.idleFrom = time.Now()
+	}
+}
+
+// forgetAll wipes every secret, the caller holds the mutex
+func (s *server) forgetAll() {
+	for name := range s.secrets {
+		s.forget(name)
+	}
+}
+
+// expire wipes the secrets once their time is up, and stops the agent when it
+// stayed empty for a while
+func (s *server) expire() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.mutex.Lock()
+
+		for name, stored := range s.secrets {
+			if time.Now().After(stored.expires) {
+				s.forget(name)
+			}
+		}
+
+		idle := s.options.ExitWhenIdle && len(s.secrets) == 0 && time.Since(s.idleFrom) > idleTimeout
+		s.mutex.Unlock()
+
+		if idle {
+			s.listener.Close()
+			return
+		}
+	}
+}
+
+// call sends req to the agent listening on socketPath
+func call(socketPath string, req request) (response, error) {
+	var res response
+
+	conn, err := net.DialTimeout("unix", socketPath, time.Second)
+	if err != nil {
+		return res, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	err = json.NewEncoder(conn).Encode(req)
+	if err != nil {
+		return res, err
+	}
+
+	err = json.NewDecoder(conn).Decode(&res)
+	if err != nil {
+		return res, err
+	}
+
+	if res.Error != "" {
+		return res, errors.New(res.Error)
+	}
+
+	return res, nil
+}
+
+// Running tells whether an agent listens on socketPath
+func Running(socketPath string) bool {
+	_, err := call(socketPath, request{Op: "ping"})
+	return err == nil
+}
+
+// Get returns the value the agent holds under name, if any
+func Get(socketPath, name string) (string, bool) {
+	res, err := call(socketPath, request{Op: "get", Name: name})
+	if err != nil || !res.Found {
+		return "", false
+	}
+	return res.Value, true
+}
+
+// Set hands value to the agent, which keeps it for ttl, or for its own TTL
+// when ttl is zero
+func Set(socketPath, name, value string, ttl time.Duration) error {
+	seconds := int64(ttl / time.Second)
+	if ttl > 0 && seconds < 1 {
+		seconds = 1
+	}
+
+	_, err := call(socketPath, request{Op: "set", Name: name, Value: value, TTL: seconds})
+	return err
+}
+
+// Forget wipes the value the agent holds under name, a missing agent holds nothing
+func Forget(socketPath, name string) error {
+	_, err := call(socketPath, request{Op: "forget", Name: name})
+	if err != nil && !Running(socketPath) {
+		return nil
+	}
+	return err
+}
+
+// List returns the secrets the agent holds, without their values
+func List(socketPath string) ([]Entry, error) {
+	res, err := call(socketPath, request{Op: "list"})
+	return res.Entries, err
+}
+
+// ForgetAll wipes every secret the agent holds, a missing agent holds nothing
+func ForgetAll(socketPath string) error {
+	_, err := call(socketPath, request{Op: "forget-all"})
+	if err != nil && !Running(socketPath) {
+		return nil
+	}
+	return err
+}
+
+// Stop wipes every secret and stops the agent
+func Stop(socketPath string) error {
+	_, err := call(socketPath, request{Op: "stop"})
+	return err
+}
@@ -0,0 +1,184 @@
+package agent
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// startAgent serves an agent on a socket in a temporary directory and stops
+// it at the end of the test
+func startAgent(t *testing.T, options Options) string {
+	t.Helper()
+
+	socketPath := filepath.Join(t.TempDir(), "agent", "agent.sock")
+
+	done := make(chan error, 1)
+	go func() {
+		done <- Serve(socketPath, options)
+	}()
+
+	for deadline := time.Now().Add(5 * time.Second); !Running(socketPath); {
+		select {
+		case err := <-done:
+			t.Fatalf("Serve: %v", err)
+		default:
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the agent didn't start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Cleanup(func() {
+		Stop(socketPath)
+		<-done
+	})
+
+	return socketPath
+}
+
+func TestSetAndGet(t *testing.T) {
+	socketPath := startAgent(t, Options{TTL: time.Minute})
+
+	if _, found := Get(socketPath, "missing"); found {
+		t.Error("Get found a secret that was never set")
+	}
+
+	if err := Set(socketPath, "passphrase", "hunter2", 0); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if value, found := Get(socketPath, "passphrase"); !found || value != "hunter2" {
+		t.Errorf("Get = %q, %v, want the value set", value, found)
+	}
+
+	entries, err := List(socketPath)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name != "passphrase" {
+		t.Errorf("List = %+v, want the passphrase only", entries)
+	}
+
+	if err := Forget(socketPath, "passphrase"); err != nil {
+		t.Fatalf("Forget: %v", err)
+	}
+	if _, found := Get(socketPath, "passphrase"); found {
+		t.Error("Get found a forgotten secret")
+	}
+}
+
+func TestSecretsExpire(t *testing.T) {
+	socketPath := startAgent(t, Options{TTL: 50 * time.Millisecond})
+
+	if err := Set(socketPath, "short", "value", 0); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := Set(socketPath, "long", "value", time.Minute); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if _, found := Get(socketPath, "short"); found {
+		t.Error("a secret was still given after its TTL")
+	}
+	if _, found := Get(socketPath, "long"); !found {
+		t.Error("a secret with its own TTL expired with the agent TTL")
+	}
+}
+
+func TestForgetAll(t *testing.T) {
+	socketPath := startAgent(t, Options{TTL: time.Minute})
+
+	for _, name := range []string{"a", "b"} {
+		if err := Set(socketPath, name, "value", 0); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+	}
+
+	if err := ForgetAll(socketPath); err != nil {
+		t.Fatalf("ForgetAll: %v", err)
+	}
+
+	entries, err := List(socketPath)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("List = %+v after ForgetAll, want nothing", entries)
+	}
+}
+
+func TestStop(t *testing.T) {
+	socketPath := startAgent(t, Options{TTL: time.Minute})
+
+	if err := Stop(socketPath); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	for deadline := time.Now().Add(5 * time.Second); Running(socketPath); {
+		if time.Now().After(deadline) {
+			t.Fatal("the agent still runs after Stop")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// A missing agent holds nothing to forget
+	if err := ForgetAll(socketPath); err != nil {
+		t.Errorf("ForgetAll without an agent: %v", err)
+	}
+}
+
+func TestSocketIsPrivate(t *testing.T) {
+	socketPath := startAgent(t, Options{TTL: time.Minute})
+
+	for filePath, want := range map[string]os.FileMode{filepath.Dir(socketPath): 0700, socketPath: 0600} {
+		info, err := os.Stat(filePath)
+		if err != nil {
+			t.Fatalf("Stat: %v", err)
+		}
+		if mode := info.Mode().Perm(); mode != want {
+			t.Errorf("%s mode = %o, want %o", filePath, mode, want)
+		}
+	}
+}
+
+func TestForeignPeersAreRejected(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "agent.sock")
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer listener.Close()
+
+	s := &server{options: Options{TTL: time.Minute}, secrets: make(map[string]*secret), listener: listener}
+	s.secrets["passphrase"] = &secret{value: []byte("hunter2"), expires: time.Now().Add(time.Minute)}
+
+	answers := make(chan error, 1)
+	go func() {
+		_, err := call(socketPath, request{Op: "get", Name: "passphrase"})
+		answers <- err
+	}()
+
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("Accept: %v", err)
+	}
+
+	// The agent takes the test for a process of another user
+	uid := os.Getuid()
+	currentUID = func() int { return uid + 1 }
+	defer func() { currentUID = os.Getuid }()
+
+	if trustedPeer(conn) {
+		t.Error("trustedPeer = true for another user")
+	}
+
+	s.handle(conn)
+	if err := <-answers; err == nil {
+		t.Error("a foreign peer got an answer")
+	}
+}
@@ -0,0 +1,100 @@
+package bucket
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// The kinds of failure scripts can rely on, test them with errors.Is
+var (
+	ErrNotFound      = errors.New("not found")
+	ErrAccessDenied  = errors.New("access denied")
+	ErrConflict      = errors.New("conflict")
+	ErrNotConfigured = errors.New("not configured")
+	ErrNetwork       = errors.New("network error")
+)
+
+// Error is a backend failure classified as one of the kinds above, its
+// message is the one of the backend
+type Error struct {
+	Kind error
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+func classified(kind error, err error) error {
+	return &Error{Kind: kind, Err: err}
+}
+
+// s3Error classifies the errors of the AWS SDK, anything else is returned as it is
+func s3Error(err error) error {
+	var kindErr *Error
+	if err == nil || errors.As(err, &kindErr) {
+		return err
+	}
+
+	var failure awserr.RequestFailure
+	if errors.As(err, &failure) {
+		switch failure.StatusCode() {
+		case http.StatusNotFound:
+			return classified(ErrNotFound, err)
+		case http.StatusForbidden, http.StatusUnauthorized:
+			return classified(ErrAccessDenied, err)
+		case http.StatusConflict, http.StatusPreconditionFailed:
+			return classified(ErrConflict, err)
+		}
+	}
+
+	var awsErr awserr.Error
+	if !errors.As(err, &awsErr) {
+		return err
+	}
+
+	switch awsErr.Code() {
+	case s3.ErrCodeNoSuchKey, s3.ErrCodeNoSuchBucket, "NotFound", "NoSuchVersion":
+		return classified(ErrNotFound, err)
+	case "AccessDenied", "Forbidden", "InvalidAccessKeyId", "SignatureDoesNotMatch", "ExpiredToken", "InvalidToken":
+		return classified(ErrAccessDenied, err)
+	case "PreconditionFailed", "OperationAborted":
+		return classified(ErrConflict, err)
+	case "NoCredentialProviders", "MissingRegion":
+		return classified(ErrNotConfigured, err)
+	case request.ErrCodeRequestError, request.ErrCodeResponseTimeout, "RequestTimeout":
+		return classified(ErrNetwork, err)
+	}
+
+	return err
+}
+
+// localError classifies the errors of the file system
+func localError(err error) error {
+	var kindErr *Error
+	if err == nil || errors.As(err, &kindErr) {
+		return err
+	}
+
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return classified(ErrNotFound, err)
+	case errors.Is(err, fs.ErrPermission):
+		return classified(ErrAccessDenied, err)
+	}
+
+	return err
+}
@@ -0,0 +1,358 @@
+package bucket
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// Messages receives the progress messages of the operations, the CLI sends
+// them elsewhere when stdout is reserved for results
+var Messages io.Writer = os.Stdout
+
+func UploadFile(store Store, filePath, targetName string) (*ObjectInfo, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	defer file.Close()
+
+	fileStat, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	if fileStat.IsDir() {
+		return nil, errors.New("you can't upload a directory without -r")
+	}
+
+	return Upload(store, file, targetName)
+}
+
+// Upload stores body under targetName, keeping the content it replaces as a
+// previous version, and returns what was stored
+func Upload(store Store, body io.Reader, targetName string) (*ObjectInfo, error) {
+	fmt.Fprintln(Messages, "🚚 Upload in progress...")
+
+	// Keep what is about to be overwritten so it can be rolled back
+	err := archiveVersion(store, targetName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to keep the previous version: %w", err)
+	}
+
+	// Use the target name directly without modifying it
+	info, err := store.Put(targetName, body, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload file: %w", err)
+	}
+
+	fmt.Fprintln(Messages, "🥳 Filed uploaded!!!")
+	return info, nil
+}
+
+// DownloadFile saves name to outputName and returns what was downloaded
+func DownloadFile(store Store, name, outputName string) (*ObjectInfo, error) {
+	fmt.Fprintln(Messages, "🚚 Download in progress...")
+
+	body, info, err := store.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download the file: %w", err)
+	}
+	defer body.Close()
+
+	err = saveFile(body, name, outputName)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintln(Messages, "🥳 Download succeed!!!")
+	return info, nil
+}
+
+// saveFile writes a downloaded body to outputName, or to name when no output was given
+func saveFile(body io.Reader, name, outputName string) error {
+	fileName := name
+	if outputName != "" {
+		fileName = outputName
+	}
+
+	file, err := os.Create(fileName)
+	if err != nil {
+		return fmt.Errorf("failed to create env file: %w", err)
+	}
+	defer file.Close()
+
+	_, err = io.Copy(file, body)
+	if err != nil {
+		return fmt.Errorf("failed to download file: %w", err)
+	}
+
+	return nil
+}
+
+// ListFiles prints the stored files matching opts as a table, or as a tree
+func ListFiles(store Store, opts ListOptions) error {
+	fmt.Fprintln(Messages, "🚚 List in progress...")
+
+	files, err := FindFiles(store, opts)
+	if err != nil {
+		return fmt.Errorf("failed to list files: %w", err)
+	}
+
+	fmt.Fprintln(Messages, "🥳 Files in the bucket:")
+
+	if len(files) == 0 {
+		fmt.Println("No files found in the bucket.")
+		return nil
+	}
+
+	if opts.Tree {
+		printTree(files)
+		return nil
+	}
+
+	fmt.Printf("%-40s | %-10s | %-19s | %s\n", "File Name", "Size", "Last Modified", "Owner")
+
+	for _, item := range files {
+		lastModified := item.LastModified.Format("2006-01-02 15:04:05")
+		fmt.Printf("%-40s | %-10s | %-19s | %s\n", item.Key, FormatSize(item.Size), lastModified, item.Owner)
+	}
+
+	return nil
+}
+
+// ListUserFiles lists the stored files leaving out the ones denv keeps for itself
+func ListUserFiles(store Store) ([]ObjectInfo, error) {
+	files, err := store.List("")
+	if err != nil {
+		return nil, err
+	}
+
+	userFiles := make([]ObjectInfo, 0, len(files))
+	for _, item := range files {
+		if !IsInternal(item.Key) {
+			userFiles = append(userFiles, item)
+		}
+	}
+
+	return userFiles, nil
+}
+
+// ListFileNames returns just the names of files in the store for use with autocomplete
+func ListFileNames(store Store) ([]string, error) {
+	files, err := ListUserFiles(store)
+	if err != nil {
+		return nil, err
+	}
+
+	fileNames := make([]string, 0, len(files))
+	for _, item := range files {
+		fileNames = append(fileNames, item.Key)
+	}
+
+	return fileNames, nil
+}
+
+func DeleteFile(store Store, name string) error {
+	fmt.Fprintln(Messages, "🚚 Delete in progress...")
+
+	// S3 deletes missing keys without complaining, check first so every backend reports it
+	_, err := store.Stat(name)
+	if err != nil {
+		return fmt.Errorf("failed to find file %s: %w", name, err)
+	}
+
+	// A deleted file keeps its history, so it can be rolled back
+	err = archiveVersion(store, name)
+	if err != nil {
+		return fmt.Errorf("failed to keep the previous version: %w", err)
+	}
+
+	err = store.Delete(name)
+	if err != nil {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+
+	fmt.Fprintln(Messages, "🥳 File deleted!!!")
+	return nil
+}
+
+// RenameFile moves oldName to newName, keeping its extension, and returns the
+// new key. A file already named newName is only replaced when overwrite is true.
+func RenameFile(store Store, oldName, newName string, overwrite bool) (string, error) {
+	fmt.Fprintln(Messages, "🚚 Rename in progress...")
+
+	// Make sure the file exists before touching anything
+	source, err := store.Stat(oldName)
+	if err != nil {
+		return "", fmt.Errorf("failed to find file %s: %w", oldName, err)
+	}
+
+	// Extract file extension from the old name
+	ext := path.Ext(oldName)
+	var newKey string
+
+	// If old name has an extension, use it for the new name
+	if ext != "" {
+		baseName := strings.TrimSuffix(newName, ext)
+		newKey = baseName + ext
+	} else {
+		newKey = newName
+	}
+
+	if newKey == oldName {
+		return "", fmt.Errorf("%s already has that name", oldName)
+	}
+
+	err = checkDestination(store, newKey, overwrite)
+	if err != nil {
+		return "", err
+	}
+
+	err = moveObject(store, source, newKey)
+	if err != nil {
+		return "", err
+	}
+
+	fmt.Fprintf(Messages, "🥳 File renamed from %s to %s!!!\n", oldName, newKey)
+	return newKey, nil
+}
+
+// RenamePrefix moves every file whose name starts with oldPrefix under
+// newPrefix, and returns the new name of each file moved by its old name.
+// Existing files are only replaced when overwrite is true.
+func RenamePrefix(store Store, oldPrefix, newPrefix string, overwrite bool) (map[string]string, error) {
+	fmt.Fprintln(Messages, "🚚 Rename in progress...")
+
+	if oldPrefix == newPrefix {
+		return nil, fmt.Errorf("%s already has that name", oldPrefix)
+	}
+
+	files, err := store.List(oldPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+
+	moves := make(map[*ObjectInfo]string)
+	for i := range files {
+		if !IsInternal(files[i].Key) {
+			moves[&files[i]] = newPrefix + strings.TrimPrefix(files[i].Key, oldPrefix)
+		}
+	}
+
+	if len(moves) == 0 {
+		return nil, classified(ErrNotFound, fmt.Errorf("no files found under %s", oldPrefix))
+	}
+
+	// Check every destination first so a conflict doesn't leave half of them moved
+	for _, newKey := range moves {
+		err := checkDestination(store, newKey, overwrite)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	renamed := make(map[string]string)
+
+	for i := range files {
+		file := &files[i]
+		newKey, ok := moves[file]
+		if !ok {
+			continue
+		}
+
+		// Stop at the first failure, what was moved so far is reported
+		err := moveObject(store, file, newKey)
+		if err != nil {
+			return renamed, err
+		}
+
+		renamed[file.Key] = newKey
+	}
+
+	fmt.Fprintf(Messages, "🥳 %d files renamed from %s to %s!!!\n", len(renamed), oldPrefix, newPrefix)
+	return renamed, nil
+}
+
+// checkDestination refuses to move onto an existing file unless overwrite is
+// true, in which case the file is kept in its history before being replaced
+func checkDestination(store Store, newKey string, overwrite bool) error {
+	_, err := store.Stat(newKey)
+	if IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check %s: %w", newKey, err)
+	}
+
+	if !overwrite {
+		return classified(ErrConflict, fmt.Errorf("%s already exists, use --force to replace it", newKey))
+	}
+
+	err = archiveVersion(store, newKey)
+	if err != nil {
+		return fmt.Errorf("failed to keep the previous version of %s: %w", newKey, err)
+	}
+
+	return nil
+}
+
+// moveObject copies source to newKey and only deletes it once the copy is
+// known to hold the same content, its history follows it
+func moveObject(store Store, source *ObjectInfo, newKey string) error {
+	err := store.Copy(source.Key, newKey)
+	if err != nil {
+		return fmt.Errorf("failed to rename file to %s: %w", newKey, err)
+	}
+
+	copied, err := store.Stat(newKey)
+	if err != nil {
+		return fmt.Errorf("failed to verify the copy of %s: %w", source.Key, err)
+	}
+
+	if !sameContent(source, copied) {
+		// Don't leave a broken copy behind
+		store.Delete(newKey)
+		return fmt.Errorf("the copy of %s doesn't match it, the original was kept", source.Key)
+	}
+
+	// Delete the old object
+	err = store.Delete(source.Key)
+	if err != nil {
+		return fmt.Errorf("failed to delete original file after rename: %w", err)
+	}
+
+	err = moveHistory(store, source.Key, newKey)
+	if err != nil {
+		return fmt.Errorf("%s was renamed but not its history: %w", source.Key, err)
+	}
+
+	return nil
+}
+
+// sameContent compares the size of two objects, and their ETag unless either
+// was uploaded in parts or encrypted with KMS, since those ETags aren't the MD5
+// of the content and change with every copy
+func sameContent(a, b *ObjectInfo) bool {
+	if a.Size != b.Size {
+		return false
+	}
+
+	if strings.Contains(a.ETag, "-") || strings.Contains(b.ETag, "-") {
+		return true
+	}
+
+	if isKMSEncrypted(a) || isKMSEncrypted(b) {
+		return true
+	}
+
+	return a.ETag == b.ETag
+}
+
+// isKMSEncrypted tells whether S3 encrypts object with a KMS key, aws:kms or aws:kms:dsse
+func isKMSEncrypted(object *ObjectInfo) bool {
+	return strings.HasPrefix(object.ServerSideEncryption, "aws:kms")
+}
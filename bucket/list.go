@@ -0,0 +1,181 @@
+package bucket
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Orders of the listed files
+const (
+	SortName = "name"
+	SortDate = "date"
+	SortSize = "size"
+)
+
+// ListOptions narrows and orders the listed files
+type ListOptions struct {
+	// Prefix only keeps the nicknames starting with it, e.g. "team/"
+	Prefix string
+	// Filter is a glob matched against the whole nickname or its last segment
+	Filter string
+	// Sort is SortName, SortDate for the newest first or SortSize for the largest first
+	Sort string
+	// Tree shows nicknames separated by slashes as folders
+	Tree bool
+}
+
+// FindFiles lists the stored files matching opts, in the order it asks for
+func FindFiles(store Store, opts ListOptions) ([]ObjectInfo, error) {
+	files, err := store.List(opts.Prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make([]ObjectInfo, 0, len(files))
+	for _, item := range files {
+		if IsInternal(item.Key) {
+			continue
+		}
+
+		matches, err := matchesFilter(item.Key, opts.Filter)
+		if err != nil {
+			return nil, err
+		}
+
+		if matches {
+			found = append(found, item)
+		}
+	}
+
+	err = sortFiles(found, opts.Sort)
+	if err != nil {
+		return nil, err
+	}
+
+	return found, nil
+}
+
+func matchesFilter(key, filter string) (bool, error) {
+	if filter == "" {
+		return true, nil
+	}
+
+	matches, err := path.Match(filter, key)
+	if err != nil || matches {
+		return matches, err
+	}
+
+	return path.Match(filter, path.Base(key))
+}
+
+func sortFiles(files []ObjectInfo, by string) error {
+	var less func(a, b ObjectInfo) bool
+
+	switch by {
+	case SortName, "":
+		less = func(a, b ObjectInfo) bool { return a.Key < b.Key }
+	case SortDate:
+		less = func(a, b ObjectInfo) bool { return a.LastModified.After(b.LastModified) }
+	case SortSize:
+		less = func(a, b ObjectInfo) bool { return a.Size > b.Size }
+	default:
+		return fmt.Errorf("unknown sort order %s, use %s, %s or %s", by, SortName, SortDate, SortSize)
+	}
+
+	// Files that compare equal stay sorted by name
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].Key < files[j].Key
+	})
+	sort.SliceStable(files, func(i, j int) bool {
+		return less(files[i], files[j])
+	})
+
+	return nil
+}
+
+// FormatSize writes a size in bytes the way people read it, e.g. 1.5 MB
+func FormatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	value := float64(size) / unit
+	units := []string{"KB", "MB", "GB", "TB"}
+
+	i := 0
+	for value >= unit && i < len(units)-1 {
+		value /= unit
+		i++
+	}
+
+	return fmt.Sprintf("%.1f %s", value, units[i])
+}
+
+// treeNode is a folder of the tree view, or a file when it has an object
+type treeNode struct {
+	name     string
+	object   *ObjectInfo
+	children []*treeNode
+}
+
+func (node *treeNode) child(name string) *treeNode {
+	for _, child := range node.children {
+		if child.name == name && child.object == nil {
+			return child
+		}
+	}
+
+	child := &treeNode{name: name}
+	node.children = append(node.children, child)
+	return child
+}
+
+// printTree shows the files as folders split on the slashes of their
+// nicknames, keeping the order of files
+func printTree(files []ObjectInfo) {
+	root := &treeNode{}
+
+	for i := range files {
+		segments := strings.Split(files[i].Key, "/")
+
+		node := root
+		for _, folder := range segments[:len(segments)-1] {
+			node = node.child(folder)
+		}
+
+		node.children = append(node.children, &treeNode{name: segments[len(segments)-1], object: &files[i]})
+	}
+
+	for _, child := range root.children {
+		printTreeNode(child, "", "")
+	}
+}
+
+func printTreeNode(node *treeNode, indent, connector string) {
+	if node.object != nil {
+		lastModified := node.object.LastModified.Format("2006-01-02 15:04:05")
+		fmt.Printf("%s%s%s  (%s, %s)\n", indent, connector, node.name, FormatSize(node.object.Size), lastModified)
+		return
+	}
+
+	fmt.Printf("%s%s%s/\n", indent, connector, node.name)
+
+	// Folders below the top level are indented under their connector
+	switch connector {
+	case "├── ":
+		indent += "│   "
+	case "└── ":
+		indent += "    "
+	}
+
+	for i, child := range node.children {
+		if i == len(node.children)-1 {
+			printTreeNode(child, indent, "└── ")
+		} else {
+			printTreeNode(child, indent, "├── ")
+		}
+	}
+}
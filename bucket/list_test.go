@@ -0,0 +1,189 @@
+package bucket
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func fileKeys(files []ObjectInfo) string {
+	keys := make([]string, 0, len(files))
+	for _, file := range files {
+		keys = append(keys, file.Key)
+	}
+	return strings.Join(keys, ",")
+}
+
+func TestSortFiles(t *testing.T) {
+	day := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	files := []ObjectInfo{
+		{Key: "b.env", Size: 10, LastModified: day},
+		{Key: "c.env", Size: 30, LastModified: day.Add(-time.Hour)},
+		{Key: "a.env", Size: 10, LastModified: day.Add(time.Hour)},
+		{Key: "d.env", Size: 20, LastModified: day},
+	}
+
+	tests := []struct {
+		by   string
+		want string
+	}{
+		{"", "a.env,b.env,c.env,d.env"},
+		{SortName, "a.env,b.env,c.env,d.env"},
+		// Newest first, files modified at the same time stay sorted by name
+		{SortDate, "a.env,b.env,d.env,c.env"},
+		// Largest first, files of the same size stay sorted by name
+		{SortSize, "c.env,d.env,a.env,b.env"},
+	}
+
+	for _, tt := range tests {
+		sorted := append([]ObjectInfo(nil), files...)
+		if err := sortFiles(sorted, tt.by); err != nil {
+			t.Fatalf("sortFiles %q: %v", tt.by, err)
+		}
+		if got := fileKeys(sorted); got != tt.want {
+			t.Errorf("sortFiles %q = %s, want %s", tt.by, got, tt.want)
+		}
+	}
+
+	if err := sortFiles(files, "owner"); err == nil {
+		t.Error("an unknown sort order was accepted")
+	}
+}
+
+func TestMatchesFilter(t *testing.T) {
+	tests := []struct {
+		key    string
+		filter string
+		want   bool
+	}{
+		{"dev.env", "", true},
+		{"dev.env", "*.env", true},
+		{"team/dev.env", "*.env", true},
+		{"team/dev.env", "team/*", true},
+		{"team/dev.env", "prod*", false},
+		{"team/prod/app.env", "team/*", false},
+		{"team/prod/app.env", "app.*", true},
+		{"dev.json", "*.env", false},
+	}
+
+	for _, tt := range tests {
+		got, err := matchesFilter(tt.key, tt.filter)
+		if err != nil {
+			t.Fatalf("matchesFilter(%q, %q): %v", tt.key, tt.filter, err)
+		}
+		if got != tt.want {
+			t.Errorf("matchesFilter(%q, %q) = %v, want %v", tt.key, tt.filter, got, tt.want)
+		}
+	}
+
+	if _, err := matchesFilter("dev.env", "[unclosed"); err == nil {
+		t.Error("an invalid filter was accepted")
+	}
+}
+
+func TestFindFiles(t *testing.T) {
+	store, root := newTestStore(t)
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	for i, key := range []string{"b.env", "team/dev.env", "team/prod.env", "a.json"} {
+		putString(t, store, key, strings.Repeat("x", i+1))
+		setModTime(t, root, key, start.Add(time.Duration(i)*time.Minute))
+	}
+
+	// Objects denv keeps for itself never show up
+	putString(t, store, versionsPrefix+"b.env/1.env", "x")
+	if all, err := store.List(InternalPrefix); err != nil || len(all) != 1 {
+		t.Fatalf("List %s = %v, %v, want the version", InternalPrefix, all, err)
+	}
+
+	tests := []struct {
+		name string
+		opts ListOptions
+		want string
+	}{
+		{"everything", ListOptions{}, "a.json,b.env,team/dev.env,team/prod.env"},
+		{"prefix", ListOptions{Prefix: "team/"}, "team/dev.env,team/prod.env"},
+		{"filter", ListOptions{Filter: "*.env"}, "b.env,team/dev.env,team/prod.env"},
+		{"prefix and filter", ListOptions{Prefix: "team/", Filter: "prod*"}, "team/prod.env"},
+		{"sorted by date", ListOptions{Sort: SortDate}, "a.json,team/prod.env,team/dev.env,b.env"},
+		{"sorted by size", ListOptions{Sort: SortSize, Filter: "*.env"}, "team/prod.env,team/dev.env,b.env"},
+		{"internal prefix", ListOptions{Prefix: InternalPrefix}, ""},
+		{"nothing matches", ListOptions{Filter: "*.yaml"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := FindFiles(store, tt.opts)
+			if err != nil {
+				t.Fatalf("FindFiles: %v", err)
+			}
+			if got := fileKeys(files); got != tt.want {
+				t.Errorf("FindFiles = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := FindFiles(store, ListOptions{Sort: "owner"}); err == nil {
+		t.Error("an unknown sort order was accepted")
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := map[int64]string{
+		0:                         "0 B",
+		1023:                      "1023 B",
+		1024:                      "1.0 KB",
+		1536:                      "1.5 KB",
+		1024 * 1024:               "1.0 MB",
+		5*1024*1024*1024 + 1:      "5.0 GB",
+		1024 * 1024 * 1024 * 1024: "1.0 TB",
+		// Sizes past the last unit stay in it
+		2048 * 1024 * 1024 * 1024 * 1024: "2048.0 TB",
+	}
+
+	for size, want := range tests {
+		if got := FormatSize(size); got != want {
+			t.Errorf("FormatSize(%d) = %s, want %s", size, got, want)
+		}
+	}
+}
+
+func TestPrintTree(t *testing.T) {
+	modified := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	var files []ObjectInfo
+	for _, key := range []string{"app.env", "team/dev.env", "team/prod/api.env", "team/prod/web.env", "team/z.env", "other/x.env"} {
+		files = append(files, ObjectInfo{Key: key, Size: 2048, LastModified: modified})
+	}
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Pipe: %v", err)
+	}
+
+	stdout := os.Stdout
+	os.Stdout = writer
+	printTree(files)
+	os.Stdout = stdout
+	writer.Close()
+
+	output, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("reading stdout: %v", err)
+	}
+
+	want := `app.env  (2.0 KB, 2024-01-02 03:04:05)
+team/
+├── dev.env  (2.0 KB, 2024-01-02 03:04:05)
+├── prod/
+│   ├── api.env  (2.0 KB, 2024-01-02 03:04:05)
+│   └── web.env  (2.0 KB, 2024-01-02 03:04:05)
+└── z.env  (2.0 KB, 2024-01-02 03:04:05)
+other/
+└── x.env  (2.0 KB, 2024-01-02 03:04:05)
+`
+	if string(output) != want {
+		t.Errorf("printTree printed:\n%s\nwant:\n%s", output, want)
+	}
+}
@@ -0,0 +1,356 @@
+package bucket
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// localMetaDir holds the metadata sidecars of the stored files, it is hidden from listings
+const localMetaDir = ".denv-meta"
+
+// localSidecarVersion tells the sidecars below apart from the plain metadata
+// maps written before the ETag was kept in them
+const localSidecarVersion = 1
+
+// localSidecar keeps the metadata of a stored file along with its ETag, which is
+// only trusted while the size and modification time still match the file
+type localSidecar struct {
+	Version  int               `json:"sidecar_version"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	ETag     string            `json:"etag,omitempty"`
+	Size     int64             `json:"size"`
+	ModTime  time.Time         `json:"mod_time"`
+}
+
+// LocalStore keeps nicknamed files in a plain directory such as a mounted NAS or a synced folder
+type LocalStore struct {
+	root string
+}
+
+func NewLocalStore(root string) (*LocalStore, error) {
+	if root == "" {
+		return nil, errors.New("local storage path is not set")
+	}
+
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve local storage path: %v", err)
+	}
+
+	err = os.MkdirAll(absRoot, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create local storage directory: %v", err)
+	}
+
+	return &LocalStore{root: absRoot}, nil
+}
+
+func (ls *LocalStore) Put(key string, body io.Reader, metadata map[string]string) (*ObjectInfo, error) {
+	filePath, err := ls.objectPath(key)
+	if err != nil {
+		return nil, localError(err)
+	}
+
+	err = os.MkdirAll(filepath.Dir(filePath), 0755)
+	if err != nil {
+		return nil, localError(err)
+	}
+
+	// Write to a temporary file first so readers never see a partial object
+	tempFile, err := os.CreateTemp(filepath.Dir(filePath), ".denv-upload-*")
+	if err != nil {
+		return nil, localError(err)
+	}
+	defer os.Remove(tempFile.Name())
+
+	// Hash while writing so the ETag doesn't cost another read
+	hash := md5.New()
+	_, err = io.Copy(io.MultiWriter(tempFile, hash), body)
+	closeErr := tempFile.Close()
+	if err != nil {
+		return nil, localError(err)
+	}
+	if closeErr != nil {
+		return nil, closeErr
+	}
+
+	err = os.Rename(tempFile.Name(), filePath)
+	if err != nil {
+		return nil, localError(err)
+	}
+
+	fileStat, err := os.Stat(filePath)
+	if err != nil {
+		return nil, localError(err)
+	}
+
+	err = ls.writeSidecar(filePath, &localSidecar{
+		Metadata: metadata,
+		ETag:     hashETag(hash.Sum(nil)),
+		Size:     fileStat.Size(),
+		ModTime:  fileStat.ModTime(),
+	})
+	if err != nil {
+		return nil, localError(err)
+	}
+
+	return ls.Stat(key)
+}
+
+func (ls *LocalStore) Get(key string) (io.ReadCloser, *ObjectInfo, error) {
+	info, err := ls.Stat(key)
+	if err != nil {
+		return nil, nil, localError(err)
+	}
+
+	filePath, err := ls.objectPath(key)
+	if err != nil {
+		return nil, nil, localError(err)
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, nil, localError(err)
+	}
+
+	return file, info, nil
+}
+
+func (ls *LocalStore) List(prefix string) ([]ObjectInfo, error) {
+	files := make([]ObjectInfo, 0)
+
+	err := filepath.WalkDir(ls.root, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return localError(err)
+		}
+
+		relPath, err := filepath.Rel(ls.root, filePath)
+		if err != nil {
+			return localError(err)
+		}
+		key := filepath.ToSlash(relPath)
+
+		if entry.IsDir() {
+			if filePath == ls.root {
+				return nil
+			}
+
+			// Skip the metadata sidecars and the directories outside of prefix
+			dirKey := key + "/"
+			if entry.Name() == localMetaDir || !(strings.HasPrefix(dirKey, prefix) || strings.HasPrefix(prefix, dirKey)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		// Skip uploads that are still being written
+		if strings.HasPrefix(entry.Name(), ".denv-upload-") {
+			return nil
+		}
+
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		info, err := ls.Stat(key)
+		if err != nil {
+			return localError(err)
+		}
+
+		fileInfo, err := entry.Info()
+		if err != nil {
+			return localError(err)
+		}
+		info.Owner = fileOwner(fileInfo)
+
+		files = append(files, *info)
+		return nil
+	})
+	if err != nil {
+		return nil, localError(err)
+	}
+
+	return files, nil
+}
+
+func (ls *LocalStore) Delete(key string) error {
+	filePath, err := ls.objectPath(key)
+	if err != nil {
+		return localError(err)
+	}
+
+	err = os.Remove(filePath)
+	if err != nil {
+		return localError(err)
+	}
+
+	err = os.Remove(ls.metadataPath(filePath))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return localError(err)
+	}
+
+	ls.removeEmptyParents(filepath.Dir(filePath))
+	return nil
+}
+
+func (ls *LocalStore) Copy(srcKey, dstKey string) error {
+	body, info, err := ls.Get(srcKey)
+	if err != nil {
+		return localError(err)
+	}
+	defer body.Close()
+
+	_, err = ls.Put(dstKey, body, info.Metadata)
+	return localError(err)
+}
+
+func (ls *LocalStore) Stat(key string) (*ObjectInfo, error) {
+	filePath, err := ls.objectPath(key)
+	if err != nil {
+		return nil, localError(err)
+	}
+
+	fileStat, err := os.Stat(filePath)
+	if err != nil {
+		return nil, localError(err)
+	}
+
+	if fileStat.IsDir() {
+		return nil, &fs.PathError{Op: "stat", Path: key, Err: fs.ErrNotExist}
+	}
+
+	sidecar, err := ls.readSidecar(filePath)
+	if err != nil {
+		return nil, localError(err)
+	}
+
+	// Files written outside of denv, e.g. by a synced folder, are hashed again
+	// and the ETag kept for the next time, when the sidecar can be written
+	if sidecar.ETag == "" || sidecar.Size != fileStat.Size() || !sidecar.ModTime.Equal(fileStat.ModTime()) {
+		sidecar.ETag, err = fileETag(filePath)
+		if err != nil {
+			return nil, localError(err)
+		}
+		sidecar.Size = fileStat.Size()
+		sidecar.ModTime = fileStat.ModTime()
+		_ = ls.writeSidecar(filePath, sidecar)
+	}
+
+	metadata := sidecar.Metadata
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+
+	return &ObjectInfo{
+		Key:          key,
+		Size:         fileStat.Size(),
+		LastModified: fileStat.ModTime(),
+		ETag:         sidecar.ETag,
+		Metadata:     metadata,
+	}, nil
+}
+
+// objectPath maps a key to its path under the root, rejecting keys that escape it
+func (ls *LocalStore) objectPath(key string) (string, error) {
+	filePath := filepath.Join(ls.root, filepath.FromSlash(key))
+
+	if !strings.HasPrefix(filePath, ls.root+string(os.PathSeparator)) {
+		return "", fmt.Errorf("invalid file name: %s", key)
+	}
+
+	relPath, _ := filepath.Rel(ls.root, filePath)
+	if strings.SplitN(filepath.ToSlash(relPath), "/", 2)[0] == localMetaDir {
+		return "", fmt.Errorf("invalid file name: %s", key)
+	}
+
+	return filePath, nil
+}
+
+// metadataPath returns where the metadata sidecar of an object path lives
+func (ls *LocalStore) metadataPath(filePath string) string {
+	relPath, _ := filepath.Rel(ls.root, filePath)
+	return filepath.Join(ls.root, localMetaDir, relPath+".json")
+}
+
+func (ls *LocalStore) writeSidecar(filePath string, sidecar *localSidecar) error {
+	metaPath := ls.metadataPath(filePath)
+
+	sidecar.Version = localSidecarVersion
+	content, err := json.Marshal(sidecar)
+	if err != nil {
+		return localError(err)
+	}
+
+	err = os.MkdirAll(filepath.Dir(metaPath), 0755)
+	if err != nil {
+		return localError(err)
+	}
+
+	return os.WriteFile(metaPath, content, 0644)
+}
+
+func (ls *LocalStore) readSidecar(filePath string) (*localSidecar, error) {
+	content, err := os.ReadFile(ls.metadataPath(filePath))
+	if errors.Is(err, fs.ErrNotExist) {
+		return &localSidecar{}, nil
+	}
+	if err != nil {
+		return nil, localError(err)
+	}
+
+	sidecar := &localSidecar{}
+	err = json.Unmarshal(content, sidecar)
+	if err == nil && sidecar.Version == localSidecarVersion {
+		return sidecar, nil
+	}
+
+	// Sidecars written before the ETag was kept only hold the metadata
+	metadata := map[string]string{}
+	err = json.Unmarshal(content, &metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metadata: %v", err)
+	}
+
+	return &localSidecar{Metadata: metadata}, nil
+}
+
+// removeEmptyParents cleans up the directories left behind by slash-separated keys
+func (ls *LocalStore) removeEmptyParents(dir string) {
+	for dir != ls.root && strings.HasPrefix(dir, ls.root) {
+		if os.Remove(dir) != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// fileETag hashes the file content the same way S3 does for single part uploads
+func fileETag(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := md5.New()
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return "", err
+	}
+
+	return hashETag(hash.Sum(nil)), nil
+}
+
+// hashETag quotes an MD5 sum like S3 ETags are
+func hashETag(sum []byte) string {
+	return `"` + hex.EncodeToString(sum) + `"`
+}
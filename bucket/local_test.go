@@ -0,0 +1,498 @@
+package bucket
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestStore returns a LocalStore in a temporary directory and silences the
+// progress messages of the operations
+func newTestStore(t *testing.T) (*LocalStore, string) {
+	t.Helper()
+
+	previous := Messages
+	Messages = io.Discard
+	t.Cleanup(func() { Messages = previous })
+
+	root := t.TempDir()
+	store, err := NewLocalStore(root)
+	if err != nil {
+		t.Fatalf("NewLocalStore: %v", err)
+	}
+
+	return store, root
+}
+
+func putString(t *testing.T, store Store, key, content string) *ObjectInfo {
+	t.Helper()
+
+	info, err := store.Put(key, strings.NewReader(content), nil)
+	if err != nil {
+		t.Fatalf("Put %s: %v", key, err)
+	}
+	return info
+}
+
+func getString(t *testing.T, store Store, key string) string {
+	t.Helper()
+
+	body, _, err := store.Get(key)
+	if err != nil {
+		t.Fatalf("Get %s: %v", key, err)
+	}
+	defer body.Close()
+
+	content, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatalf("Get %s: %v", key, err)
+	}
+	return string(content)
+}
+
+// setModTime moves the modification time of key
+func setModTime(t *testing.T, root, key string, modTime time.Time) {
+	t.Helper()
+
+	err := os.Chtimes(filepath.Join(root, filepath.FromSlash(key)), modTime, modTime)
+	if err != nil {
+		t.Fatalf("Chtimes %s: %v", key, err)
+	}
+}
+
+func TestLocalStorePutGet(t *testing.T) {
+	store, _ := newTestStore(t)
+
+	info, err := store.Put("team/dev.env", strings.NewReader("A=1\n"), map[string]string{"owner": "alice"})
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if info.Size != 4 || info.ETag == "" {
+		t.Errorf("Put returned %+v, want size 4 and an ETag", info)
+	}
+
+	body, got, err := store.Get("team/dev.env")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	content, _ := io.ReadAll(body)
+	body.Close()
+
+	if string(content) != "A=1\n" {
+		t.Errorf("Get content = %q, want %q", content, "A=1\n")
+	}
+	if got.ETag != info.ETag {
+		t.Errorf("Get ETag = %s, want %s", got.ETag, info.ETag)
+	}
+	if got.Metadata["owner"] != "alice" {
+		t.Errorf("Get metadata = %v, want owner alice", got.Metadata)
+	}
+
+	// Replacing an object drops the metadata it had
+	putString(t, store, "team/dev.env", "A=2\n")
+	stat, err := store.Stat("team/dev.env")
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if len(stat.Metadata) != 0 {
+		t.Errorf("metadata after Put without any = %v, want none", stat.Metadata)
+	}
+}
+
+func TestLocalStoreMissingKeys(t *testing.T) {
+	store, _ := newTestStore(t)
+
+	if _, _, err := store.Get("missing.env"); !IsNotFound(err) {
+		t.Errorf("Get missing = %v, want not found", err)
+	}
+	if _, err := store.Stat("missing.env"); !IsNotFound(err) {
+		t.Errorf("Stat missing = %v, want not found", err)
+	}
+	if err := store.Delete("missing.env"); !IsNotFound(err) {
+		t.Errorf("Delete missing = %v, want not found", err)
+	}
+
+	// A directory made by nested keys isn't an object itself
+	putString(t, store, "a/b.env", "B=1\n")
+	if _, err := store.Stat("a"); !IsNotFound(err) {
+		t.Errorf("Stat directory = %v, want not found", err)
+	}
+}
+
+func TestLocalStoreRejectsEscapingKeys(t *testing.T) {
+	store, _ := newTestStore(t)
+
+	for _, key := range []string{"../outside.env", "a/../../outside.env", localMetaDir + "/x.json", ""} {
+		if _, err := store.Put(key, strings.NewReader("x"), nil); err == nil {
+			t.Errorf("Put %q succeeded, want an error", key)
+		}
+	}
+}
+
+func TestLocalStoreList(t *testing.T) {
+	store, _ := newTestStore(t)
+
+	putString(t, store, "a.env", "A=1\n")
+	putString(t, store, "team/dev.env", "B=1\n")
+	putString(t, store, "team/prod.env", "C=1\n")
+	if _, err := store.Put("meta.env", strings.NewReader("D=1\n"), map[string]string{"k": "v"}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"", []string{"a.env", "meta.env", "team/dev.env", "team/prod.env"}},
+		{"team/", []string{"team/dev.env", "team/prod.env"}},
+		{"team/p", []string{"team/prod.env"}},
+		{"te", []string{"team/dev.env", "team/prod.env"}},
+		{"a", []string{"a.env"}},
+		{"nothing", nil},
+	}
+
+	for _, tt := range tests {
+		files, err := store.List(tt.prefix)
+		if err != nil {
+			t.Fatalf("List %q: %v", tt.prefix, err)
+		}
+
+		var keys []string
+		for _, file := range files {
+			keys = append(keys, file.Key)
+		}
+		sort.Strings(keys)
+
+		if strings.Join(keys, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("List %q = %v, want %v", tt.prefix, keys, tt.want)
+		}
+	}
+}
+
+func TestLocalStoreKeepsETagInSidecar(t *testing.T) {
+	store, root := newTestStore(t)
+	filePath := filepath.Join(root, "a.env")
+	sidecarPath := filepath.Join(root, localMetaDir, "a.env.json")
+
+	put := putString(t, store, "a.env", "A=1\n")
+	if want, _ := fileETag(filePath); put.ETag != want {
+		t.Fatalf("Put ETag = %s, want %s", put.ETag, want)
+	}
+
+	// The file isn't hashed again while the sidecar matches it
+	sidecar, err := store.readSidecar(filePath)
+	if err != nil {
+		t.Fatalf("readSidecar: %v", err)
+	}
+	sidecar.ETag = `"cached"`
+	if err := store.writeSidecar(filePath, sidecar); err != nil {
+		t.Fatalf("writeSidecar: %v", err)
+	}
+	if info, err := store.Stat("a.env"); err != nil || info.ETag != `"cached"` {
+		t.Errorf("Stat = %+v, %v, want the cached ETag", info, err)
+	}
+
+	// A file changed outside of denv is hashed again
+	if err := os.WriteFile(filePath, []byte("A=changed\n"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	want, _ := fileETag(filePath)
+	if info, err := store.Stat("a.env"); err != nil || info.ETag != want {
+		t.Errorf("Stat after a change = %+v, %v, want ETag %s", info, err, want)
+	}
+
+	// Sidecars written before the ETag was kept still give their metadata
+	if err := os.WriteFile(sidecarPath, []byte(`{"k":"v"}`), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	info, err := store.Stat("a.env")
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if info.ETag != want || info.Metadata["k"] != "v" {
+		t.Errorf("Stat with an old sidecar = %+v, want ETag %s and metadata k=v", info, want)
+	}
+}
+
+func TestLocalStoreDeleteRemovesEmptyDirectories(t *testing.T) {
+	store, root := newTestStore(t)
+
+	if _, err := store.Put("a/b/c.env", strings.NewReader("C=1\n"), map[string]string{"k": "v"}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	if err := store.Delete("a/b/c.env"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(root, "a")); !os.IsNotExist(err) {
+		t.Errorf("a/ still exists after deleting its only file: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, localMetaDir, "a", "b", "c.env.json")); !os.IsNotExist(err) {
+		t.Errorf("the metadata of a/b/c.env was kept: %v", err)
+	}
+}
+
+func TestLocalStoreCopy(t *testing.T) {
+	store, _ := newTestStore(t)
+
+	source, err := store.Put("src.env", strings.NewReader("A=1\n"), map[string]string{"k": "v"})
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	if err := store.Copy("src.env", "copies/dst.env"); err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+
+	copied, err := store.Stat("copies/dst.env")
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if copied.ETag != source.ETag || copied.Metadata["k"] != "v" {
+		t.Errorf("copy = %+v, want the ETag and metadata of %+v", copied, source)
+	}
+	if getString(t, store, "src.env") != "A=1\n" {
+		t.Error("Copy changed the source")
+	}
+
+	if err := store.Copy("missing.env", "dst.env"); !IsNotFound(err) {
+		t.Errorf("Copy missing = %v, want not found", err)
+	}
+}
+
+func TestManagedVersions(t *testing.T) {
+	store, root := newTestStore(t)
+	modTime := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	// Uploads in a row may share a timestamp, on S3 or on filesystems with
+	// coarse ones, and each of them must still be kept
+	contents := []string{"A=1\n", "A=2\n", "A=3\n"}
+	for i, content := range contents {
+		if _, err := Upload(store, strings.NewReader(content), "app.env"); err != nil {
+			t.Fatalf("Upload %d: %v", i+1, err)
+		}
+		setModTime(t, root, "app.env", modTime)
+	}
+
+	versions, err := History(store, "app.env")
+	if err != nil {
+		t.Fatalf("History: %v", err)
+	}
+	if len(versions) != 3 {
+		t.Fatalf("History has %d versions, want 3", len(versions))
+	}
+	for i, version := range versions {
+		if version.Number != i+1 {
+			t.Errorf("version %d is numbered %d", i+1, version.Number)
+		}
+		if version.Current != (i == 2) {
+			t.Errorf("version %d current = %v", i+1, version.Current)
+		}
+		if !version.LastModified.Equal(modTime) {
+			t.Errorf("version %d was uploaded at %v, want %v", i+1, version.LastModified, modTime)
+		}
+
+		body, err := openVersion(store, version)
+		if err != nil {
+			t.Fatalf("openVersion %d: %v", i+1, err)
+		}
+		content, _ := io.ReadAll(body)
+		body.Close()
+		if string(content) != contents[i] {
+			t.Errorf("version %d = %q, want %q", i+1, content, contents[i])
+		}
+	}
+
+	// Versions are kept out of the listings
+	files, err := FindFiles(store, ListOptions{})
+	if err != nil {
+		t.Fatalf("FindFiles: %v", err)
+	}
+	if len(files) != 1 || files[0].Key != "app.env" {
+		t.Errorf("FindFiles = %v, want only app.env", files)
+	}
+
+	if err := Rollback(store, "app.env", 1); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	if got := getString(t, store, "app.env"); got != "A=1\n" {
+		t.Errorf("content after rollback = %q, want %q", got, "A=1\n")
+	}
+
+	versions, err = History(store, "app.env")
+	if err != nil {
+		t.Fatalf("History: %v", err)
+	}
+	if len(versions) != 4 {
+		t.Errorf("History after rollback has %d versions, want 4", len(versions))
+	}
+}
+
+func TestDeleteFileKeepsHistory(t *testing.T) {
+	store, _ := newTestStore(t)
+
+	if _, err := Upload(store, strings.NewReader("A=1\n"), "gone.env"); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+
+	if err := DeleteFile(store, "gone.env"); err != nil {
+		t.Fatalf("DeleteFile: %v", err)
+	}
+
+	if _, err := store.Stat("gone.env"); !IsNotFound(err) {
+		t.Fatalf("Stat after delete = %v, want not found", err)
+	}
+
+	versions, err := History(store, "gone.env")
+	if err != nil {
+		t.Fatalf("History: %v", err)
+	}
+	if len(versions) != 1 || versions[0].Current {
+		t.Fatalf("History = %+v, want one version that isn't current", versions)
+	}
+
+	if err := Rollback(store, "gone.env", 1); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	if got := getString(t, store, "gone.env"); got != "A=1\n" {
+		t.Errorf("content after rollback = %q, want %q", got, "A=1\n")
+	}
+
+	if err := DeleteFile(store, "never.env"); !IsNotFound(err) {
+		t.Errorf("DeleteFile missing = %v, want not found", err)
+	}
+}
+
+func TestRenameFileKeepsContent(t *testing.T) {
+	store, _ := newTestStore(t)
+
+	putString(t, store, "old.env", "A=1\n")
+
+	newKey, err := RenameFile(store, "old.env", "new", false)
+	if err != nil {
+		t.Fatalf("RenameFile: %v", err)
+	}
+	if newKey != "new.env" {
+		t.Errorf("RenameFile key = %s, want new.env", newKey)
+	}
+
+	if _, err := store.Stat("old.env"); !IsNotFound(err) {
+		t.Errorf("old.env still exists: %v", err)
+	}
+	if got := getString(t, store, "new.env"); got != "A=1\n" {
+		t.Errorf("new.env = %q, want %q", got, "A=1\n")
+	}
+}
+
+func TestRenameOntoExistingFile(t *testing.T) {
+	store, _ := newTestStore(t)
+
+	putString(t, store, "dev.env", "DEV=1\n")
+	putString(t, store, "prod.env", "PROD=1\n")
+
+	_, err := RenameFile(store, "dev.env", "prod.env", false)
+	if !errors.Is(err, ErrConflict) {
+		t.Fatalf("RenameFile onto prod.env = %v, want a conflict", err)
+	}
+	if got := getString(t, store, "prod.env"); got != "PROD=1\n" {
+		t.Errorf("prod.env = %q after a refused rename, want it untouched", got)
+	}
+	if got := getString(t, store, "dev.env"); got != "DEV=1\n" {
+		t.Errorf("dev.env = %q after a refused rename, want it untouched", got)
+	}
+
+	if _, err := RenameFile(store, "dev.env", "prod.env", true); err != nil {
+		t.Fatalf("RenameFile with overwrite: %v", err)
+	}
+	if got := getString(t, store, "prod.env"); got != "DEV=1\n" {
+		t.Errorf("prod.env = %q, want the content of dev.env", got)
+	}
+
+	// The replaced content can still be rolled back
+	versions, err := History(store, "prod.env")
+	if err != nil {
+		t.Fatalf("History: %v", err)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("History of prod.env has %d versions, want 2", len(versions))
+	}
+	if err := Rollback(store, "prod.env", 1); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	if got := getString(t, store, "prod.env"); got != "PROD=1\n" {
+		t.Errorf("prod.env after rollback = %q, want %q", got, "PROD=1\n")
+	}
+
+	if _, err := RenameFile(store, "prod.env", "prod", true); err == nil {
+		t.Error("renaming a file onto itself succeeded")
+	}
+}
+
+func TestRenameMovesHistory(t *testing.T) {
+	store, _ := newTestStore(t)
+
+	for _, content := range []string{"A=1\n", "A=2\n", "A=3\n"} {
+		if _, err := Upload(store, strings.NewReader(content), "old.env"); err != nil {
+			t.Fatalf("Upload: %v", err)
+		}
+	}
+
+	if _, err := RenameFile(store, "old.env", "new.env", false); err != nil {
+		t.Fatalf("RenameFile: %v", err)
+	}
+
+	if versions, err := History(store, "old.env"); err != nil || len(versions) != 0 {
+		t.Errorf("History of old.env = %d versions, %v, want none", len(versions), err)
+	}
+
+	versions, err := History(store, "new.env")
+	if err != nil {
+		t.Fatalf("History: %v", err)
+	}
+	if len(versions) != 3 {
+		t.Fatalf("History of new.env has %d versions, want 3", len(versions))
+	}
+	if err := Rollback(store, "new.env", 1); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	if got := getString(t, store, "new.env"); got != "A=1\n" {
+		t.Errorf("new.env after rollback = %q, want %q", got, "A=1\n")
+	}
+}
+
+func TestRenamePrefixChecksEveryDestination(t *testing.T) {
+	store, _ := newTestStore(t)
+
+	putString(t, store, "team/a.env", "A=1\n")
+	putString(t, store, "team/b.env", "B=1\n")
+	putString(t, store, "archive/b.env", "OLD=1\n")
+
+	_, err := RenamePrefix(store, "team/", "archive/", false)
+	if !errors.Is(err, ErrConflict) {
+		t.Fatalf("RenamePrefix = %v, want a conflict", err)
+	}
+	// Nothing was moved before the conflict was found
+	if got := getString(t, store, "team/a.env"); got != "A=1\n" {
+		t.Errorf("team/a.env = %q, want it untouched", got)
+	}
+
+	renamed, err := RenamePrefix(store, "team/", "archive/", true)
+	if err != nil {
+		t.Fatalf("RenamePrefix with overwrite: %v", err)
+	}
+	if len(renamed) != 2 {
+		t.Errorf("renamed = %v, want both files", renamed)
+	}
+	if got := getString(t, store, "archive/b.env"); got != "B=1\n" {
+		t.Errorf("archive/b.env = %q, want the content of team/b.env", got)
+	}
+	if versions, err := History(store, "archive/b.env"); err != nil || len(versions) != 2 {
+		t.Errorf("History of archive/b.env = %d versions, %v, want the replaced one kept", len(versions), err)
+	}
+}
@@ -0,0 +1,358 @@
+package bucket
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// seekableFile is a body whose parts can be read again, such as an *os.File,
+// so an interrupted multipart upload of it can be resumed
+type seekableFile interface {
+	io.ReaderAt
+	Stat() (fs.FileInfo, error)
+}
+
+// uploadPart is a slice of the uploaded file sent as one part
+type uploadPart struct {
+	number int64
+	offset int64
+	size   int64
+}
+
+// putResumable uploads file in parts, reusing the parts an unfinished upload
+// of key already sent when their content matches. A failed upload is left in
+// the bucket so that uploading the same file again resumes it.
+func (s3b *S3Bucket) putResumable(key string, file seekableFile, size int64, metadata map[string]string) (*ObjectInfo, error) {
+	parts := splitParts(size, s3b.partSize)
+
+	uploadID, uploaded, err := s3b.findUpload(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if uploadID == "" {
+		res, err := s3b.bucket.CreateMultipartUpload(&s3.CreateMultipartUploadInput{
+			Bucket:             aws.String(s3b.bucketName),
+			Key:                aws.String(key),
+			ACL:                aws.String("private"),
+			ContentDisposition: aws.String("attachment"),
+			ContentType:        aws.String("application/octet-stream"),
+			Metadata:           aws.StringMap(metadata),
+		})
+		if err != nil {
+			return nil, err
+		}
+		uploadID = aws.StringValue(res.UploadId)
+	} else {
+		fmt.Fprintf(Messages, "🚚 Resuming the unfinished upload of %s\n", key)
+	}
+
+	completed, err := s3b.uploadParts(key, uploadID, file, parts, uploaded)
+	if err != nil {
+		fmt.Fprintf(Messages, "🚧 %d of %d parts were uploaded, upload the same file again to resume\n", len(completed), len(parts))
+		return nil, err
+	}
+
+	sort.Slice(completed, func(i, j int) bool {
+		return aws.Int64Value(completed[i].PartNumber) < aws.Int64Value(completed[j].PartNumber)
+	})
+
+	res, err := s3b.bucket.CompleteMultipartUpload(&s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(s3b.bucketName),
+		Key:             aws.String(key),
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: completed},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ObjectInfo{
+		Key:       key,
+		Size:      size,
+		ETag:      aws.StringValue(res.ETag),
+		VersionID: aws.StringValue(res.VersionId),
+		Metadata:  metadata,
+	}, nil
+}
+
+// splitParts cuts size bytes in parts of partSize, larger when needed to stay
+// within the number of parts S3 accepts
+func splitParts(size, partSize int64) []uploadPart {
+	if minSize := (size + s3manager.MaxUploadParts - 1) / s3manager.MaxUploadParts; partSize < minSize {
+		partSize = minSize
+	}
+
+	var parts []uploadPart
+	for offset := int64(0); offset < size; offset += partSize {
+		parts = append(parts, uploadPart{
+			number: int64(len(parts) + 1),
+			offset: offset,
+			size:   min64(partSize, size-offset),
+		})
+	}
+
+	return parts
+}
+
+// findUpload returns the newest unfinished upload of key along with the parts
+// it already has, older ones are aborted since only one can be resumed
+func (s3b *S3Bucket) findUpload(key string) (string, map[int64]*s3.Part, error) {
+	var uploads []*s3.MultipartUpload
+
+	err := s3b.bucket.ListMultipartUploadsPages(&s3.ListMultipartUploadsInput{
+		Bucket: aws.String(s3b.bucketName),
+		Prefix: aws.String(key),
+	}, func(page *s3.ListMultipartUploadsOutput, lastPage bool) bool {
+		for _, upload := range page.Uploads {
+			// The prefix also matches longer keys
+			if aws.StringValue(upload.Key) == key {
+				uploads = append(uploads, upload)
+			}
+		}
+		return true
+	})
+	// Some S3-compatible services answer NoSuchUpload when there is none at all
+	if isAWSCode(err, s3.ErrCodeNoSuchUpload) {
+		return "", nil, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	if len(uploads) == 0 {
+		return "", nil, nil
+	}
+
+	sort.Slice(uploads, func(i, j int) bool {
+		return aws.TimeValue(uploads[i].Initiated).After(aws.TimeValue(uploads[j].Initiated))
+	})
+
+	for _, stale := range uploads[1:] {
+		s3b.bucket.AbortMultipartUpload(&s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(s3b.bucketName),
+			Key:      aws.String(key),
+			UploadId: stale.UploadId,
+		})
+	}
+
+	uploadID := aws.StringValue(uploads[0].UploadId)
+	uploaded := make(map[int64]*s3.Part)
+
+	err = s3b.bucket.ListPartsPages(&s3.ListPartsInput{
+		Bucket:   aws.String(s3b.bucketName),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	}, func(page *s3.ListPartsOutput, lastPage bool) bool {
+		for _, part := range page.Parts {
+			uploaded[aws.Int64Value(part.PartNumber)] = part
+		}
+		return true
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	return uploadID, uploaded, nil
+}
+
+// uploadParts sends the parts that aren't uploaded yet with as many requests
+// at once as the concurrency allows, and returns every part that made it
+func (s3b *S3Bucket) uploadParts(key, uploadID string, file seekableFile, parts []uploadPart, uploaded map[int64]*s3.Part) ([]*s3.CompletedPart, error) {
+	var (
+		mutex     sync.Mutex
+		wait      sync.WaitGroup
+		completed []*s3.CompletedPart
+		firstErr  error
+	)
+
+	queue := make(chan uploadPart)
+
+	for i := 0; i < s3b.concurrency; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+
+			for part := range queue {
+				etag, err := s3b.uploadPart(key, uploadID, file, part, uploaded[part.number])
+
+				mutex.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = fmt.Errorf("failed to upload part %d: %w", part.number, err)
+				}
+				if err == nil {
+					completed = append(completed, &s3.CompletedPart{ETag: aws.String(etag), PartNumber: aws.Int64(part.number)})
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	for _, part := range parts {
+		mutex.Lock()
+		failed := firstErr != nil
+		mutex.Unlock()
+
+		// Stop sending parts once one failed, the rest is left for the resume
+		if failed {
+			break
+		}
+		queue <- part
+	}
+	close(queue)
+	wait.Wait()
+
+	return completed, firstErr
+}
+
+// uploadPart sends part unless existing already holds the same content
+func (s3b *S3Bucket) uploadPart(key, uploadID string, file seekableFile, part uploadPart, existing *s3.Part) (string, error) {
+	section := io.NewSectionReader(file, part.offset, part.size)
+
+	if existing != nil && aws.Int64Value(existing.Size) == part.size {
+		hash := md5.New()
+		_, err := io.Copy(hash, section)
+		if err != nil {
+			return "", err
+		}
+
+		etag := `"` + hex.EncodeToString(hash.Sum(nil)) + `"`
+		if aws.StringValue(existing.ETag) == etag {
+			return etag, nil
+		}
+	}
+
+	res, err := s3b.bucket.UploadPart(&s3.UploadPartInput{
+		Bucket:        aws.String(s3b.bucketName),
+		Key:           aws.String(key),
+		UploadId:      aws.String(uploadID),
+		PartNumber:    aws.Int64(part.number),
+		Body:          io.NewSectionReader(file, part.offset, part.size),
+		ContentLength: aws.Int64(part.size),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return aws.StringValue(res.ETag), nil
+}
+
+// maxCopyObjectSize is the largest object a single copy request accepts
+const maxCopyObjectSize = 5 * 1024 * 1024 * 1024
+
+// copyPartSize is the size of the parts large objects are copied in
+const copyPartSize = 512 * 1024 * 1024
+
+// copyMultipart copies an object too large for a single copy request in
+// ranges, the upload is aborted when any of them fails
+func (s3b *S3Bucket) copyMultipart(srcKey, dstKey string, source *s3.HeadObjectOutput) error {
+	input := &s3.CreateMultipartUploadInput{
+		Bucket:             aws.String(s3b.bucketName),
+		Key:                aws.String(dstKey),
+		ACL:                aws.String("private"),
+		ContentDisposition: source.ContentDisposition,
+		ContentType:        source.ContentType,
+		Metadata:           source.Metadata,
+		StorageClass:       source.StorageClass,
+	}
+
+	if source.ServerSideEncryption != nil {
+		input.ServerSideEncryption = source.ServerSideEncryption
+		input.SSEKMSKeyId = source.SSEKMSKeyId
+	}
+
+	res, err := s3b.bucket.CreateMultipartUpload(input)
+	if err != nil {
+		return err
+	}
+	uploadID := res.UploadId
+
+	parts := splitParts(aws.Int64Value(source.ContentLength), copyPartSize)
+	completed := make([]*s3.CompletedPart, len(parts))
+
+	var (
+		mutex    sync.Mutex
+		wait     sync.WaitGroup
+		firstErr error
+	)
+
+	queue := make(chan uploadPart)
+
+	for i := 0; i < s3b.concurrency; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+
+			for part := range queue {
+				res, err := s3b.bucket.UploadPartCopy(&s3.UploadPartCopyInput{
+					Bucket:          aws.String(s3b.bucketName),
+					Key:             aws.String(dstKey),
+					UploadId:        uploadID,
+					PartNumber:      aws.Int64(part.number),
+					CopySource:      aws.String(s3b.copySource(srcKey)),
+					CopySourceRange: aws.String(fmt.Sprintf("bytes=%d-%d", part.offset, part.offset+part.size-1)),
+					// Make sure every range comes from the same object
+					CopySourceIfMatch: source.ETag,
+				})
+
+				mutex.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = fmt.Errorf("failed to copy part %d: %w", part.number, err)
+				}
+				if err == nil {
+					completed[part.number-1] = &s3.CompletedPart{ETag: res.CopyPartResult.ETag, PartNumber: aws.Int64(part.number)}
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	for _, part := range parts {
+		queue <- part
+	}
+	close(queue)
+	wait.Wait()
+
+	if firstErr == nil {
+		_, firstErr = s3b.bucket.CompleteMultipartUpload(&s3.CompleteMultipartUploadInput{
+			Bucket:          aws.String(s3b.bucketName),
+			Key:             aws.String(dstKey),
+			UploadId:        uploadID,
+			MultipartUpload: &s3.CompletedMultipartUpload{Parts: completed},
+		})
+	}
+
+	// Copies can't be resumed, don't leave their parts behind
+	if firstErr != nil {
+		s3b.bucket.AbortMultipartUpload(&s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(s3b.bucketName),
+			Key:      aws.String(dstKey),
+			UploadId: uploadID,
+		})
+	}
+
+	return firstErr
+}
+
+func isAWSCode(err error, code string) bool {
+	var awsErr awserr.Error
+	return errors.As(err, &awsErr) && awsErr.Code() == code
+}
+
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
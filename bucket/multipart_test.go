@@ -0,0 +1,78 @@
+package bucket
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+func TestSplitParts(t *testing.T) {
+	const mb = 1024 * 1024
+
+	tests := []struct {
+		name         string
+		size         int64
+		partSize     int64
+		wantParts    int
+		wantPartSize int64
+		wantLastSize int64
+	}{
+		{"empty file", 0, 5 * mb, 0, 0, 0},
+		{"smaller than a part", 1, 5 * mb, 1, 1, 1},
+		{"exactly one part", 5 * mb, 5 * mb, 1, 5 * mb, 5 * mb},
+		{"exact multiple", 15 * mb, 5 * mb, 3, 5 * mb, 5 * mb},
+		{"one byte over", 15*mb + 1, 5 * mb, 4, 5 * mb, 1},
+		{"remainder", 12 * mb, 5 * mb, 3, 5 * mb, 2 * mb},
+		{
+			name:         "at the part limit",
+			size:         s3manager.MaxUploadParts * 5 * mb,
+			partSize:     5 * mb,
+			wantParts:    s3manager.MaxUploadParts,
+			wantPartSize: 5 * mb,
+			wantLastSize: 5 * mb,
+		},
+		{
+			// One more byte no longer fits, the parts grow instead
+			name:         "over the part limit",
+			size:         s3manager.MaxUploadParts*5*mb + 1,
+			partSize:     5 * mb,
+			wantParts:    s3manager.MaxUploadParts,
+			wantPartSize: 5*mb + 1,
+			wantLastSize: 5*mb + 1 - s3manager.MaxUploadParts + 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parts := splitParts(tt.size, tt.partSize)
+
+			if len(parts) != tt.wantParts {
+				t.Fatalf("got %d parts, want %d", len(parts), tt.wantParts)
+			}
+			if len(parts) == 0 {
+				return
+			}
+
+			var offset int64
+			for i, part := range parts {
+				if part.number != int64(i+1) {
+					t.Fatalf("part %d is numbered %d", i+1, part.number)
+				}
+				if part.offset != offset {
+					t.Fatalf("part %d starts at %d, want %d", part.number, part.offset, offset)
+				}
+				if i < len(parts)-1 && part.size != tt.wantPartSize {
+					t.Fatalf("part %d has %d bytes, want %d", part.number, part.size, tt.wantPartSize)
+				}
+				offset += part.size
+			}
+
+			if offset != tt.size {
+				t.Errorf("parts cover %d bytes, want %d", offset, tt.size)
+			}
+			if last := parts[len(parts)-1].size; last != tt.wantLastSize {
+				t.Errorf("last part has %d bytes, want %d", last, tt.wantLastSize)
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+//go:build !windows
+
+package bucket
+
+import (
+	"io/fs"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// fileOwner names the user owning a file of the local store, or its uid when
+// the user is unknown to this machine
+func fileOwner(info fs.FileInfo) string {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return ""
+	}
+
+	uid := strconv.FormatUint(uint64(stat.Uid), 10)
+
+	owner, err := user.LookupId(uid)
+	if err != nil {
+		return uid
+	}
+	return owner.Username
+}
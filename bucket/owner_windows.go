@@ -0,0 +1,10 @@
+//go:build windows
+
+package bucket
+
+import "io/fs"
+
+// fileOwner is left empty on Windows, where files have no uid
+func fileOwner(info fs.FileInfo) string {
+	return ""
+}
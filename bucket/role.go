@@ -0,0 +1,168 @@
+package bucket
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// RoleOptions assumes an IAM role with the credentials of the session
+type RoleOptions struct {
+	RoleARN    string
+	ExternalID string
+	// SessionName shows up in CloudTrail, empty uses "denv"
+	SessionName string
+	// MFASerial is the MFA device the trust policy of the role asks for, its
+	// code comes from the MFATokenProvider of S3Options
+	MFASerial string
+	// Duration of the role credentials, zero uses one hour
+	Duration time.Duration
+	// STSEndpoint overrides the STS endpoint, e.g. LocalStack or moto
+	STSEndpoint string
+	// CacheDir keeps the role credentials until they expire, so the MFA code
+	// is only asked again then. Empty disables the cache
+	CacheDir string
+}
+
+// roleExpiryWindow renews the role credentials a bit before they expire
+const roleExpiryWindow = time.Minute
+
+// cachedRole is the cache file of role credentials
+type cachedRole struct {
+	AccessKeyID     string    `json:"access_key_id"`
+	SecretAccessKey string    `json:"secret_access_key"`
+	SessionToken    string    `json:"session_token"`
+	Expiration      time.Time `json:"expiration"`
+}
+
+// roleProvider assumes the role with STS unless the cache still holds
+// credentials for it
+type roleProvider struct {
+	credentials.Expiry
+	assume    *stscreds.AssumeRoleProvider
+	cachePath string
+}
+
+// newRoleCredentials returns the credentials of the role, using sess to call STS
+func newRoleCredentials(sess *session.Session, role RoleOptions, source string, tokenProvider func() (string, error)) *credentials.Credentials {
+	stsConfig := &aws.Config{}
+	if role.STSEndpoint != "" {
+		stsConfig.Endpoint = aws.String(role.STSEndpoint)
+	}
+	if aws.StringValue(sess.Config.Region) == "" {
+		stsConfig.Region = aws.String("us-east-1")
+	}
+
+	provider := &stscreds.AssumeRoleProvider{
+		Client:          sts.New(sess, stsConfig),
+		RoleARN:         role.RoleARN,
+		RoleSessionName: role.SessionName,
+		Duration:        role.Duration,
+		ExpiryWindow:    roleExpiryWindow,
+	}
+
+	if provider.RoleSessionName == "" {
+		provider.RoleSessionName = "denv"
+	}
+	if provider.Duration == 0 {
+		provider.Duration = time.Hour
+	}
+	if role.ExternalID != "" {
+		provider.ExternalID = aws.String(role.ExternalID)
+	}
+	if role.MFASerial != "" {
+		provider.SerialNumber = aws.String(role.MFASerial)
+		provider.TokenProvider = tokenProvider
+	}
+
+	cachePath := ""
+	if role.CacheDir != "" {
+		// Another role, or the same one reached from other credentials, is
+		// cached separately
+		hash := sha256.Sum256([]byte(strings.Join([]string{role.RoleARN, role.ExternalID, provider.RoleSessionName, role.MFASerial, source}, "\n")))
+		cachePath = filepath.Join(role.CacheDir, "role-"+hex.EncodeToString(hash[:8])+".json")
+	}
+
+	return credentials.NewCredentials(&roleProvider{assume: provider, cachePath: cachePath})
+}
+
+func (p *roleProvider) Retrieve() (credentials.Value, error) {
+	if cached, ok := p.readCache(); ok {
+		p.SetExpiration(cached.Expiration, roleExpiryWindow)
+		return credentials.Value{
+			AccessKeyID:     cached.AccessKeyID,
+			SecretAccessKey: cached.SecretAccessKey,
+			SessionToken:    cached.SessionToken,
+			ProviderName:    stscreds.ProviderName,
+		}, nil
+	}
+
+	value, err := p.assume.Retrieve()
+	if err != nil {
+		return value, err
+	}
+
+	// The provider already took the window off its expiration
+	expiration := p.assume.ExpiresAt().Add(roleExpiryWindow)
+	p.SetExpiration(expiration, roleExpiryWindow)
+
+	p.writeCache(cachedRole{
+		AccessKeyID:     value.AccessKeyID,
+		SecretAccessKey: value.SecretAccessKey,
+		SessionToken:    value.SessionToken,
+		Expiration:      expiration,
+	})
+
+	return value, nil
+}
+
+// readCache returns the cached credentials unless they are about to expire
+func (p *roleProvider) readCache() (cachedRole, bool) {
+	var cached cachedRole
+
+	if p.cachePath == "" {
+		return cached, false
+	}
+
+	content, err := os.ReadFile(p.cachePath)
+	if err != nil {
+		return cached, false
+	}
+
+	err = json.Unmarshal(content, &cached)
+	if err != nil || time.Until(cached.Expiration) < roleExpiryWindow {
+		return cached, false
+	}
+
+	return cached, true
+}
+
+// writeCache stores the credentials for the next runs, failing to do so only
+// means STS is asked again
+func (p *roleProvider) writeCache(cached cachedRole) {
+	if p.cachePath == "" {
+		return
+	}
+
+	content, err := json.Marshal(cached)
+	if err != nil {
+		return
+	}
+
+	err = os.MkdirAll(filepath.Dir(p.cachePath), 0700)
+	if err != nil {
+		return
+	}
+
+	os.WriteFile(p.cachePath, content, 0600)
+}
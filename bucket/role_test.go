@@ -0,0 +1,301 @@
+package bucket
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// fakeSTS answers AssumeRole with credentials valid for lifetime, numbered
+// after how many calls it got
+type fakeSTS struct {
+	mutex    sync.Mutex
+	calls    int
+	lastForm url.Values
+	lifetime time.Duration
+	// tokenCode is the MFA code the role asks for, empty asks for none
+	tokenCode string
+}
+
+func (f *fakeSTS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+
+	f.mutex.Lock()
+	f.calls++
+	calls := f.calls
+	f.lastForm = r.Form
+	f.mutex.Unlock()
+
+	if r.Form.Get("Action") != "AssumeRole" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if f.tokenCode != "" && r.Form.Get("TokenCode") != f.tokenCode {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `<ErrorResponse><Error><Type>Sender</Type><Code>AccessDenied</Code><Message>MultiFactorAuthentication failed</Message></Error><RequestId>1</RequestId></ErrorResponse>`)
+		return
+	}
+
+	expiration := time.Now().Add(f.lifetime).UTC().Format(time.RFC3339)
+	fmt.Fprintf(w, `<AssumeRoleResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleResult>
+    <Credentials>
+      <AccessKeyId>ASIA%d</AccessKeyId>
+      <SecretAccessKey>secret%d</SecretAccessKey>
+      <SessionToken>token%d</SessionToken>
+      <Expiration>%s</Expiration>
+    </Credentials>
+    <AssumedRoleUser>
+      <Arn>arn:aws:sts::123456789012:assumed-role/secrets/denv</Arn>
+      <AssumedRoleId>AROA:denv</AssumedRoleId>
+    </AssumedRoleUser>
+  </AssumeRoleResult>
+  <ResponseMetadata><RequestId>%d</RequestId></ResponseMetadata>
+</AssumeRoleResponse>`, calls, calls, calls, expiration, calls)
+}
+
+func (f *fakeSTS) callCount() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.calls
+}
+
+func (f *fakeSTS) form() url.Values {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.lastForm
+}
+
+func newFakeSTS(t *testing.T, fake *fakeSTS) string {
+	t.Helper()
+
+	if fake.lifetime == 0 {
+		fake.lifetime = time.Hour
+	}
+
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func newTestSession(t *testing.T) *session.Session {
+	t.Helper()
+
+	sess, err := session.NewSession(&aws.Config{
+		Region:      aws.String("us-east-1"),
+		Credentials: credentials.NewStaticCredentials("AKIA", "secret", ""),
+	})
+	if err != nil {
+		t.Fatalf("NewSession: %v", err)
+	}
+	return sess
+}
+
+func retrieveRole(t *testing.T, role RoleOptions, source string, tokenProvider func() (string, error)) credentials.Value {
+	t.Helper()
+
+	value, err := newRoleCredentials(newTestSession(t), role, source, tokenProvider).Get()
+	if err != nil {
+		t.Fatalf("retrieving the role credentials: %v", err)
+	}
+	return value
+}
+
+func cacheFiles(t *testing.T, dir string) []string {
+	t.Helper()
+
+	files, err := filepath.Glob(filepath.Join(dir, "role-*.json"))
+	if err != nil {
+		t.Fatalf("Glob: %v", err)
+	}
+	return files
+}
+
+func TestRoleCredentialsAreCached(t *testing.T) {
+	fake := &fakeSTS{}
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+	role := RoleOptions{
+		RoleARN:     "arn:aws:iam::123456789012:role/secrets",
+		ExternalID:  "external",
+		STSEndpoint: newFakeSTS(t, fake),
+		CacheDir:    cacheDir,
+	}
+
+	first := retrieveRole(t, role, "AKIA", nil)
+	if first.AccessKeyID != "ASIA1" || first.SessionToken != "token1" {
+		t.Fatalf("credentials = %+v, want the ones of the first AssumeRole", first)
+	}
+
+	if got := fake.form().Get("RoleSessionName"); got != "denv" {
+		t.Errorf("RoleSessionName = %q, want denv", got)
+	}
+	if got := fake.form().Get("DurationSeconds"); got != "3600" {
+		t.Errorf("DurationSeconds = %q, want 3600", got)
+	}
+	if got := fake.form().Get("ExternalId"); got != "external" {
+		t.Errorf("ExternalId = %q, want external", got)
+	}
+
+	files := cacheFiles(t, cacheDir)
+	if len(files) != 1 {
+		t.Fatalf("cache files = %v, want one", files)
+	}
+	for filePath, want := range map[string]os.FileMode{cacheDir: 0700, files[0]: 0600} {
+		stat, err := os.Stat(filePath)
+		if err != nil {
+			t.Fatalf("Stat: %v", err)
+		}
+		if stat.Mode().Perm() != want {
+			t.Errorf("%s has permissions %v, want %v", filePath, stat.Mode().Perm(), want)
+		}
+	}
+
+	// A later run reads the cache instead of asking STS again
+	second := retrieveRole(t, role, "AKIA", nil)
+	if fake.callCount() != 1 {
+		t.Errorf("STS was called %d times, want once", fake.callCount())
+	}
+	if second.AccessKeyID != first.AccessKeyID || second.SessionToken != first.SessionToken {
+		t.Errorf("cached credentials = %+v, want %+v", second, first)
+	}
+
+	// Other source credentials may not be allowed to assume the role
+	retrieveRole(t, role, "AKIA-OTHER", nil)
+	if fake.callCount() != 2 {
+		t.Errorf("STS was called %d times, want twice once the source changed", fake.callCount())
+	}
+	if files := cacheFiles(t, cacheDir); len(files) != 2 {
+		t.Errorf("cache files = %v, want one per source", files)
+	}
+}
+
+func TestRoleCacheExpiry(t *testing.T) {
+	fake := &fakeSTS{}
+	cacheDir := t.TempDir()
+	role := RoleOptions{
+		RoleARN:     "arn:aws:iam::123456789012:role/secrets",
+		STSEndpoint: newFakeSTS(t, fake),
+		CacheDir:    cacheDir,
+	}
+
+	retrieveRole(t, role, "AKIA", nil)
+
+	files := cacheFiles(t, cacheDir)
+	if len(files) != 1 {
+		t.Fatalf("cache files = %v, want one", files)
+	}
+
+	tests := []struct {
+		name      string
+		expiresIn time.Duration
+		wantCalls int
+	}{
+		{"valid", 30 * time.Minute, 0},
+		{"within the expiry window", roleExpiryWindow / 2, 1},
+		{"expired", -time.Hour, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cached := cachedRole{
+				AccessKeyID:     "ASIACACHED",
+				SecretAccessKey: "cached",
+				SessionToken:    "cached",
+				Expiration:      time.Now().Add(tt.expiresIn),
+			}
+			content, _ := json.Marshal(cached)
+			if err := os.WriteFile(files[0], content, 0600); err != nil {
+				t.Fatalf("WriteFile: %v", err)
+			}
+
+			before := fake.callCount()
+			value := retrieveRole(t, role, "AKIA", nil)
+
+			if calls := fake.callCount() - before; calls != tt.wantCalls {
+				t.Errorf("STS was called %d times, want %d", calls, tt.wantCalls)
+			}
+			if fromCache := value.AccessKeyID == "ASIACACHED"; fromCache != (tt.wantCalls == 0) {
+				t.Errorf("credentials = %s, want them from the cache %v", value.AccessKeyID, tt.wantCalls == 0)
+			}
+		})
+	}
+
+	// Unreadable caches are replaced
+	if err := os.WriteFile(files[0], []byte("not json"), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	before := fake.callCount()
+	retrieveRole(t, role, "AKIA", nil)
+	if fake.callCount() != before+1 {
+		t.Error("a corrupted cache was used")
+	}
+}
+
+func TestRoleWithoutCache(t *testing.T) {
+	fake := &fakeSTS{}
+	role := RoleOptions{
+		RoleARN:     "arn:aws:iam::123456789012:role/secrets",
+		STSEndpoint: newFakeSTS(t, fake),
+		Duration:    15 * time.Minute,
+	}
+
+	retrieveRole(t, role, "AKIA", nil)
+	retrieveRole(t, role, "AKIA", nil)
+
+	if fake.callCount() != 2 {
+		t.Errorf("STS was called %d times, want every time without a cache", fake.callCount())
+	}
+	if got := fake.form().Get("DurationSeconds"); got != "900" {
+		t.Errorf("DurationSeconds = %q, want 900", got)
+	}
+}
+
+func TestRoleWithMFA(t *testing.T) {
+	fake := &fakeSTS{tokenCode: "123456"}
+	cacheDir := t.TempDir()
+	role := RoleOptions{
+		RoleARN:     "arn:aws:iam::123456789012:role/secrets",
+		MFASerial:   "arn:aws:iam::123456789012:mfa/alice",
+		STSEndpoint: newFakeSTS(t, fake),
+		CacheDir:    cacheDir,
+	}
+
+	asked := 0
+	code := "000000"
+	tokenProvider := func() (string, error) {
+		asked++
+		return code, nil
+	}
+
+	_, err := newRoleCredentials(newTestSession(t), role, "AKIA", tokenProvider).Get()
+	if err == nil {
+		t.Fatal("a wrong MFA code was accepted")
+	}
+	if files := cacheFiles(t, cacheDir); len(files) != 0 {
+		t.Errorf("cache files = %v after a failure, want none", files)
+	}
+
+	code = "123456"
+	retrieveRole(t, role, "AKIA", tokenProvider)
+	if got := fake.form().Get("SerialNumber"); got != role.MFASerial {
+		t.Errorf("SerialNumber = %q, want %q", got, role.MFASerial)
+	}
+
+	// The code is only asked again once the cached credentials expire
+	retrieveRole(t, role, "AKIA", tokenProvider)
+	if asked != 2 {
+		t.Errorf("the MFA code was asked %d times, want 2", asked)
+	}
+}
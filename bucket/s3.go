@@ -1,230 +1,412 @@
 package bucket
 
 import (
-	"bytes"
+	"crypto/tls"
 	"fmt"
 	"io"
-	"log"
+	"net/http"
+	"net/url"
 	"os"
-	"path"
+	"sort"
 	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 )
 
 type S3Bucket struct {
 	bucket     *s3.S3
+	uploader   *s3manager.Uploader
 	bucketName string
+	// partSize and concurrency shape multipart uploads
+	partSize    int64
+	concurrency int
+	// versioning caches whether the bucket keeps versions, nil until asked
+	versioning *bool
 }
 
-func NewS3Bucket(accessKey, secretKey, bucketName, bucketRegion string) *S3Bucket {
-	sess := session.Must(session.NewSession(&aws.Config{
-		Region:      aws.String(bucketRegion),
-		Credentials: credentials.NewStaticCredentials(accessKey, secretKey, ""),
-	}))
+// S3Options configures the connection to AWS S3 or to any S3-compatible service
+// such as MinIO, Ceph, Cloudflare R2 or LocalStack
+type S3Options struct {
+	AccessKey    string
+	SecretKey    string
+	BucketName   string
+	BucketRegion string
+	// Endpoint overrides the AWS endpoint, e.g. http://localhost:9000
+	Endpoint string
+	// PathStyle addresses the bucket as endpoint/bucket instead of bucket.endpoint
+	PathStyle bool
+	// InsecureSkipVerify disables the TLS certificate verification
+	InsecureSkipVerify bool
+	// CABundle is the path of a PEM file with extra certificate authorities to trust
+	CABundle string
+	// PartSize is the size in bytes of each part of multipart uploads, files
+	// larger than it are uploaded in parts. Zero uses 5 MB, the minimum S3 allows
+	PartSize int64
+	// Concurrency is how many parts are uploaded at once, zero uses 5
+	Concurrency int
+	// UseDefaultCredentials ignores AccessKey and SecretKey and looks for credentials
+	// the way the AWS CLI does: environment variables, ~/.aws/credentials and
+	// ~/.aws/config profiles, web identity and the instance or task role
+	UseDefaultCredentials bool
+	// Profile is the shared config profile used with UseDefaultCredentials,
+	// empty uses AWS_PROFILE or the default one
+	Profile string
+	// MFATokenProvider asks for the MFA code of profiles with an mfa_serial,
+	// and of the Role when it has an MFASerial
+	MFATokenProvider func() (string, error)
+	// Role is assumed with the credentials above when it has a RoleARN
+	Role RoleOptions
+}
 
-	bucket := s3.New(sess)
+func NewS3Bucket(opts S3Options) (*S3Bucket, error) {
+	region := opts.BucketRegion
+	if region == "" && opts.Endpoint != "" {
+		// Most S3-compatible services ignore the region but the SDK requires one
+		region = "us-east-1"
+	}
 
-	s3Bucket := S3Bucket{
-		bucket:     bucket,
-		bucketName: bucketName,
+	awsConfig := &aws.Config{
+		S3ForcePathStyle: aws.Bool(opts.PathStyle),
 	}
 
-	return &s3Bucket
-}
+	// Without a region the shared config or AWS_REGION may provide one
+	if region != "" {
+		awsConfig.Region = aws.String(region)
+	}
 
-func (s3b *S3Bucket) UploadFile(filePath, targetName string) {
-	fmt.Println("🚚 Upload in progress...")
+	if !opts.UseDefaultCredentials {
+		awsConfig.Credentials = credentials.NewStaticCredentials(opts.AccessKey, opts.SecretKey, "")
+	}
 
-	file, err := os.Open(filePath)
-	if err != nil {
-		log.Fatalf("Failed to read file: %s", err.Error())
+	if opts.Endpoint != "" {
+		awsConfig.Endpoint = aws.String(opts.Endpoint)
 	}
-	defer file.Close()
 
-	fileStat, _ := file.Stat()
-	if fileStat.IsDir() {
-		fmt.Println("🚧 You can't upload a directory")
-		return
+	if opts.InsecureSkipVerify {
+		awsConfig.HTTPClient = &http.Client{
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+			},
+		}
+	}
+
+	sessionOptions := session.Options{Config: *awsConfig}
+
+	if opts.UseDefaultCredentials {
+		// Read ~/.aws/config too, it holds credential_process, SSO and roles
+		sessionOptions.SharedConfigState = session.SharedConfigEnable
+		sessionOptions.Profile = opts.Profile
+		sessionOptions.AssumeRoleTokenProvider = opts.MFATokenProvider
 	}
 
-	size := fileStat.Size()
-	buffer := make([]byte, size)
-	_, err = file.Read(buffer)
+	if opts.CABundle != "" {
+		caBundle, err := os.Open(opts.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %v", err)
+		}
+		defer caBundle.Close()
+
+		sessionOptions.CustomCABundle = caBundle
+	}
+
+	sess, err := session.NewSessionWithOptions(sessionOptions)
 	if err != nil {
-		log.Fatalf("Failed to read file content: %s", err.Error())
+		return nil, fmt.Errorf("failed to create S3 session: %v", err)
+	}
+
+	partSize := opts.PartSize
+	if partSize == 0 {
+		partSize = s3manager.DefaultUploadPartSize
+	}
+	if partSize < s3manager.MinUploadPartSize {
+		return nil, fmt.Errorf("the part size must be at least %d MB", s3manager.MinUploadPartSize/1024/1024)
+	}
+
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = s3manager.DefaultUploadConcurrency
+	}
+
+	var clientConfig []*aws.Config
+	if opts.Role.RoleARN != "" {
+		// Role credentials from other static keys or another profile are different
+		source := opts.AccessKey + "\n" + opts.Profile
+		roleCredentials := newRoleCredentials(sess, opts.Role, source, opts.MFATokenProvider)
+		clientConfig = append(clientConfig, &aws.Config{Credentials: roleCredentials})
+	}
+
+	client := s3.New(sess, clientConfig...)
+
+	s3Bucket := S3Bucket{
+		bucket: client,
+		uploader: s3manager.NewUploaderWithClient(client, func(uploader *s3manager.Uploader) {
+			uploader.PartSize = partSize
+			uploader.Concurrency = concurrency
+		}),
+		bucketName:  opts.BucketName,
+		partSize:    partSize,
+		concurrency: concurrency,
 	}
 
-	// Use the target name directly without modifying it
-	_, err = s3b.bucket.PutObject(&s3.PutObjectInput{
+	return &s3Bucket, nil
+}
+
+func (s3b *S3Bucket) Put(key string, body io.Reader, metadata map[string]string) (*ObjectInfo, error) {
+	// Large files are uploaded in parts that survive an interruption, streams
+	// such as the output of crypt.Store start over instead
+	if file, ok := body.(seekableFile); ok {
+		fileStat, err := file.Stat()
+		if err != nil {
+			return nil, s3Error(err)
+		}
+
+		if fileStat.Mode().IsRegular() && fileStat.Size() > s3b.partSize {
+			info, err := s3b.putResumable(key, file, fileStat.Size(), metadata)
+			return info, s3Error(err)
+		}
+	}
+
+	// Anything else is streamed, in parts once it outgrows a single one
+	res, err := s3b.uploader.Upload(&s3manager.UploadInput{
 		Bucket:             aws.String(s3b.bucketName),
-		Key:                aws.String(targetName),
-		Body:               bytes.NewReader(buffer),
+		Key:                aws.String(key),
+		Body:               body,
 		ACL:                aws.String("private"),
 		ContentDisposition: aws.String("attachment"),
 		ContentType:        aws.String("application/octet-stream"),
+		Metadata:           aws.StringMap(metadata),
 	})
-
 	if err != nil {
-		log.Fatalf("Failed to upload file to s3: %s", err.Error())
+		return nil, s3Error(err)
 	}
 
-	fmt.Println("🥳 Filed uploaded!!!")
+	return &ObjectInfo{
+		Key:       key,
+		ETag:      aws.StringValue(res.ETag),
+		VersionID: aws.StringValue(res.VersionID),
+		Metadata:  metadata,
+	}, nil
 }
 
-func (s3b *S3Bucket) DownloadFile(name, outputName string) {
-	fmt.Println("🚚 Download in progress...")
-
+func (s3b *S3Bucket) Get(key string) (io.ReadCloser, *ObjectInfo, error) {
 	res, err := s3b.bucket.GetObject(&s3.GetObjectInput{
 		Bucket: aws.String(s3b.bucketName),
-		Key:    aws.String(name),
+		Key:    aws.String(key),
 	})
-
-	if err != nil {
-		log.Fatalf("Failed to download the file: %s", err.Error())
-	}
-	defer res.Body.Close()
-
-	fileName := name
-	if outputName != "" {
-		fileName = outputName
-	}
-
-	file, err := os.Create(fileName)
 	if err != nil {
-		log.Fatalf("Failed to create env file: %s", err.Error())
+		return nil, nil, s3Error(err)
 	}
-	defer file.Close()
 
-	_, err = io.Copy(file, res.Body)
-	if err != nil {
-		log.Fatalf("Failed to download file: %s", err.Error())
+	info := &ObjectInfo{
+		Key:          key,
+		Size:         aws.Int64Value(res.ContentLength),
+		LastModified: aws.TimeValue(res.LastModified),
+		ETag:         aws.StringValue(res.ETag),
+		VersionID:    aws.StringValue(res.VersionId),
+		Metadata:     aws.StringValueMap(res.Metadata),
 	}
 
-	fmt.Println("🥳 Download succeed!!!")
+	return res.Body, info, nil
 }
 
-func (s3b *S3Bucket) ListFiles() {
-	fmt.Println("🚚 List in progress...")
-
-	files, err := s3b.getFilesList()
+func (s3b *S3Bucket) List(prefix string) ([]ObjectInfo, error) {
+	files := make([]ObjectInfo, 0)
+
+	// A page holds up to 1000 keys
+	err := s3b.bucket.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket:     aws.String(s3b.bucketName),
+		Prefix:     aws.String(prefix),
+		FetchOwner: aws.Bool(true),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, item := range page.Contents {
+			if item.Key == nil {
+				continue
+			}
+
+			files = append(files, ObjectInfo{
+				Key:          *item.Key,
+				Size:         aws.Int64Value(item.Size),
+				LastModified: aws.TimeValue(item.LastModified),
+				ETag:         aws.StringValue(item.ETag),
+				Owner:        ownerName(item.Owner),
+			})
+		}
+		return true
+	})
 	if err != nil {
-		log.Fatalf("Failed to list files: %s", err.Error())
+		return nil, s3Error(err)
 	}
 
-	fmt.Println("🥳 Files in the bucket:")
+	return files, nil
+}
 
-	if len(files.Contents) == 0 {
-		fmt.Println("No files found in the bucket.")
-		return
+// ownerName prefers the display name, which not every S3-compatible service sets
+func ownerName(owner *s3.Owner) string {
+	if owner == nil {
+		return ""
 	}
 
-	fmt.Printf("%-40s | %-20s\n", "File Name", "Last Modified")
-
-	for _, item := range files.Contents {
-		key := *item.Key
-		lastModified := item.LastModified.Format("2006-01-02 15:04:05")
-		fmt.Printf("%-40s | %-20s\n", key, lastModified)
+	if name := aws.StringValue(owner.DisplayName); name != "" {
+		return name
 	}
+	return aws.StringValue(owner.ID)
 }
 
-// getFilesList returns the raw S3 ListObjectsOutput
-func (s3b *S3Bucket) getFilesList() (*s3.ListObjectsOutput, error) {
-	return s3b.bucket.ListObjects(&s3.ListObjectsInput{
+func (s3b *S3Bucket) Delete(key string) error {
+	_, err := s3b.bucket.DeleteObject(&s3.DeleteObjectInput{
 		Bucket: aws.String(s3b.bucketName),
+		Key:    aws.String(key),
 	})
+
+	return s3Error(err)
 }
 
-// ListFileNames returns just the names of files in the bucket for use with autocomplete
-func (s3b *S3Bucket) ListFileNames() ([]string, error) {
-	res, err := s3b.getFilesList()
+// Copy duplicates the object inside the bucket, without downloading it, and
+// keeps its metadata and server-side encryption settings
+func (s3b *S3Bucket) Copy(srcKey, dstKey string) error {
+	source, err := s3b.bucket.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(s3b.bucketName),
+		Key:    aws.String(srcKey),
+	})
 	if err != nil {
-		return nil, err
+		return s3Error(err)
 	}
 
-	fileNames := make([]string, 0, len(res.Contents))
-	for _, item := range res.Contents {
-		if item.Key != nil {
-			fileNames = append(fileNames, *item.Key)
-		}
+	// A single copy request is limited to 5 GB
+	if aws.Int64Value(source.ContentLength) > maxCopyObjectSize {
+		return s3Error(s3b.copyMultipart(srcKey, dstKey, source))
 	}
 
-	return fileNames, nil
+	input := &s3.CopyObjectInput{
+		Bucket:            aws.String(s3b.bucketName),
+		Key:               aws.String(dstKey),
+		CopySource:        aws.String(s3b.copySource(srcKey)),
+		ACL:               aws.String("private"),
+		MetadataDirective: aws.String(s3.MetadataDirectiveCopy),
+		StorageClass:      source.StorageClass,
+	}
+
+	if source.ServerSideEncryption != nil {
+		input.ServerSideEncryption = source.ServerSideEncryption
+		input.SSEKMSKeyId = source.SSEKMSKeyId
+	}
+
+	_, err = s3b.bucket.CopyObject(input)
+	return s3Error(err)
 }
 
-func (s3b *S3Bucket) DeleteFile(name string) {
-	fmt.Println("🚚 Delete in progress...")
+// copySource is the URL encoded bucket and key copy requests read from
+func (s3b *S3Bucket) copySource(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
 
-	_, err := s3b.bucket.DeleteObject(&s3.DeleteObjectInput{
+	return s3b.bucketName + "/" + strings.Join(segments, "/")
+}
+
+func (s3b *S3Bucket) Stat(key string) (*ObjectInfo, error) {
+	res, err := s3b.bucket.HeadObject(&s3.HeadObjectInput{
 		Bucket: aws.String(s3b.bucketName),
-		Key:    aws.String(name),
+		Key:    aws.String(key),
 	})
-
 	if err != nil {
-		log.Fatalf("Failed to delete file: %s", err.Error())
+		return nil, s3Error(err)
 	}
 
-	fmt.Println("🥳 File deleted!!!")
+	return &ObjectInfo{
+		Key:                  key,
+		Size:                 aws.Int64Value(res.ContentLength),
+		LastModified:         aws.TimeValue(res.LastModified),
+		ETag:                 aws.StringValue(res.ETag),
+		VersionID:            aws.StringValue(res.VersionId),
+		Metadata:             aws.StringValueMap(res.Metadata),
+		ServerSideEncryption: aws.StringValue(res.ServerSideEncryption),
+	}, nil
 }
 
-func (s3b *S3Bucket) RenameFile(oldName, newName string) {
-	fmt.Println("🚚 Rename in progress...")
+func (s3b *S3Bucket) VersioningEnabled() (bool, error) {
+	if s3b.versioning != nil {
+		return *s3b.versioning, nil
+	}
 
-	// First, copy the file with the new name
-	res, err := s3b.bucket.GetObject(&s3.GetObjectInput{
+	res, err := s3b.bucket.GetBucketVersioning(&s3.GetBucketVersioningInput{
 		Bucket: aws.String(s3b.bucketName),
-		Key:    aws.String(oldName),
 	})
-
 	if err != nil {
-		log.Fatalf("Failed to find file %s: %s", oldName, err.Error())
+		return false, s3Error(err)
 	}
-	defer res.Body.Close()
 
-	// Extract file extension from the old name
-	ext := path.Ext(oldName)
-	var newKey string
+	// Suspended buckets keep the old versions but don't create new ones
+	enabled := aws.StringValue(res.Status) == s3.BucketVersioningStatusEnabled
+	s3b.versioning = &enabled
 
-	// If old name has an extension, use it for the new name
-	if ext != "" {
-		baseName := strings.TrimSuffix(newName, ext)
-		newKey = baseName + ext
-	} else {
-		newKey = newName
-	}
+	return enabled, nil
+}
 
-	// Read the entire body
-	bodyBytes, err := io.ReadAll(res.Body)
-	if err != nil {
-		log.Fatalf("Failed to read file contents: %s", err.Error())
-	}
+func (s3b *S3Bucket) ListVersions(key string) ([]ObjectInfo, error) {
+	var versions []ObjectInfo
 
-	// Copy object to the new key
-	_, err = s3b.bucket.PutObject(&s3.PutObjectInput{
-		Bucket:             aws.String(s3b.bucketName),
-		Key:                aws.String(newKey),
-		Body:               bytes.NewReader(bodyBytes),
-		ACL:                aws.String("private"),
-		ContentDisposition: aws.String("attachment"),
-		ContentType:        aws.String("application/octet-stream"),
+	err := s3b.bucket.ListObjectVersionsPages(&s3.ListObjectVersionsInput{
+		Bucket: aws.String(s3b.bucketName),
+		Prefix: aws.String(key),
+	}, func(page *s3.ListObjectVersionsOutput, lastPage bool) bool {
+		for _, version := range page.Versions {
+			// The prefix also matches longer keys
+			if aws.StringValue(version.Key) != key {
+				continue
+			}
+
+			versions = append(versions, ObjectInfo{
+				Key:          key,
+				Size:         aws.Int64Value(version.Size),
+				LastModified: aws.TimeValue(version.LastModified),
+				ETag:         aws.StringValue(version.ETag),
+				VersionID:    aws.StringValue(version.VersionId),
+			})
+		}
+		return true
 	})
-
 	if err != nil {
-		log.Fatalf("Failed to rename file to %s: %s", newKey, err.Error())
+		return nil, s3Error(err)
 	}
 
-	// Delete the old object
-	_, err = s3b.bucket.DeleteObject(&s3.DeleteObjectInput{
-		Bucket: aws.String(s3b.bucketName),
-		Key:    aws.String(oldName),
+	// S3 lists the newest version first, reverse it and keep that order for
+	// versions uploaded within the same second
+	for i, j := 0, len(versions)-1; i < j; i, j = i+1, j-1 {
+		versions[i], versions[j] = versions[j], versions[i]
+	}
+
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].LastModified.Before(versions[j].LastModified)
 	})
 
+	return versions, nil
+}
+
+func (s3b *S3Bucket) GetVersion(key, versionID string) (io.ReadCloser, *ObjectInfo, error) {
+	res, err := s3b.bucket.GetObject(&s3.GetObjectInput{
+		Bucket:    aws.String(s3b.bucketName),
+		Key:       aws.String(key),
+		VersionId: aws.String(versionID),
+	})
 	if err != nil {
-		log.Fatalf("Failed to delete original file after rename: %s", err.Error())
+		return nil, nil, s3Error(err)
+	}
+
+	info := &ObjectInfo{
+		Key:          key,
+		Size:         aws.Int64Value(res.ContentLength),
+		LastModified: aws.TimeValue(res.LastModified),
+		ETag:         aws.StringValue(res.ETag),
+		VersionID:    aws.StringValue(res.VersionId),
+		Metadata:     aws.StringValueMap(res.Metadata),
 	}
 
-	fmt.Printf("🥳 File renamed from %s to %s!!!\n", oldName, newKey)
+	return res.Body, info, nil
 }
@@ -0,0 +1,187 @@
+package bucket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// fakeS3 answers HEAD requests for every key and records what was asked
+type fakeS3 struct {
+	mutex    sync.Mutex
+	requests []*http.Request
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	f.requests = append(f.requests, r)
+	f.mutex.Unlock()
+
+	if r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	if strings.HasSuffix(r.URL.Path, "/missing.env") {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Length", "4")
+	w.Header().Set("ETag", `"etag"`)
+	w.Header().Set("Last-Modified", "Mon, 01 Jan 2024 10:00:00 GMT")
+	w.Header().Set("x-amz-server-side-encryption", "aws:kms")
+	w.WriteHeader(http.StatusOK)
+}
+
+func (f *fakeS3) lastRequest(t *testing.T) *http.Request {
+	t.Helper()
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if len(f.requests) == 0 {
+		t.Fatal("no request reached the endpoint")
+	}
+	return f.requests[len(f.requests)-1]
+}
+
+func newTestS3Bucket(t *testing.T, opts S3Options) *S3Bucket {
+	t.Helper()
+
+	if opts.AccessKey == "" {
+		opts.AccessKey, opts.SecretKey = "access", "secret"
+	}
+	if opts.BucketName == "" {
+		opts.BucketName = "bkt"
+	}
+
+	s3b, err := NewS3Bucket(opts)
+	if err != nil {
+		t.Fatalf("NewS3Bucket: %v", err)
+	}
+	return s3b
+}
+
+func TestS3PathStyleEndpoint(t *testing.T) {
+	fake := &fakeS3{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	s3b := newTestS3Bucket(t, S3Options{Endpoint: server.URL, PathStyle: true})
+
+	info, err := s3b.Stat("team/dev env.env")
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if info.Size != 4 || info.ETag != `"etag"` || info.ServerSideEncryption != "aws:kms" {
+		t.Errorf("Stat = %+v, want the size, ETag and encryption of the response", info)
+	}
+
+	request := fake.lastRequest(t)
+	if request.URL.Path != "/bkt/team/dev env.env" {
+		t.Errorf("path = %q, want the bucket in the path", request.URL.Path)
+	}
+	if !strings.Contains(request.Header.Get("Authorization"), "/us-east-1/s3/") {
+		t.Errorf("Authorization = %q, want it signed for us-east-1 when no region is set", request.Header.Get("Authorization"))
+	}
+
+	_, err = s3b.Stat("missing.env")
+	if !IsNotFound(err) {
+		t.Errorf("Stat missing = %v, want not found", err)
+	}
+}
+
+func TestS3VirtualHostedStyle(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      S3Options
+		wantHost  string
+		wantPath  string
+		wantHTTPS bool
+	}{
+		{
+			name:      "AWS",
+			opts:      S3Options{BucketRegion: "eu-west-1"},
+			wantHost:  "bkt.s3.eu-west-1.amazonaws.com",
+			wantPath:  "/dev.env",
+			wantHTTPS: true,
+		},
+		{
+			name:     "custom endpoint",
+			opts:     S3Options{Endpoint: "http://minio.local:9000"},
+			wantHost: "bkt.minio.local:9000",
+			wantPath: "/dev.env",
+		},
+		{
+			name:     "custom endpoint with path-style",
+			opts:     S3Options{Endpoint: "http://minio.local:9000", PathStyle: true},
+			wantHost: "minio.local:9000",
+			wantPath: "/bkt/dev.env",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s3b := newTestS3Bucket(t, tt.opts)
+
+			// Building the request resolves the URL without sending anything
+			request, _ := s3b.bucket.HeadObjectRequest(&s3.HeadObjectInput{
+				Bucket: aws.String("bkt"),
+				Key:    aws.String("dev.env"),
+			})
+			if err := request.Build(); err != nil {
+				t.Fatalf("Build: %v", err)
+			}
+
+			url := request.HTTPRequest.URL
+			if url.Host != tt.wantHost || url.Path != tt.wantPath {
+				t.Errorf("URL = %s, want host %s and path %s", url, tt.wantHost, tt.wantPath)
+			}
+			if (url.Scheme == "https") != tt.wantHTTPS {
+				t.Errorf("scheme = %s, want https %v", url.Scheme, tt.wantHTTPS)
+			}
+		})
+	}
+}
+
+func TestS3CABundle(t *testing.T) {
+	_, err := NewS3Bucket(S3Options{Endpoint: "https://localhost:9000", CABundle: "/nonexistent/ca.pem"})
+	if err == nil {
+		t.Error("a missing CA bundle was accepted")
+	}
+}
+
+func TestS3PartSize(t *testing.T) {
+	s3b := newTestS3Bucket(t, S3Options{Endpoint: "http://localhost:9000"})
+	if s3b.partSize != 5*1024*1024 || s3b.concurrency != 5 {
+		t.Errorf("defaults = %d bytes and %d parts at once, want 5 MB and 5", s3b.partSize, s3b.concurrency)
+	}
+
+	_, err := NewS3Bucket(S3Options{Endpoint: "http://localhost:9000", PartSize: 1024 * 1024})
+	if err == nil {
+		t.Error("a 1 MB part size was accepted, S3 needs at least 5 MB")
+	}
+}
+
+func TestCopySource(t *testing.T) {
+	s3b := &S3Bucket{bucketName: "bkt"}
+
+	tests := map[string]string{
+		"dev.env":            "bkt/dev.env",
+		"team/dev env.env":   "bkt/team/dev%20env.env",
+		"a+b/100%/é.env":     "bkt/a+b/100%25/%C3%A9.env",
+		"nested/dir/app.env": "bkt/nested/dir/app.env",
+	}
+
+	for key, want := range tests {
+		if got := s3b.copySource(key); got != want {
+			t.Errorf("copySource(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package bucket
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+	"time"
+)
+
+// ObjectInfo describes a stored object independently of the backend holding it
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+	ETag         string
+	VersionID    string
+	Metadata     map[string]string
+	// Owner is who stored the object, only known when listing
+	Owner string
+	// ServerSideEncryption is how S3 encrypts the object, e.g. aws:kms
+	ServerSideEncryption string
+}
+
+// Store is the set of primitive operations every storage backend provides.
+// The upload, download, list, delete and rename flows are built on top of it,
+// so any backend implementing Store can be selected from the config.
+type Store interface {
+	// Put writes body under key, replacing any existing object
+	Put(key string, body io.Reader, metadata map[string]string) (*ObjectInfo, error)
+	// Get opens the object stored under key, the caller must close the body
+	Get(key string) (io.ReadCloser, *ObjectInfo, error)
+	// List returns every object whose key starts with prefix
+	List(prefix string) ([]ObjectInfo, error)
+	// Delete removes the object stored under key
+	Delete(key string) error
+	// Copy duplicates the object stored under srcKey to dstKey
+	Copy(srcKey, dstKey string) error
+	// Stat returns the object information without reading its content
+	Stat(key string) (*ObjectInfo, error)
+}
+
+// InternalPrefix holds the objects denv keeps for itself, they are hidden from listings
+const InternalPrefix = ".denv/"
+
+// IsInternal tells whether key belongs to denv itself instead of the user
+func IsInternal(key string) bool {
+	return strings.HasPrefix(key, InternalPrefix)
+}
+
+// IsNotFound tells whether err means the requested object doesn't exist
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound) || errors.Is(err, fs.ErrNotExist)
+}
@@ -0,0 +1,381 @@
+package bucket
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// versionsPrefix holds the previous versions of every file when the backend
+// has no versioning of its own
+const versionsPrefix = InternalPrefix + "versions/"
+
+// versionTimeFormat sorts lexicographically in chronological order
+const versionTimeFormat = "20060102T150405.000000000Z"
+
+// versionSequenceFormat tells apart versions archived with the same timestamp,
+// it is padded so that the names keep sorting in order
+const versionSequenceFormat = "%s-%04d"
+
+// Versioner is implemented by backends with native object versioning, such as
+// S3 buckets with versioning enabled
+type Versioner interface {
+	// VersioningEnabled tells whether new versions are currently being kept
+	VersioningEnabled() (bool, error)
+	// ListVersions returns every version of key, oldest first
+	ListVersions(key string) ([]ObjectInfo, error)
+	// GetVersion opens a specific version of key, the caller must close the body
+	GetVersion(key, versionID string) (io.ReadCloser, *ObjectInfo, error)
+}
+
+// Version is one entry of a file history, numbered from 1 for the oldest one
+type Version struct {
+	ObjectInfo
+	Number  int
+	Current bool
+	// versionKey is where a denv-managed version is stored
+	versionKey string
+}
+
+// nativeVersioner returns the store as a Versioner when it keeps versions by itself
+func nativeVersioner(store Store) (Versioner, bool, error) {
+	versioner, ok := store.(Versioner)
+	if !ok {
+		return nil, false, nil
+	}
+
+	enabled, err := versioner.VersioningEnabled()
+	if err != nil {
+		return nil, false, err
+	}
+
+	return versioner, enabled, nil
+}
+
+// archiveVersion keeps a copy of the current object before it is overwritten,
+// unless the backend already does it
+func archiveVersion(store Store, key string) error {
+	_, native, err := nativeVersioner(store)
+	if err != nil || native {
+		return err
+	}
+
+	current, err := store.Stat(key)
+	if IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	versionKey, err := unusedVersionKey(store, key, current.LastModified.UTC().Format(versionTimeFormat))
+	if err != nil {
+		return err
+	}
+
+	return store.Copy(key, versionKey)
+}
+
+// unusedVersionKey names a version of key after versionName, uploads in a row
+// may share a timestamp so an existing version is never overwritten
+func unusedVersionKey(store Store, key, versionName string) (string, error) {
+	firstKey := versionsPrefix + key + "/" + versionName
+
+	versionKey := firstKey
+	for sequence := 2; ; sequence++ {
+		_, err := store.Stat(versionKey)
+		if IsNotFound(err) {
+			return versionKey, nil
+		}
+		if err != nil {
+			return "", err
+		}
+
+		versionKey = fmt.Sprintf(versionSequenceFormat, firstKey, sequence)
+	}
+}
+
+// versionTimestamp strips the sequence number of a version name
+func versionTimestamp(versionName string) string {
+	if index := strings.LastIndex(versionName, "-"); index != -1 {
+		return versionName[:index]
+	}
+	return versionName
+}
+
+// moveHistory moves the versions denv keeps of oldKey to newKey, backends
+// versioning objects themselves keep the history of the old key instead
+func moveHistory(store Store, oldKey, newKey string) error {
+	_, native, err := nativeVersioner(store)
+	if err != nil || native {
+		return err
+	}
+
+	prefix := versionsPrefix + oldKey + "/"
+
+	objects, err := store.List(prefix)
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].Key < objects[j].Key
+	})
+
+	for _, object := range objects {
+		versionName := strings.TrimPrefix(object.Key, prefix)
+
+		// Versions of nested keys such as "a/b" show up when listing "a"
+		if strings.Contains(versionName, "/") {
+			continue
+		}
+
+		versionKey, err := unusedVersionKey(store, newKey, versionTimestamp(versionName))
+		if err != nil {
+			return err
+		}
+
+		err = store.Copy(object.Key, versionKey)
+		if err != nil {
+			return err
+		}
+
+		err = store.Delete(object.Key)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// History lists every version of key, oldest first, the last one being the current file
+func History(store Store, key string) ([]Version, error) {
+	versioner, native, err := nativeVersioner(store)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []Version
+
+	if native {
+		objects, err := versioner.ListVersions(key)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, object := range objects {
+			versions = append(versions, Version{ObjectInfo: object})
+		}
+	} else {
+		versions, err = managedHistory(store, key)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range versions {
+		versions[i].Number = i + 1
+	}
+
+	// A deleted file keeps its history but has no current version
+	if len(versions) > 0 {
+		_, err := store.Stat(key)
+		if err != nil && !IsNotFound(err) {
+			return nil, err
+		}
+		versions[len(versions)-1].Current = err == nil
+	}
+
+	return versions, nil
+}
+
+func managedHistory(store Store, key string) ([]Version, error) {
+	prefix := versionsPrefix + key + "/"
+
+	objects, err := store.List(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].Key < objects[j].Key
+	})
+
+	versions := make([]Version, 0, len(objects)+1)
+	for _, object := range objects {
+		versionName := strings.TrimPrefix(object.Key, prefix)
+
+		// Versions of nested keys such as "a/b" show up when listing "a"
+		if strings.Contains(versionName, "/") {
+			continue
+		}
+
+		// The version name is when that content was uploaded
+		if uploadedAt, err := time.Parse(versionTimeFormat, versionTimestamp(versionName)); err == nil {
+			object.LastModified = uploadedAt
+		}
+
+		versionKey := object.Key
+		object.Key = key
+		versions = append(versions, Version{ObjectInfo: object, versionKey: versionKey})
+	}
+
+	current, err := store.Stat(key)
+	if err != nil && !IsNotFound(err) {
+		return nil, err
+	}
+	if current != nil {
+		versions = append(versions, Version{ObjectInfo: *current, versionKey: key})
+	}
+
+	return versions, nil
+}
+
+// getVersion opens the content of version number of key
+func getVersion(store Store, key string, number int) (io.ReadCloser, *Version, error) {
+	versions, err := History(store, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if number < 1 || number > len(versions) {
+		return nil, nil, classified(ErrNotFound, fmt.Errorf("version %d of %s not found, it has %d version(s)", number, key, len(versions)))
+	}
+
+	version := versions[number-1]
+	body, err := openVersion(store, version)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return body, &version, nil
+}
+
+func openVersion(store Store, version Version) (io.ReadCloser, error) {
+	if version.versionKey != "" {
+		body, _, err := store.Get(version.versionKey)
+		return body, err
+	}
+
+	body, _, err := store.(Versioner).GetVersion(version.Key, version.VersionID)
+	return body, err
+}
+
+// ReadMatchingVersion reads the version of key with versionID, or with etag
+// when either side has no version ID, and reports whether one was found
+func ReadMatchingVersion(store Store, key, etag, versionID string) ([]byte, bool, error) {
+	versions, err := History(store, key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// The version looked for is usually one of the latest
+	for i := len(versions) - 1; i >= 0; i-- {
+		version := versions[i]
+
+		matches := version.ETag == etag
+		if versionID != "" && version.VersionID != "" {
+			matches = version.VersionID == versionID
+		}
+
+		if !matches {
+			continue
+		}
+
+		body, err := openVersion(store, version)
+		if err != nil {
+			return nil, false, err
+		}
+		defer body.Close()
+
+		content, err := io.ReadAll(body)
+		if err != nil {
+			return nil, false, err
+		}
+
+		return content, true, nil
+	}
+
+	return nil, false, nil
+}
+
+func PrintHistory(store Store, key string) error {
+	fmt.Fprintln(Messages, "🚚 History in progress...")
+
+	versions, err := History(store, key)
+	if err != nil {
+		return fmt.Errorf("failed to read the history: %w", err)
+	}
+
+	if len(versions) == 0 {
+		return classified(ErrNotFound, fmt.Errorf("no versions found for %s", key))
+	}
+
+	fmt.Fprintf(Messages, "🥳 Versions of %s:\n", key)
+	fmt.Printf("%-8s | %-20s | %-10s\n", "Version", "Last Modified", "Size")
+
+	for _, version := range versions {
+		number := fmt.Sprint(version.Number)
+		if version.Current {
+			number += " *"
+		}
+
+		lastModified := version.LastModified.Format("2006-01-02 15:04:05")
+		fmt.Printf("%-8s | %-20s | %-10d\n", number, lastModified, version.Size)
+	}
+
+	return nil
+}
+
+// DownloadVersion saves version number of name to outputName and returns it
+func DownloadVersion(store Store, name string, number int, outputName string) (*Version, error) {
+	fmt.Fprintln(Messages, "🚚 Download in progress...")
+
+	body, version, err := getVersion(store, name, number)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download the file: %w", err)
+	}
+	defer body.Close()
+
+	err = saveFile(body, name, outputName)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(Messages, "🥳 Version %d downloaded!!!\n", number)
+	return version, nil
+}
+
+// Rollback uploads an old version again, so the rollback itself becomes a new version
+func Rollback(store Store, name string, number int) error {
+	fmt.Fprintln(Messages, "🚚 Rollback in progress...")
+
+	body, _, err := getVersion(store, name, number)
+	if err != nil {
+		return fmt.Errorf("failed to read version %d: %w", number, err)
+	}
+
+	// Read it all before the current file is touched, the version may be the current file
+	content, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read version %d: %w", number, err)
+	}
+
+	err = archiveVersion(store, name)
+	if err != nil {
+		return fmt.Errorf("failed to keep the current version: %w", err)
+	}
+
+	_, err = store.Put(name, bytes.NewReader(content), nil)
+	if err != nil {
+		return fmt.Errorf("failed to restore version %d: %w", number, err)
+	}
+
+	fmt.Fprintf(Messages, "🥳 %s rolled back to version %d!!!\n", name, number)
+	return nil
+}
@@ -0,0 +1,238 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/robertokbr/denv/agent"
+	"github.com/robertokbr/denv/config"
+)
+
+// masterSecret is the name the agent keeps the master passphrase under
+const masterSecret = "master"
+
+// defaultAgentTTL is how long the agent keeps secrets without --ttl
+const defaultAgentTTL = 15 * time.Minute
+
+func newAgentCommand(cli *CLI) Command {
+	flags := flag.NewFlagSet("agent", flag.ContinueOnError)
+	ttl := flags.Duration("ttl", agentTTL(), "How long passphrases are kept, e.g. 1h")
+	exitWhenIdle := flags.Bool("exit-when-idle", false, "Stop once no passphrase is kept anymore")
+
+	return Command{
+		Name:        "agent",
+		Usage:       "denv agent start|stop|status|lock [--ttl 15m]",
+		Description: "Keep typed passphrases in memory for a while, so they are only asked once",
+		Flags:       flags,
+		Execute: func() error {
+			args, err := parseArgs(flags, cli.args)
+			if err != nil {
+				return err
+			}
+
+			if len(args) != 1 {
+				return printAgentUsage()
+			}
+
+			switch args[0] {
+			case "start":
+				return cli.handleAgentStart(*ttl)
+			case "stop":
+				return cli.handleAgentStop()
+			case "status":
+				return cli.handleAgentStatus()
+			case "lock":
+				return cli.handleAgentLock()
+			case "serve":
+				// The agent process itself, also handy under systemd or launchd
+				return agent.Serve(config.AgentSocketPath, agent.Options{TTL: *ttl, ExitWhenIdle: *exitWhenIdle})
+			default:
+				return printAgentUsage()
+			}
+		},
+	}
+}
+
+func printAgentUsage() error {
+	fmt.Fprintln(notices, "🌝 Usage:")
+	fmt.Fprintln(notices, "denv agent start [--ttl 15m] to keep typed passphrases in memory for a while")
+	fmt.Fprintln(notices, "denv agent status to see which passphrases are kept and until when")
+	fmt.Fprintln(notices, "denv agent lock to forget every passphrase right away")
+	fmt.Fprintln(notices, "denv agent stop to forget every passphrase and stop the agent")
+	fmt.Fprintln(notices, "denv agent serve [--ttl 15m] to run the agent in the foreground")
+	return &usageError{message: "wrong usage of denv agent"}
+}
+
+// agentTTL is DENV_AGENT_TTL when it holds a duration such as 1h
+func agentTTL() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("DENV_AGENT_TTL"))
+	if err != nil || ttl <= 0 {
+		return defaultAgentTTL
+	}
+	return ttl
+}
+
+func (cli *CLI) handleAgentStart(ttl time.Duration) error {
+	if agent.Running(config.AgentSocketPath) {
+		fmt.Fprintln(messages, "🤓 The agent is already running")
+		return nil
+	}
+
+	err := startAgent(ttl)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(messages, "🥳 Agent started, passphrases are kept for %s!!!\n", ttl)
+	return nil
+}
+
+func (cli *CLI) handleAgentStop() error {
+	if !agent.Running(config.AgentSocketPath) {
+		fmt.Fprintln(messages, "🤓 The agent isn't running")
+		return nil
+	}
+
+	err := agent.Stop(config.AgentSocketPath)
+	if err != nil {
+		return fmt.Errorf("failed to stop the agent: %w", err)
+	}
+
+	fmt.Fprintln(messages, "🥳 Agent stopped, every passphrase was forgotten")
+	return nil
+}
+
+func (cli *CLI) handleAgentStatus() error {
+	running := agent.Running(config.AgentSocketPath)
+
+	var entries []agent.Entry
+	if running {
+		var err error
+		entries, err = agent.List(config.AgentSocketPath)
+		if err != nil {
+			return fmt.Errorf("failed to reach the agent: %w", err)
+		}
+	}
+
+	if cli.jsonOutput() {
+		if entries == nil {
+			entries = []agent.Entry{}
+		}
+		return printJSON(map[string]interface{}{"running": running, "secrets": entries})
+	}
+
+	if !running {
+		fmt.Println("The agent isn't running, type denv agent start to start it")
+		return nil
+	}
+
+	fmt.Printf("The agent is listening on %s\n", config.AgentSocketPath)
+	if len(entries) == 0 {
+		fmt.Println("No passphrase is kept")
+		return nil
+	}
+
+	for _, entry := range entries {
+		fmt.Printf("%-50s | until %s\n", entry.Name, entry.Expires.Local().Format("2006-01-02 15:04:05"))
+	}
+	return nil
+}
+
+func (cli *CLI) handleAgentLock() error {
+	err := agent.ForgetAll(config.AgentSocketPath)
+	if err != nil {
+		return fmt.Errorf("failed to reach the agent: %w", err)
+	}
+
+	fmt.Fprintln(messages, "🔒 Every passphrase was forgotten")
+	return nil
+}
+
+// startAgent runs denv agent serve in the background unless it already runs
+func startAgent(ttl time.Duration) error {
+	if agent.Running(config.AgentSocketPath) {
+		return nil
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find denv: %w", err)
+	}
+
+	cmd := exec.Command(executable, "agent", "serve", "--ttl", ttl.String())
+	detachProcess(cmd)
+
+	err = cmd.Start()
+	if err != nil {
+		return fmt.Errorf("failed to start the agent: %w", err)
+	}
+	cmd.Process.Release()
+
+	for i := 0; i < 50; i++ {
+		if agent.Running(config.AgentSocketPath) {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	return errors.New("the agent didn't start")
+}
+
+// readCachedPassphrase reads a passphrase from envName, then from the agent
+// under name and last from the terminal, handing what was typed to the agent
+// when one runs so it isn't asked again
+func readCachedPassphrase(name, prompt, envName string) (string, error) {
+	if passphrase := os.Getenv(envName); passphrase != "" {
+		return passphrase, nil
+	}
+
+	if passphrase, ok := agent.Get(config.AgentSocketPath, name); ok {
+		return passphrase, nil
+	}
+
+	passphrase, err := readPassphrase(prompt, envName)
+	if err != nil {
+		return "", err
+	}
+
+	if passphrase != "" && agent.Running(config.AgentSocketPath) {
+		agent.Set(config.AgentSocketPath, name, passphrase, 0)
+	}
+
+	return passphrase, nil
+}
+
+// forgetCachedPassphrase drops a passphrase the agent holds once it turned out wrong
+func forgetCachedPassphrase(name string) {
+	agent.Forget(config.AgentSocketPath, name)
+}
+
+// askMasterPassphrase gets the passphrase of locked settings
+func askMasterPassphrase() (string, error) {
+	return readCachedPassphrase(masterSecret, "🔑 Insert the master passphrase of your denv settings: ", "DENV_MASTER_PASSPHRASE")
+}
+
+// agentMasterPassphrase only gets the passphrase from the agent, for the
+// shell completion which can't ask for it
+func agentMasterPassphrase() (string, error) {
+	if passphrase, ok := agent.Get(config.AgentSocketPath, masterSecret); ok {
+		return passphrase, nil
+	}
+
+	return "", errors.New("the settings are locked, run denv agent start and any denv command to unlock them")
+}
+
+// passphraseSecret names the encryption passphrase of a storage in the agent
+func passphraseSecret(storageID string) string {
+	return "passphrase:" + storageID
+}
+
+// sshSecret names the passphrase of an SSH key in the agent
+func sshSecret(keyPath string) string {
+	return "ssh:" + strings.TrimSpace(keyPath)
+}
@@ -2,6 +2,9 @@ package cli
 
 import (
 	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"os"
@@ -139,3 +142,79 @@ func extractZipArchive(zipPath, extractDir string) error {
 
 	return nil
 }
+
+// zipTreeHashes maps every file of an in-memory zip archive to the hash of its content
+func zipTreeHashes(content []byte) (map[string]string, error) {
+	reader, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open zip file: %v", err)
+	}
+
+	hashes := make(map[string]string)
+	for _, file := range reader.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+
+		fileReader, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open zip file: %v", err)
+		}
+
+		hash, err := hashReader(fileReader)
+		fileReader.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		hashes[filepath.ToSlash(file.Name)] = hash
+	}
+
+	return hashes, nil
+}
+
+// dirTreeHashes maps every file under dir to the hash of its content, using the
+// same relative paths createZipArchive stores
+func dirTreeHashes(dir string) (map[string]string, error) {
+	hashes := make(map[string]string)
+
+	err := filepath.Walk(dir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(dir, filePath)
+		if err != nil {
+			return err
+		}
+
+		file, err := os.Open(filePath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		hash, err := hashReader(file)
+		if err != nil {
+			return err
+		}
+
+		hashes[filepath.ToSlash(relPath)] = hash
+		return nil
+	})
+
+	return hashes, err
+}
+
+func hashReader(reader io.Reader) (string, error) {
+	hash := sha256.New()
+	if _, err := io.Copy(hash, reader); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
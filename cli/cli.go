@@ -1,19 +1,24 @@
 package cli
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"path"
+	"path/filepath"
 	"strings"
 
 	"github.com/robertokbr/denv/bucket"
 	"github.com/robertokbr/denv/config"
+	"github.com/robertokbr/denv/dotenv"
 )
 
 type CLI struct {
-	s3bucket            *bucket.S3Bucket
+	store               bucket.Store
+	backend             bucket.Store
+	storageID           string
 	flagUpload          string
 	flagName            string
 	flagOutput          string
@@ -25,7 +30,14 @@ type CLI struct {
 	flagCompletionFiles bool
 	flagSetupCompletion bool
 	flagRecursive       bool
+	flagVersion         int
+	flagForce           bool
+	flagOutputFormat    string
+	flagQuiet           bool
+	flagProfile         string
 	commands            map[string]Command
+	commandNames        []string
+	args                []string
 }
 
 func New() *CLI {
@@ -34,43 +46,48 @@ func New() *CLI {
 	}
 
 	flag.BoolVar(&cli.flagHelp, "help", false, "See how to use the CLI")
-	flag.BoolVar(&cli.flagConfig, "config", false, "Start the app config")
-	flag.StringVar(&cli.flagUpload, "up", "", "Upload some file")
-	flag.StringVar(&cli.flagName, "name", "", "Nickname to the file you will upload")
-	flag.StringVar(&cli.flagOutput, "out", "", "Optional flag to specify the output such as: .env.example")
-	flag.BoolVar(&cli.flagList, "list", false, "List all files in the bucket")
-	flag.StringVar(&cli.flagDelete, "del", "", "Delete some file in the bucket")
-	flag.StringVar(&cli.flagRename, "rename", "", "Rename a file in the bucket")
 	flag.BoolVar(&cli.flagCompletionFiles, "completion-files", false, "List files for shell completion (internal use)")
 	flag.BoolVar(&cli.flagSetupCompletion, "setup-completion", false, "Setup shell completion for denv commands")
-	flag.BoolVar(&cli.flagRecursive, "r", false, "Upload a directory recursively (will be zipped)")
+	flag.StringVar(&cli.flagOutputFormat, "output", OutputText, "Output format of the results: text or json")
+	flag.BoolVar(&cli.flagQuiet, "quiet", false, "Only print results and errors, no progress messages")
+	flag.StringVar(&cli.flagProfile, "profile", "", "Settings profile to use instead of the default one")
+
+	// Flags from before the subcommands, kept so existing scripts keep working
+	flag.BoolVar(&cli.flagConfig, "config", false, "Deprecated: use denv config")
+	flag.StringVar(&cli.flagUpload, "up", "", "Deprecated: use denv up")
+	flag.StringVar(&cli.flagName, "name", "", "Deprecated: use denv up or denv get")
+	flag.StringVar(&cli.flagOutput, "out", "", "Deprecated: use denv get --out")
+	flag.BoolVar(&cli.flagList, "list", false, "Deprecated: use denv ls")
+	flag.StringVar(&cli.flagDelete, "del", "", "Deprecated: use denv rm")
+	flag.StringVar(&cli.flagRename, "rename", "", "Deprecated: use denv mv")
+	flag.BoolVar(&cli.flagRecursive, "r", false, "Deprecated: use denv up -r")
+	flag.IntVar(&cli.flagVersion, "version", 0, "Deprecated: use denv get --version")
+	flag.BoolVar(&cli.flagForce, "force", false, "Deprecated: use denv up --force")
 
 	flag.Parse()
 
-	// Register commands first so we can handle special commands
-	cli.registerCommands()
-
-	// Skip initialization for completion-related commands
-	if !cli.flagCompletionFiles && !cli.flagSetupCompletion {
-		// Initialize configuration
-		err := initializeApp()
-		if err != nil {
-			log.Fatalf("Failed to initialize application: %v", err)
-		}
-
-		// Create S3 bucket instance
-		cli.initializeS3Bucket()
+	// Anything after the subcommand name belongs to the subcommand
+	if flag.NArg() > 1 {
+		cli.args = flag.Args()[1:]
 	}
 
+	cli.registerCommands()
+
 	return cli
 }
 
-func initializeApp() error {
+func initializeApp(profile string) error {
 	// Initialize paths
 	if err := config.InitPaths(); err != nil {
 		return fmt.Errorf("failed to initialize paths: %v", err)
 	}
 
+	config.MasterPassphrase = askMasterPassphrase
+
+	if err := config.SelectProfile(profile); err != nil {
+		return &bucket.Error{Kind: bucket.ErrNotConfigured, Err: err}
+	}
+
 	// Setup environment
 	if err := config.SetupEnvironment(); err != nil {
 		return fmt.Errorf("failed to setup environment: %v", err)
@@ -79,218 +96,411 @@ func initializeApp() error {
 	return nil
 }
 
-func (cli *CLI) initializeS3Bucket() {
+func (cli *CLI) initializeStore() error {
 	creds := config.GetAWSCredentials()
-	cli.s3bucket = bucket.NewS3Bucket(
-		creds.AccessKey,
-		creds.SecretKey,
-		creds.BucketName,
-		creds.BucketRegion,
-	)
+
+	backend, err := newBackend(creds)
+	if err != nil {
+		return &bucket.Error{Kind: bucket.ErrNotConfigured, Err: fmt.Errorf("failed to initialize storage: %w", err)}
+	}
+
+	store, err := newEncryptedStore(backend, creds)
+	if err != nil {
+		return &bucket.Error{Kind: bucket.ErrNotConfigured, Err: fmt.Errorf("failed to initialize encryption: %w", err)}
+	}
+
+	cli.backend = backend
+	cli.store = store
+	cli.storageID = creds.StorageID()
+	return nil
 }
 
 func (cli *CLI) registerCommands() {
+	// Commands are selected by name, e.g. "denv up .env --name dev"
 	commands := []Command{
-		newHelpCommand(cli),
+		newUpCommand(cli),
+		newGetCommand(cli),
+		newLsCommand(cli),
+		newRmCommand(cli),
+		newMvCommand(cli),
+		newSetKeyCommand(cli),
+		newUnsetKeyCommand(cli),
+		newRunCommand(cli),
+		newExportCommand(cli),
+		newImportCommand(cli),
+		newPullCommand(cli),
+		newPushCommand(cli),
+		newDiffCommand(cli),
+		newMergeCommand(cli),
+		newHistoryCommand(cli),
+		newRollbackCommand(cli),
+		newRecipientsCommand(cli),
 		newConfigCommand(cli),
-		newUploadCommand(cli),
-		newDownloadCommand(cli),
-		newListCommand(cli),
-		newDeleteCommand(cli),
-		newRenameCommand(cli),
-		newCompletionFilesCommand(cli),
-		newSetupCompletionCommand(cli),
+		newAgentCommand(cli),
+		newHelpCommand(cli),
 	}
 
 	for _, cmd := range commands {
+		// Wrong flags show the help of the command they were given to
+		if cmd.Flags != nil {
+			current := cmd
+			cmd.Flags.Usage = func() { printCommandHelp(notices, current) }
+		}
+
 		cli.commands[cmd.Name] = cmd
+		cli.commandNames = append(cli.commandNames, cmd.Name)
 	}
 }
 
-func (cli *CLI) validateEnvironment() bool {
+func (cli *CLI) validateEnvironment() error {
 	err := config.ValidateEnvironment()
-	if err != nil {
+	if errors.Is(err, config.ErrWrongPassphrase) {
+		forgetCachedPassphrase(masterSecret)
+		return &bucket.Error{Kind: bucket.ErrAccessDenied, Err: err}
+	}
+	// Locked settings are configured, they just couldn't be read
+	if err != nil && !config.IsLocked() {
 		PrintSetupMessage()
-		return false
 	}
-	return true
+	if err != nil {
+		return &bucket.Error{Kind: bucket.ErrNotConfigured, Err: err}
+	}
+	return nil
 }
 
-func (cli *CLI) executeWithValidation(operation func()) {
-	if !cli.validateEnvironment() {
-		return
+func (cli *CLI) executeWithValidation(operation func() error) error {
+	if err := cli.validateEnvironment(); err != nil {
+		return err
+	}
+
+	// Create the storage backend instance once the config is known to be valid
+	if err := cli.initializeStore(); err != nil {
+		return err
+	}
+
+	err := operation()
+
+	// A passphrase that doesn't decrypt the files shouldn't be offered again
+	if errors.Is(err, bucket.ErrAccessDenied) {
+		forgetCachedPassphrase(passphraseSecret(cli.storageID))
 	}
-	operation()
+
+	return err
 }
 
-func (cli *CLI) handleUpload() {
-	cli.executeWithValidation(func() {
-		currentPath, err := os.Getwd()
+func (cli *CLI) handleUpload(localPath, name string, recursive bool) error {
+	return cli.executeWithValidation(func() error {
+		fullPath, err := filepath.Abs(localPath)
 		if err != nil {
-			log.Fatalf("Failed to get the current path %v", err)
+			return fmt.Errorf("failed to get the current path: %w", err)
 		}
 
-		fullPath := path.Join(currentPath, cli.flagUpload)
+		return cli.uploadPath(fullPath, name, recursive)
+	})
+}
 
-		if cli.flagRecursive {
-			// Create a temporary zip file with a unique name that doesn't conflict
-			tempDir, err := os.MkdirTemp("", "denv")
-			if err != nil {
-				log.Fatalf("Failed to create temporary directory: %v", err)
-			}
-			defer os.RemoveAll(tempDir) // Clean up temp directory
+// uploadPath stores the file at fullPath as name, directories are zipped when recursive is set
+func (cli *CLI) uploadPath(fullPath, name string, recursive bool) error {
+	if recursive {
+		// Create a temporary zip file with a unique name that doesn't conflict
+		tempDir, err := os.MkdirTemp("", "denv")
+		if err != nil {
+			return fmt.Errorf("failed to create temporary directory: %w", err)
+		}
+		defer os.RemoveAll(tempDir) // Clean up temp directory
 
-			tempZipPath := path.Join(tempDir, "temp_archive")
-			err = createZipArchive(fullPath, tempZipPath)
-			if err != nil {
-				log.Fatalf("Failed to create zip archive: %v", err)
-			}
+		tempZipPath := path.Join(tempDir, "temp_archive")
+		err = createZipArchive(fullPath, tempZipPath)
+		if err != nil {
+			return fmt.Errorf("failed to create zip archive: %w", err)
+		}
 
-			// Check if the name already ends with .zip
-			bucketName := cli.flagName
-			if !strings.HasSuffix(bucketName, ".zip") {
-				bucketName += ".zip"
-			}
+		// Check if the name already ends with .zip
+		bucketName := name
+		if !strings.HasSuffix(bucketName, ".zip") {
+			bucketName += ".zip"
+		}
 
-			// Upload the zip file
-			cli.s3bucket.UploadFile(tempZipPath, bucketName)
-		} else {
-			// For regular files, preserve the original file extension if the user hasn't specified one
-			originalExt := path.Ext(fullPath)
-			targetName := cli.flagName
+		// Upload the zip file
+		return cli.uploadFile(tempZipPath, fullPath, bucketName)
+	}
 
-			// If the original file has an extension and the target name doesn't have any extension
-			if originalExt != "" && path.Ext(targetName) == "" {
-				targetName += originalExt
-			}
+	// For regular files, preserve the original file extension if the user hasn't specified one
+	originalExt := path.Ext(fullPath)
+	targetName := name
+
+	// If the original file has an extension and the target name doesn't have any extension
+	if originalExt != "" && path.Ext(targetName) == "" {
+		targetName += originalExt
+	}
+
+	return cli.uploadFile(fullPath, fullPath, targetName)
+}
 
-			cli.s3bucket.UploadFile(fullPath, targetName)
+// uploadFile stores filePath as name unless that would overwrite changes made
+// elsewhere, localPath is what the user uploads and is shown on conflicts
+func (cli *CLI) uploadFile(filePath, localPath, name string) error {
+	if isDotenvFile(name) || isDotenvFile(localPath) {
+		content, err := os.ReadFile(filePath)
+		if err == nil && dotenv.HasConflictMarkers(content) {
+			return fmt.Errorf("%s still has conflict markers, keep one side of each before uploading it", localPath)
 		}
-	})
+	}
+
+	err := cli.checkConflict(name, localPath)
+	if err != nil {
+		return err
+	}
+
+	info, err := bucket.UploadFile(cli.store, filePath, name)
+	if err != nil {
+		return err
+	}
+
+	cli.recordSync(name, info)
+	return cli.printTransfer(info, localPath)
 }
 
-func (cli *CLI) handleDownload() {
-	cli.executeWithValidation(func() {
-		outputPath := cli.flagOutput
+func (cli *CLI) handleDownload(name, outputPath string, version int) error {
+	return cli.executeWithValidation(func() error {
 		if outputPath == "" {
-			outputPath = cli.flagName
+			outputPath = name
 		}
 
-		// Download the file
-		cli.s3bucket.DownloadFile(cli.flagName, outputPath)
-
-		// Check if the file is a zip (ends with .zip)
-		if strings.HasSuffix(outputPath, ".zip") {
-			// Extract the zip file
-			extractDir := strings.TrimSuffix(outputPath, ".zip")
-			err := extractZipArchive(outputPath, extractDir)
-			if err != nil {
-				log.Printf("Warning: Failed to extract zip file: %v", err)
-			} else {
-				// Remove the zip file after extraction
-				os.Remove(outputPath)
-			}
-		}
+		return cli.downloadTo(name, outputPath, version)
 	})
 }
 
-func (cli *CLI) handleList() {
-	cli.executeWithValidation(func() {
-		cli.s3bucket.ListFiles()
-	})
+// downloadTo saves name, or one of its previous versions when version is set,
+// at outputPath and extracts it next to it when it is a zip
+func (cli *CLI) downloadTo(name, outputPath string, version int) error {
+	var info *bucket.ObjectInfo
+
+	// Download the file, or one of its previous versions
+	if version > 0 {
+		downloaded, err := bucket.DownloadVersion(cli.store, name, version, outputPath)
+		if err != nil {
+			return err
+		}
+		info = &downloaded.ObjectInfo
+	} else {
+		downloaded, err := bucket.DownloadFile(cli.store, name, outputPath)
+		if err != nil {
+			return err
+		}
+		info = downloaded
+		cli.recordSync(name, info)
+	}
+
+	// Check if the file is a zip (ends with .zip)
+	if strings.HasSuffix(outputPath, ".zip") {
+		// Extract the zip file
+		extractDir := strings.TrimSuffix(outputPath, ".zip")
+		err := extractZipArchive(outputPath, extractDir)
+		if err != nil {
+			log.Printf("Warning: Failed to extract zip file: %v", err)
+		} else {
+			// Remove the zip file after extraction
+			os.Remove(outputPath)
+			outputPath = extractDir
+		}
+	}
+
+	return cli.printTransfer(info, outputPath)
+}
+
+// printTransfer shows the file just uploaded or downloaded with --output json,
+// the text output already said everything while it happened
+func (cli *CLI) printTransfer(info *bucket.ObjectInfo, localPath string) error {
+	if !cli.jsonOutput() || info == nil {
+		return nil
+	}
+
+	return printJSON(transferJSON{objectJSON: newObjectJSON(*info), Path: localPath})
 }
 
-func (cli *CLI) handleDelete() {
-	cli.executeWithValidation(func() {
-		cli.s3bucket.DeleteFile(cli.flagDelete)
+func (cli *CLI) handleList(opts bucket.ListOptions) error {
+	return cli.executeWithValidation(func() error {
+		if !cli.jsonOutput() {
+			return bucket.ListFiles(cli.store, opts)
+		}
+
+		files, err := bucket.FindFiles(cli.store, opts)
+		if err != nil {
+			return fmt.Errorf("failed to list files: %w", err)
+		}
+
+		list := make([]objectJSON, 0, len(files))
+		for _, file := range files {
+			list = append(list, newObjectJSON(file))
+		}
+
+		return printJSON(list)
 	})
 }
 
-func (cli *CLI) handleRename() {
-	cli.executeWithValidation(func() {
-		if cli.flagName == "" {
-			fmt.Println("🌝 Please, provide a new name for the file using --name flag")
-			return
+func (cli *CLI) handleDelete(name string) error {
+	return cli.executeWithValidation(func() error {
+		err := bucket.DeleteFile(cli.store, name)
+		if err != nil {
+			return err
 		}
-		cli.s3bucket.RenameFile(cli.flagRename, cli.flagName)
+
+		cli.forgetSync(name)
+		return nil
 	})
 }
 
-func (cli *CLI) handleConfig() {
-	ConfigureApplication()
+func (cli *CLI) handleRename(oldName, newName string) error {
+	return cli.executeWithValidation(func() error {
+		// Names ending with a slash rename every file under them
+		if strings.HasSuffix(oldName, "/") {
+			if !strings.HasSuffix(newName, "/") {
+				newName += "/"
+			}
+
+			renamed, err := bucket.RenamePrefix(cli.store, oldName, newName, cli.flagForce)
+			for oldKey, newKey := range renamed {
+				cli.moveSync(oldKey, newKey)
+			}
+			return err
+		}
+
+		newKey, err := bucket.RenameFile(cli.store, oldName, newName, cli.flagForce)
+		if err != nil {
+			return err
+		}
+
+		cli.moveSync(oldName, newKey)
+		return nil
+	})
 }
 
 func (cli *CLI) handleHelp() {
-	PrintHelp()
+	var commands []Command
+	for _, name := range cli.commandNames {
+		commands = append(commands, cli.commands[name])
+	}
+
+	PrintHelp(commands)
 }
 
 func (cli *CLI) handleCompletionFiles() {
 	PrintFileList()
 }
 
-func (cli *CLI) handleSetupCompletion() {
+func (cli *CLI) handleSetupCompletion() error {
 	err := WriteCompletionScript()
 	if err != nil {
-		fmt.Printf("Failed to setup completion: %v\n", err)
-		return
+		return fmt.Errorf("failed to setup completion: %w", err)
 	}
+	return nil
 }
 
-func (cli *CLI) executeCommand(name string) bool {
-	if cmd, exists := cli.commands[name]; exists {
-		cmd.Execute()
-		return true
+func (cli *CLI) executeCommand(name string) error {
+	cmd, exists := cli.commands[name]
+	if !exists {
+		fmt.Fprintln(notices, "🤓 Type denv help if you want to see how to use the CLI.")
+		return printCommandError("🌝 Unknown command: %s", name)
 	}
-	return false
-}
 
-func (cli *CLI) Run() {
-	// Handle completion commands first as they don't require full initialization
-	if cli.flagCompletionFiles && cli.executeCommand("completion-files") {
-		return
+	if wantsHelp(cli.args) {
+		printCommandHelp(os.Stdout, cmd)
+		return nil
 	}
 
-	if cli.flagSetupCompletion && cli.executeCommand("setup-completion") {
-		return
+	return cmd.Execute()
+}
+
+// Run executes what the arguments ask for and returns the exit code of denv,
+// see ExitCode for what each code means
+func (cli *CLI) Run() int {
+	err := cli.run()
+	if err != nil && !isReported(err) {
+		log.Printf("Error: %v", err)
 	}
 
-	if cli.flagHelp && cli.executeCommand("help") {
-		return
+	return ExitCode(err)
+}
+
+func (cli *CLI) run() error {
+	args, err := cli.extractGlobalFlags(cli.args)
+	if err != nil {
+		return err
 	}
+	cli.args = args
 
-	if cli.flagConfig && cli.executeCommand("config") {
-		return
+	err = cli.setupOutput()
+	if err != nil {
+		return err
 	}
 
-	if cli.flagUpload != "" && cli.flagName != "" && cli.executeCommand("upload") {
-		return
+	// Handle completion commands first as they don't require full initialization
+	if cli.flagCompletionFiles {
+		cli.handleCompletionFiles()
+		return nil
 	}
 
-	if cli.flagRename != "" && cli.flagName != "" && cli.executeCommand("rename") {
-		return
+	if cli.flagSetupCompletion {
+		return cli.handleSetupCompletion()
 	}
 
-	if cli.flagName != "" && cli.flagUpload == "" && cli.flagRename == "" && cli.executeCommand("download") {
-		return
+	// Help needs no settings, so it works before denv is configured or with a
+	// profile that doesn't exist
+	if flag.NArg() == 0 && cli.flagHelp {
+		cli.handleHelp()
+		return nil
 	}
 
-	if cli.flagList && cli.executeCommand("list") {
-		return
+	if flag.NArg() > 0 && (flag.Arg(0) == "help" || wantsHelp(cli.args)) {
+		return cli.executeCommand(flag.Arg(0))
 	}
 
-	if cli.flagDelete != "" && cli.executeCommand("delete") {
-		return
+	err = initializeApp(cli.flagProfile)
+	if err != nil {
+		return fmt.Errorf("failed to initialize application: %w", err)
 	}
 
-	if cli.flagUpload != "" && cli.flagName == "" {
-		fmt.Println("🌝 Please, provide a nickname to your file using --name flag")
-		return
+	// Commands such as "denv up" or "denv recipients list"
+	if flag.NArg() > 0 {
+		return cli.executeCommand(flag.Arg(0))
 	}
 
-	if cli.flagRename != "" && cli.flagName == "" {
-		fmt.Println("🌝 Please, provide a new name for the file using --name flag")
-		return
+	return cli.runDeprecatedFlags()
+}
+
+// runDeprecatedFlags keeps the flags denv had before its subcommands working,
+// the warning goes to stderr so the output scripts read doesn't change
+func (cli *CLI) runDeprecatedFlags() error {
+	switch {
+	case cli.flagConfig:
+		warnDeprecated("--config", "denv config")
+		return cli.configureInteractively()
+	case cli.flagUpload != "" && cli.flagName != "":
+		warnDeprecated("--up", "denv up [file path] --name [file nickname]")
+		return cli.handleUpload(cli.flagUpload, cli.flagName, cli.flagRecursive)
+	case cli.flagRename != "" && cli.flagName != "":
+		warnDeprecated("--rename", "denv mv [file nickname] [new nickname]")
+		return cli.handleRename(cli.flagRename, cli.flagName)
+	case cli.flagName != "" && cli.flagUpload == "" && cli.flagRename == "":
+		warnDeprecated("--name", "denv get [file nickname]")
+		return cli.handleDownload(cli.flagName, cli.flagOutput, cli.flagVersion)
+	case cli.flagList:
+		warnDeprecated("--list", "denv ls")
+		return cli.handleList(bucket.ListOptions{})
+	case cli.flagDelete != "":
+		warnDeprecated("--del", "denv rm [file nickname]")
+		return cli.handleDelete(cli.flagDelete)
+	case cli.flagUpload != "" && cli.flagName == "":
+		return printCommandError("🌝 Please, provide a nickname to your file using --name flag")
+	case cli.flagRename != "" && cli.flagName == "":
+		return printCommandError("🌝 Please, provide a new name for the file using --name flag")
+	default:
+		fmt.Fprintln(messages, "🤓 Type denv help if you want to see how to use the CLI.")
+		return nil
 	}
+}
 
-	fmt.Println("🤓 Type denv --help if you want to see how to use the CLI.")
+func warnDeprecated(oldFlag, replacement string) {
+	fmt.Fprintf(os.Stderr, "⚠️  %s is deprecated and will be removed, use: %s\n", oldFlag, replacement)
 }
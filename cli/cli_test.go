@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/robertokbr/denv/bucket"
+	"github.com/robertokbr/denv/config"
+	"github.com/robertokbr/denv/crypt"
+)
+
+// newTestCLI returns a CLI configured with the local backend in temporary
+// directories, along with the directory the files are stored in
+func newTestCLI(t *testing.T) (*CLI, string) {
+	t.Helper()
+
+	home := t.TempDir()
+	storeDir := t.TempDir()
+
+	t.Setenv("DENV_PROFILE", "")
+	t.Setenv("DENV_BACKEND", config.BackendLocal)
+	t.Setenv("DENV_LOCAL_PATH", storeDir)
+	t.Setenv("DENV_ENCRYPTION", config.EncryptionNone)
+
+	config.SetPaths(home)
+	if err := config.SelectProfile(config.DefaultProfile); err != nil {
+		t.Fatalf("SelectProfile: %v", err)
+	}
+	if err := config.SetupEnvironment(); err != nil {
+		t.Fatalf("SetupEnvironment: %v", err)
+	}
+
+	previousMessages, previousNotices, previousBucket, previousCrypt := messages, notices, bucket.Messages, crypt.Warnings
+	messages, notices, bucket.Messages, crypt.Warnings = io.Discard, io.Discard, io.Discard, io.Discard
+	t.Cleanup(func() {
+		messages, notices, bucket.Messages, crypt.Warnings = previousMessages, previousNotices, previousBucket, previousCrypt
+	})
+
+	cli := &CLI{commands: make(map[string]Command)}
+	cli.registerCommands()
+
+	return cli, storeDir
+}
+
+// execute runs a command the way "denv name args..." does
+func (cli *CLI) execute(name string, args ...string) error {
+	cli.args = args
+	return cli.executeCommand(name)
+}
+
+// captureStdout returns what fn printed to stdout
+func captureStdout(t *testing.T, fn func() error) (string, error) {
+	t.Helper()
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Pipe: %v", err)
+	}
+
+	stdout := os.Stdout
+	os.Stdout = writer
+	runErr := fn()
+	os.Stdout = stdout
+	writer.Close()
+
+	output, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("reading stdout: %v", err)
+	}
+
+	return string(output), runErr
+}
+
+func writeTestFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	filePath := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(filePath, []byte(content), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return filePath
+}
+
+func TestUploadAndDownload(t *testing.T) {
+	cli, storeDir := newTestCLI(t)
+
+	localPath := writeTestFile(t, "app.env", "A=1\n")
+	if err := cli.execute("up", localPath, "--name", "app"); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+
+	// The extension of the local file is kept
+	if _, err := os.Stat(filepath.Join(storeDir, "app.env")); err != nil {
+		t.Fatalf("app.env wasn't stored: %v", err)
+	}
+
+	outPath := filepath.Join(t.TempDir(), "downloaded.env")
+	if err := cli.execute("get", "app.env", "--out", outPath); err != nil {
+		t.Fatalf("get: %v", err)
+	}
+
+	content, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if string(content) != "A=1\n" {
+		t.Errorf("downloaded %q, want %q", content, "A=1\n")
+	}
+}
@@ -1,112 +1,218 @@
 package cli
 
 import (
+	"flag"
 	"fmt"
+	"io"
+	"os"
+	"path"
+
+	"github.com/robertokbr/denv/bucket"
 )
 
 type Command struct {
 	Name        string
+	Usage       string
 	Description string
-	Execute     func() error
+	// Flags holds the options of the command, nil when it has none
+	Flags   *flag.FlagSet
+	Execute func() error
 }
 
 func printCommandError(format string, args ...interface{}) error {
 	errorMsg := fmt.Sprintf(format, args...)
-	fmt.Println(errorMsg)
-	return fmt.Errorf(errorMsg)
+	fmt.Fprintln(notices, errorMsg)
+	return &usageError{message: errorMsg}
 }
 
-func newUploadCommand(cli *CLI) Command {
-	return Command{
-		Name:        "upload",
-		Description: "Upload a file to S3 bucket",
-		Execute: func() error {
-			if cli.flagName == "" {
-				return printCommandError("🌝 Please, provide a nickname to your file using --name flag")
-			}
-			
-			cli.handleUpload()
-			return nil
-		},
+// printCommandHelp shows how to use a single command
+func printCommandHelp(w io.Writer, cmd Command) {
+	fmt.Fprintf(w, "Usage: %s\n\n%s\n", cmd.Usage, cmd.Description)
+
+	// Commands such as pull have a flag set only to reject unknown flags
+	hasFlags := false
+	if cmd.Flags != nil {
+		cmd.Flags.VisitAll(func(*flag.Flag) { hasFlags = true })
 	}
+	if !hasFlags {
+		return
+	}
+
+	fmt.Fprintln(w, "\nFlags:")
+	cmd.Flags.SetOutput(w)
+	cmd.Flags.PrintDefaults()
 }
 
-func newDownloadCommand(cli *CLI) Command {
-	return Command{
-		Name:        "download",
-		Description: "Download a file from S3 bucket",
-		Execute: func() error {
-			cli.handleDownload()
-			return nil
-		},
+// wantsHelp tells whether -h or --help was given to a command, arguments after
+// -- belong to another program and are left alone
+func wantsHelp(args []string) bool {
+	for _, arg := range args {
+		switch arg {
+		case "--":
+			return false
+		case "-h", "-help", "--help":
+			return true
+		}
 	}
+
+	return false
 }
 
-func newListCommand(cli *CLI) Command {
-	return Command{
-		Name:        "list",
-		Description: "List all files in S3 bucket",
-		Execute: func() error {
-			cli.handleList()
-			return nil
-		},
+// parseArgs parses the flags of a subcommand wherever they appear among its
+// arguments and returns the positional ones
+func parseArgs(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+
+	for {
+		// The flag set already printed what went wrong along with the usage
+		if err := flags.Parse(args); err != nil {
+			return nil, &usageError{message: err.Error()}
+		}
+
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+
+		positional = append(positional, args[0])
+		args = args[1:]
 	}
 }
 
-func newDeleteCommand(cli *CLI) Command {
+func newUpCommand(cli *CLI) Command {
+	flags := flag.NewFlagSet("up", flag.ContinueOnError)
+	name := flags.String("name", "", "Nickname to the file you will upload")
+	recursive := flags.Bool("r", false, "Upload a directory recursively (will be zipped)")
+	flags.BoolVar(&cli.flagForce, "force", false, "Upload even if the file was changed in the bucket since it was last synced")
+
 	return Command{
-		Name:        "delete",
-		Description: "Delete a file from S3 bucket",
+		Name:        "up",
+		Usage:       "denv up [file path] --name [file nickname] [-r] [--force]",
+		Description: "Upload a file, or a directory with -r",
+		Flags:       flags,
 		Execute: func() error {
-			cli.handleDelete()
-			return nil
+			args, err := parseArgs(flags, cli.args)
+			if err != nil {
+				return err
+			}
+
+			if len(args) != 1 {
+				return printCommandError("🌝 Usage: denv up [file path] --name [file nickname]")
+			}
+
+			if *name == "" {
+				return printCommandError("🌝 Please, provide a nickname to your file using --name flag")
+			}
+
+			return cli.handleUpload(args[0], *name, *recursive)
 		},
 	}
 }
 
-func newRenameCommand(cli *CLI) Command {
+func newGetCommand(cli *CLI) Command {
+	flags := flag.NewFlagSet("get", flag.ContinueOnError)
+	output := flags.String("out", "", "Optional output such as: .env.example")
+	version := flags.Int("version", 0, "Download a previous version of the file, see denv history")
+
 	return Command{
-		Name:        "rename",
-		Description: "Rename a file in S3 bucket",
+		Name:        "get",
+		Usage:       "denv get [file nickname] [KEY] [--out file] [--version version]",
+		Description: "Download a file, or print the value of a variable of a stored env file when KEY is given",
+		Flags:       flags,
 		Execute: func() error {
-			if cli.flagName == "" {
-				return printCommandError("🌝 Please, provide a new name for the file using --name flag")
+			args, err := parseArgs(flags, cli.args)
+			if err != nil {
+				return err
+			}
+
+			switch len(args) {
+			case 1:
+				return cli.handleDownload(args[0], *output, *version)
+			case 2:
+				return cli.handleGetKey(args[0], args[1])
+			default:
+				return printCommandError("🌝 Usage: denv get [file nickname] [KEY]")
 			}
-			cli.handleRename()
-			return nil
 		},
 	}
 }
 
-func newConfigCommand(cli *CLI) Command {
+func newLsCommand(cli *CLI) Command {
+	flags := flag.NewFlagSet("ls", flag.ContinueOnError)
+	sortBy := flags.String("sort", bucket.SortName, "Order of the files: name, date (newest first) or size (largest first)")
+	filter := flags.String("filter", "", "Only list the files matching a glob such as *.env")
+	tree := flags.Bool("tree", false, "Show nicknames separated by slashes as folders")
+
 	return Command{
-		Name:        "config",
-		Description: "Configure the application",
+		Name:        "ls",
+		Usage:       "denv ls [prefix] [--sort name|date|size] [--filter glob] [--tree]",
+		Description: "List the files in the bucket, or the ones under a prefix",
+		Flags:       flags,
 		Execute: func() error {
-			cli.handleConfig()
-			return nil
+			args, err := parseArgs(flags, cli.args)
+			if err != nil {
+				return err
+			}
+
+			if len(args) > 1 {
+				return printCommandError("🌝 Usage: denv ls [prefix] [--sort name|date|size] [--filter glob] [--tree]")
+			}
+
+			switch *sortBy {
+			case bucket.SortName, bucket.SortDate, bucket.SortSize:
+			default:
+				return printCommandError("🌝 Unknown sort order %s, use %s, %s or %s", *sortBy, bucket.SortName, bucket.SortDate, bucket.SortSize)
+			}
+
+			if _, err := path.Match(*filter, ""); err != nil {
+				return printCommandError("🌝 Invalid filter %s: %v", *filter, err)
+			}
+
+			opts := bucket.ListOptions{Sort: *sortBy, Filter: *filter, Tree: *tree}
+			if len(args) == 1 {
+				opts.Prefix = args[0]
+			}
+
+			return cli.handleList(opts)
 		},
 	}
 }
 
-func newCompletionFilesCommand(cli *CLI) Command {
+func newRmCommand(cli *CLI) Command {
 	return Command{
-		Name:        "completion-files",
-		Description: "List files for shell completion",
+		Name:        "rm",
+		Usage:       "denv rm [file nickname]",
+		Description: "Delete a file in the bucket",
 		Execute: func() error {
-			cli.handleCompletionFiles()
-			return nil
+			if len(cli.args) != 1 {
+				return printCommandError("🌝 Usage: denv rm [file nickname]")
+			}
+
+			return cli.handleDelete(cli.args[0])
 		},
 	}
 }
 
-func newSetupCompletionCommand(cli *CLI) Command {
+func newMvCommand(cli *CLI) Command {
+	flags := flag.NewFlagSet("mv", flag.ContinueOnError)
+	flags.BoolVar(&cli.flagForce, "force", false, "Replace a file already using the new nickname, it is kept in its history")
+
 	return Command{
-		Name:        "setup-completion",
-		Description: "Setup shell completion for denv commands",
+		Name:        "mv",
+		Usage:       "denv mv [file nickname] [new nickname] [--force]",
+		Description: "Rename a file in the bucket, or every file under a prefix ending with /",
+		Flags:       flags,
 		Execute: func() error {
-			cli.handleSetupCompletion()
-			return nil
+			args, err := parseArgs(flags, cli.args)
+			if err != nil {
+				return err
+			}
+
+			if len(args) != 2 {
+				return printCommandError("🌝 Usage: denv mv [file nickname] [new nickname] [--force]")
+			}
+
+			return cli.handleRename(args[0], args[1])
 		},
 	}
 }
@@ -114,10 +220,21 @@ func newSetupCompletionCommand(cli *CLI) Command {
 func newHelpCommand(cli *CLI) Command {
 	return Command{
 		Name:        "help",
-		Description: "Show help information",
+		Usage:       "denv help [command]",
+		Description: "Show help information, or how to use a command",
 		Execute: func() error {
-			cli.handleHelp()
+			if len(cli.args) == 0 {
+				cli.handleHelp()
+				return nil
+			}
+
+			cmd, exists := cli.commands[cli.args[0]]
+			if !exists {
+				return printCommandError("🌝 Unknown command: %s", cli.args[0])
+			}
+
+			printCommandHelp(os.Stdout, cmd)
 			return nil
 		},
 	}
-}
\ No newline at end of file
+}
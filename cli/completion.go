@@ -24,20 +24,58 @@ _denv_files() {
   _describe -t files "files" files
 }
 
+_denv_commands() {
+  local commands
+  commands=(
+    'up:Upload a file, or a directory with -r'
+    'get:Download a file, or print one of its variables'
+    'ls:List all files in the bucket'
+    'rm:Delete a file in the bucket'
+    'mv:Rename a file in the bucket'
+    'set:Set variables of a stored env file'
+    'unset:Remove variables from a stored env file'
+    'run:Run a command with stored env files injected'
+    'export:Convert a stored env file to another format'
+    'import:Store secrets written in another format as an env file'
+    'pull:Download every file listed in the project manifest'
+    'push:Upload every file listed in the project manifest'
+    'diff:Compare a local file with the stored copy'
+    'merge:Merge the changes of a stored dotenv file into the local one'
+    'history:List the previous versions of a file'
+    'rollback:Restore a previous version of a file'
+    'recipients:Manage the public keys files are encrypted to'
+    'config:Configure the application'
+    'agent:Keep typed passphrases in memory for a while'
+    'help:Show help information'
+  )
+  _describe -t commands "commands" commands
+}
+
 _denv() {
   local curcontext="$curcontext" state line
   typeset -A opt_args
-  
-  _arguments \
-    '--help[See how to use the CLI]' \
-    '--config[Start the app config]' \
-    '--up[Upload some file]:file:_files' \
-    '--name[Nickname for the file]:file:_denv_files' \
-    '--out[Optional output name]:filename:' \
-    '--list[List all files in the bucket]' \
-    '--del[Delete some file in the bucket]:file:_denv_files' \
-    '--rename[Rename a file in the bucket]:file:_denv_files' \
-    '--setup-completion[Setup shell completion for denv commands]'
+
+  if (( CURRENT == 2 )) && [[ $words[2] != -* ]]; then
+    _denv_commands
+    return
+  fi
+
+  case $words[2] in
+    up|import)
+      _files
+      ;;
+    get|rm|mv|set|unset|export|diff|merge|history|rollback|pull|push)
+      _denv_files
+      ;;
+    help)
+      _denv_commands
+      ;;
+    *)
+      _arguments \
+        '--help[See how to use the CLI]' \
+        '--setup-completion[Setup shell completion for denv commands]'
+      ;;
+  esac
 }
 
 compdef _denv denv
@@ -104,19 +142,26 @@ func WriteCompletionScript() error {
 		}
 	}
 
-	fmt.Println("🎉 ZSH completion has been set up successfully!")
-	fmt.Println("ℹ️  You need to restart your shell or run 'source ~/.zshrc' to enable it.")
+	fmt.Fprintln(messages, "🎉 ZSH completion has been set up successfully!")
+	fmt.Fprintln(messages, "ℹ️  You need to restart your shell or run 'source ~/.zshrc' to enable it.")
 
 	return nil
 }
 
-// GetFileList returns a list of files from the S3 bucket for completion
+// GetFileList returns a list of files from the configured store for completion
 func GetFileList() ([]string, error) {
 	// Initialize config
 	if err := config.InitPaths(); err != nil {
 		return nil, err
 	}
 
+	if err := config.SelectProfile(""); err != nil {
+		return nil, err
+	}
+
+	// Completing a word can't wait for a passphrase
+	config.MasterPassphrase = agentMasterPassphrase
+
 	// Setup environment
 	if err := config.SetupEnvironment(); err != nil {
 		return nil, err
@@ -128,19 +173,14 @@ func GetFileList() ([]string, error) {
 		return nil, err
 	}
 
-	// Get credentials
-	creds := config.GetAWSCredentials()
-
-	// Create S3 bucket client
-	s3Client := bucket.NewS3Bucket(
-		creds.AccessKey,
-		creds.SecretKey,
-		creds.BucketName,
-		creds.BucketRegion,
-	)
+	// Create the storage backend client, listing doesn't need the encryption keys
+	store, err := newBackend(config.GetAWSCredentials())
+	if err != nil {
+		return nil, err
+	}
 
 	// Get the list of files
-	return s3Client.ListFileNames()
+	return bucket.ListFileNames(store)
 }
 
 // PrintFileList prints the list of files for command completion
@@ -0,0 +1,316 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/robertokbr/denv/bucket"
+	"github.com/robertokbr/denv/config"
+	"golang.org/x/term"
+)
+
+func newConfigCommand(cli *CLI) Command {
+	flags := flag.NewFlagSet("config", flag.ContinueOnError)
+
+	// Every setting can be given as a flag to configure without questions
+	for _, setting := range config.Settings {
+		flags.String(strings.ReplaceAll(setting.Name, "_", "-"), "", setting.Description)
+	}
+
+	return Command{
+		Name:        "config",
+		Usage:       "denv config [set|get|list|profiles|use|lock|unlock] [--profile name] [--setting value]",
+		Description: "Configure the application, or the profile selected with --profile",
+		Flags:       flags,
+		Execute: func() error {
+			args, err := parseArgs(flags, cli.args)
+			if err != nil {
+				return err
+			}
+
+			given := make(map[string]string)
+			flags.Visit(func(f *flag.Flag) {
+				given[f.Name] = f.Value.String()
+			})
+
+			if len(args) == 0 {
+				if len(given) > 0 {
+					return cli.handleConfigSet(given)
+				}
+				return cli.configureInteractively()
+			}
+
+			switch args[0] {
+			case "set":
+				return cli.handleConfigSetArgs(args[1:])
+			case "get":
+				if len(args) != 2 {
+					return printConfigUsage()
+				}
+				return cli.handleConfigGet(args[1])
+			case "list":
+				return cli.handleConfigList()
+			case "profiles":
+				return cli.handleConfigProfiles()
+			case "use":
+				if len(args) != 2 {
+					return printConfigUsage()
+				}
+				return cli.handleConfigUse(args[1])
+			case "lock":
+				return cli.handleConfigLock()
+			case "unlock":
+				return cli.handleConfigUnlock()
+			default:
+				return printConfigUsage()
+			}
+		},
+	}
+}
+
+func printConfigUsage() error {
+	fmt.Fprintln(notices, "🌝 Usage:")
+	fmt.Fprintln(notices, "denv config to answer the setup questions")
+	fmt.Fprintln(notices, "denv config --backend s3 --bucket [name] ... to configure without questions")
+	fmt.Fprintln(notices, "denv config set [setting] [value] or denv config set [setting]=[value] ... to change settings")
+	fmt.Fprintln(notices, "denv config get [setting] to print a setting")
+	fmt.Fprintln(notices, "denv config list to list the settings")
+	fmt.Fprintln(notices, "denv config profiles to list the profiles")
+	fmt.Fprintln(notices, "denv config use [profile] to pick the profile used without --profile")
+	fmt.Fprintln(notices, "denv config lock to encrypt the settings with a master passphrase")
+	fmt.Fprintln(notices, "denv config unlock to store the settings in plaintext again")
+	fmt.Fprintln(notices, "Add --profile [name] to work on another profile than the default one")
+	return &usageError{message: "wrong usage of denv config"}
+}
+
+// configureInteractively asks for the settings of the selected profile
+func (cli *CLI) configureInteractively() error {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Fprintln(notices, "🌝 denv config needs a terminal to ask questions, use its flags or denv config set instead")
+		return &usageError{message: "no terminal to configure denv"}
+	}
+
+	if config.Profile != config.DefaultProfile {
+		fmt.Printf("🚧 Configuring the %s profile\n", config.Profile)
+	}
+
+	return ConfigureApplication()
+}
+
+// handleConfigSetArgs reads "setting value" or any number of "setting=value"
+func (cli *CLI) handleConfigSetArgs(args []string) error {
+	values := make(map[string]string)
+
+	if len(args) == 2 && !strings.Contains(args[0], "=") {
+		values[args[0]] = args[1]
+		return cli.handleConfigSet(values)
+	}
+
+	for _, arg := range args {
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			return printConfigUsage()
+		}
+		values[name] = value
+	}
+
+	if len(values) == 0 {
+		return printConfigUsage()
+	}
+
+	return cli.handleConfigSet(values)
+}
+
+func (cli *CLI) handleConfigSet(values map[string]string) error {
+	for name := range values {
+		if _, ok := config.FindSetting(name); !ok {
+			return printCommandError("🌝 Unknown setting %s, type denv config list to see them", name)
+		}
+	}
+
+	err := config.SetSettings(values)
+	if err != nil {
+		return printCommandError("🌝 %v", err)
+	}
+
+	fmt.Fprintf(messages, "🥳 Settings of the %s profile saved!!!\n", config.Profile)
+	return nil
+}
+
+func (cli *CLI) handleConfigGet(name string) error {
+	setting, ok := config.FindSetting(name)
+	if !ok {
+		return printCommandError("🌝 Unknown setting %s, type denv config list to see them", name)
+	}
+
+	err := loadStoredSettings()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(os.Getenv(setting.Env))
+	return nil
+}
+
+// handleConfigList prints every setting of the selected profile, values
+// coming from environment variables instead of the profile are marked
+func (cli *CLI) handleConfigList() error {
+	err := loadStoredSettings()
+	if err != nil {
+		return err
+	}
+
+	stored, err := config.ReadSettings()
+	if err != nil {
+		return err
+	}
+
+	if cli.jsonOutput() {
+		list := make(map[string]string)
+		for _, setting := range config.Settings {
+			if value := os.Getenv(setting.Env); value != "" {
+				list[setting.Name] = displayedSetting(setting, value)
+			}
+		}
+		return printJSON(list)
+	}
+
+	fmt.Fprintf(messages, "🥸 Settings of the %s profile:\n", config.Profile)
+
+	for _, setting := range config.Settings {
+		value := os.Getenv(setting.Env)
+
+		source := ""
+		if value != stored[setting.Env] {
+			source = fmt.Sprintf("(from %s)", setting.Env)
+		}
+
+		line := fmt.Sprintf("%-22s | %-40s %s", setting.Name, displayedSetting(setting, value), source)
+		fmt.Println(strings.TrimRight(line, " "))
+	}
+
+	return nil
+}
+
+// loadStoredSettings makes the settings of the selected profile readable
+// even when they are locked, a profile without settings has none to load
+func loadStoredSettings() error {
+	err := config.LoadSettings()
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if errors.Is(err, config.ErrWrongPassphrase) {
+		return &bucket.Error{Kind: bucket.ErrAccessDenied, Err: err}
+	}
+	if err != nil {
+		return &bucket.Error{Kind: bucket.ErrNotConfigured, Err: err}
+	}
+	return nil
+}
+
+// displayedSetting masks the secrets but the last characters
+func displayedSetting(setting config.Setting, value string) string {
+	if !setting.Secret || value == "" {
+		return value
+	}
+
+	if len(value) <= 4 {
+		return "****"
+	}
+	return "****" + value[len(value)-4:]
+}
+
+func (cli *CLI) handleConfigProfiles() error {
+	profiles, err := config.ListProfiles()
+	if err != nil {
+		return err
+	}
+
+	defaultProfile := config.DefaultProfileName()
+
+	if cli.jsonOutput() {
+		return printJSON(map[string]interface{}{
+			"profiles": profiles,
+			"default":  defaultProfile,
+			"current":  config.Profile,
+		})
+	}
+
+	for _, profile := range profiles {
+		marker := " "
+		if profile == config.Profile {
+			marker = "*"
+		}
+
+		if profile == defaultProfile {
+			fmt.Printf("%s %s (default)\n", marker, profile)
+		} else {
+			fmt.Printf("%s %s\n", marker, profile)
+		}
+	}
+
+	return nil
+}
+
+func (cli *CLI) handleConfigUse(profile string) error {
+	err := config.SetDefaultProfile(profile)
+	if err != nil {
+		return printCommandError("🌝 %v", err)
+	}
+
+	fmt.Fprintf(messages, "🥳 denv uses the %s profile from now on!!!\n", profile)
+	return nil
+}
+
+// handleConfigLock encrypts the settings of every profile, or makes the agent
+// forget the master passphrase when they are already encrypted
+func (cli *CLI) handleConfigLock() error {
+	if config.IsLocked() {
+		forgetCachedPassphrase(masterSecret)
+		fmt.Fprintln(messages, "🔒 Settings locked, the master passphrase is asked again from now on")
+		return nil
+	}
+
+	passphrase, err := readNewPassphrase("🔑 Choose a master passphrase for your denv settings: ", "DENV_MASTER_PASSPHRASE")
+	if err != nil {
+		return err
+	}
+
+	err = config.LockSettings(passphrase)
+	if err != nil {
+		return fmt.Errorf("failed to lock the settings: %w", err)
+	}
+
+	fmt.Fprintln(messages, "🔒 Settings encrypted, keep the master passphrase somewhere safe")
+	return nil
+}
+
+// handleConfigUnlock stores the settings of every profile in plaintext again
+func (cli *CLI) handleConfigUnlock() error {
+	if !config.IsLocked() {
+		fmt.Fprintln(notices, "🌝 The settings aren't encrypted")
+		return nil
+	}
+
+	passphrase, err := askMasterPassphrase()
+	if err != nil {
+		return err
+	}
+
+	err = config.UnlockSettings(passphrase)
+	if errors.Is(err, config.ErrWrongPassphrase) {
+		return &bucket.Error{Kind: bucket.ErrAccessDenied, Err: err}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to unlock the settings: %w", err)
+	}
+
+	forgetCachedPassphrase(masterSecret)
+
+	fmt.Fprintln(messages, "🔓 Settings decrypted, they are stored in plaintext again")
+	return nil
+}
@@ -0,0 +1,258 @@
+package cli
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"github.com/robertokbr/denv/bucket"
+	"github.com/robertokbr/denv/diff"
+)
+
+const maskedValue = "***"
+
+func newDiffCommand(cli *CLI) Command {
+	flags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	showValues := flags.Bool("show-values", false, "Show the values of the changed keys instead of masking them")
+
+	return Command{
+		Name:        "diff",
+		Usage:       "denv diff [file nickname] [local file] [--show-values]",
+		Description: "Compare a local file with the stored copy",
+		Flags:       flags,
+		Execute: func() error {
+			args, err := parseArgs(flags, cli.args)
+			if err != nil {
+				return err
+			}
+
+			if len(args) < 1 || len(args) > 2 {
+				return printCommandError("🌝 Usage: denv diff [file nickname] [local file] [--show-values]")
+			}
+
+			name := args[0]
+			localPath := ""
+			if len(args) == 2 {
+				localPath = args[1]
+			}
+
+			return cli.handleDiff(name, localPath, *showValues)
+		},
+	}
+}
+
+// handleDiff shows what uploading localPath over name would change, the local
+// path defaults to where denv get would download it
+func (cli *CLI) handleDiff(name, localPath string, showValues bool) error {
+	return cli.executeWithValidation(func() error {
+		report, err := cli.compareFiles(name, localPath, showValues)
+		if err != nil {
+			return err
+		}
+
+		if cli.jsonOutput() {
+			return printJSON(report)
+		}
+
+		writeDiff(os.Stdout, report)
+		return nil
+	})
+}
+
+// Kinds of comparison made by denv diff
+const (
+	diffKindDotenv = "dotenv"
+	diffKindTree   = "tree"
+	diffKindText   = "text"
+)
+
+// diffReport is what changes from the stored file to the local one
+type diffReport struct {
+	Name      string       `json:"name"`
+	Local     string       `json:"local"`
+	Kind      string       `json:"kind"`
+	Identical bool         `json:"identical"`
+	Changes   []diffChange `json:"changes"`
+	// Unified is the line diff of text files, empty when it couldn't be made
+	Unified    string `json:"unified,omitempty"`
+	unifiedErr error
+}
+
+// diffChange is a key of dotenv files, or a file of directories, that differs
+type diffChange struct {
+	Type     diff.ChangeType `json:"type"`
+	Key      string          `json:"key"`
+	OldValue string          `json:"old_value,omitempty"`
+	NewValue string          `json:"new_value,omitempty"`
+}
+
+func (cli *CLI) compareFiles(name, localPath string, showValues bool) (*diffReport, error) {
+	remote, err := readRemote(cli.store, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download the file: %w", err)
+	}
+
+	if strings.HasSuffix(name, ".zip") {
+		if localPath == "" {
+			localPath = strings.TrimSuffix(name, ".zip")
+		}
+		return diffTrees(name, localPath, remote)
+	}
+
+	if localPath == "" {
+		localPath = name
+	}
+
+	local, err := os.ReadFile(localPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	if isDotenvFile(name) || isDotenvFile(localPath) {
+		return diffDotenv(name, localPath, remote, local, showValues)
+	}
+
+	return diffLines(name, localPath, remote, local), nil
+}
+
+// readRemote downloads name into memory, a missing file reads as empty so it
+// shows up as entirely added
+func readRemote(store bucket.Store, name string) ([]byte, error) {
+	body, _, err := store.Get(name)
+	if bucket.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	return io.ReadAll(body)
+}
+
+// isDotenvFile recognizes .env, .env.production, production.env and alike
+func isDotenvFile(name string) bool {
+	base := path.Base(name)
+	return base == ".env" || strings.HasPrefix(base, ".env.") || path.Ext(base) == ".env"
+}
+
+func diffDotenv(name, localPath string, remote, local []byte, showValues bool) (*diffReport, error) {
+	remoteValues, err := godotenv.Unmarshal(string(remote))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the stored file: %w", err)
+	}
+
+	localValues, err := godotenv.Unmarshal(string(local))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the local file: %w", err)
+	}
+
+	report := &diffReport{Name: name, Local: localPath, Kind: diffKindDotenv, Changes: []diffChange{}}
+
+	for _, change := range diff.Maps(remoteValues, localValues) {
+		if !showValues {
+			change = maskChange(change)
+		}
+
+		report.Changes = append(report.Changes, diffChange(change))
+	}
+
+	report.Identical = len(report.Changes) == 0
+	return report, nil
+}
+
+// maskChange hides the values the change has, even empty ones
+func maskChange(change diff.Change) diff.Change {
+	if change.Type != diff.Added {
+		change.OldValue = maskedValue
+	}
+	if change.Type != diff.Removed {
+		change.NewValue = maskedValue
+	}
+
+	return change
+}
+
+func diffLines(name, localPath string, remote, local []byte) *diffReport {
+	report := &diffReport{Name: name, Local: localPath, Kind: diffKindText, Changes: []diffChange{}}
+
+	if bytes.Equal(remote, local) {
+		report.Identical = true
+		return report
+	}
+
+	report.Unified, report.unifiedErr = diff.Unified(name+" (stored)", localPath+" (local)", string(remote), string(local), 3)
+	return report
+}
+
+func diffTrees(name, localDir string, remote []byte) (*diffReport, error) {
+	remoteHashes := map[string]string{}
+	if len(remote) > 0 {
+		var err error
+		remoteHashes, err = zipTreeHashes(remote)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the stored archive: %w", err)
+		}
+	}
+
+	localHashes, err := dirTreeHashes(localDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the local directory: %w", err)
+	}
+
+	report := &diffReport{Name: name, Local: localDir, Kind: diffKindTree, Changes: []diffChange{}}
+
+	// The hashes only tell whether the content changed
+	for _, change := range diff.Maps(remoteHashes, localHashes) {
+		report.Changes = append(report.Changes, diffChange{Type: change.Type, Key: change.Key})
+	}
+
+	report.Identical = len(report.Changes) == 0
+	return report, nil
+}
+
+// writeDiff prints report the way people read it
+func writeDiff(w io.Writer, report *diffReport) {
+	if report.Identical {
+		fmt.Fprintln(w, "🥳 No differences found")
+		return
+	}
+
+	if report.Kind == diffKindText {
+		if report.unifiedErr != nil {
+			fmt.Fprintf(w, "🚧 The files differ: %v\n", report.unifiedErr)
+			return
+		}
+
+		fmt.Fprint(w, report.Unified)
+		return
+	}
+
+	for _, change := range report.Changes {
+		value := ""
+		if report.Kind == diffKindDotenv {
+			switch change.Type {
+			case diff.Added:
+				value = "=" + change.NewValue
+			case diff.Removed:
+				value = "=" + change.OldValue
+			case diff.Changed:
+				value = "=" + change.OldValue + " -> " + change.NewValue
+			}
+		}
+
+		switch change.Type {
+		case diff.Added:
+			fmt.Fprintf(w, "+ %s%s\n", change.Key, value)
+		case diff.Removed:
+			fmt.Fprintf(w, "- %s%s\n", change.Key, value)
+		case diff.Changed:
+			fmt.Fprintf(w, "~ %s%s\n", change.Key, value)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDiffDotenv(t *testing.T) {
+	cli, _ := newTestCLI(t)
+
+	if err := cli.execute("up", writeTestFile(t, "app.env", "A=1\nB=2\nC=3\n"), "--name", "app"); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		local string
+		args  []string
+		want  string
+	}{
+		{
+			name:  "values are masked",
+			local: "# comments don't count\nA=1\nB=two\nD=4\n",
+			want:  "~ B=*** -> ***\n- C=***\n+ D=***\n",
+		},
+		{
+			name:  "values are shown on demand",
+			local: "A=1\nB=two\nD=4\n",
+			args:  []string{"--show-values"},
+			want:  "~ B=2 -> two\n- C=3\n+ D=4\n",
+		},
+		{
+			name:  "same variables written differently",
+			local: "export A=1\nB='2'\nC=\"3\"\n",
+			want:  "🥳 No differences found\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			localPath := writeTestFile(t, ".env", tt.local)
+
+			output, err := captureStdout(t, func() error {
+				return cli.execute("diff", append([]string{"app.env", localPath}, tt.args...)...)
+			})
+			if err != nil {
+				t.Fatalf("diff: %v", err)
+			}
+			if output != tt.want {
+				t.Errorf("diff printed %q, want %q", output, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffText(t *testing.T) {
+	cli, _ := newTestCLI(t)
+
+	if err := cli.execute("up", writeTestFile(t, "nginx.conf", "listen 80;\nroot /srv;\n"), "--name", "nginx"); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+
+	localPath := writeTestFile(t, "nginx.conf", "listen 443;\nroot /srv;\n")
+	output, err := captureStdout(t, func() error { return cli.execute("diff", "nginx.conf", localPath) })
+	if err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+
+	for _, line := range []string{"-listen 80;\n", "+listen 443;\n", " root /srv;\n"} {
+		if !strings.Contains(output, line) {
+			t.Errorf("diff printed %q, want the line %q", output, line)
+		}
+	}
+}
+
+func TestDiffTree(t *testing.T) {
+	cli, _ := newTestCLI(t)
+
+	dir := filepath.Join(t.TempDir(), "certs")
+	files := map[string]string{"ca.pem": "ca", "server/key.pem": "key", "server/cert.pem": "cert"}
+	for name, content := range files {
+		filePath := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			t.Fatalf("MkdirAll: %v", err)
+		}
+		if err := os.WriteFile(filePath, []byte(content), 0600); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+
+	if err := cli.execute("up", dir, "-r", "--name", "certs"); err != nil {
+		t.Fatalf("up -r: %v", err)
+	}
+
+	output, err := captureStdout(t, func() error { return cli.execute("diff", "certs.zip", dir) })
+	if err != nil || output != "🥳 No differences found\n" {
+		t.Fatalf("diff of the uploaded directory printed %q (%v), want no differences", output, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "ca.pem"), []byte("rotated"), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := os.Remove(filepath.Join(dir, "server", "key.pem")); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "server", "new.pem"), []byte("new"), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	output, err = captureStdout(t, func() error { return cli.execute("diff", "certs.zip", dir) })
+	if err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+	if want := "~ ca.pem\n- server/key.pem\n+ server/new.pem\n"; output != want {
+		t.Errorf("diff printed %q, want %q", output, want)
+	}
+}
+
+func TestDiffMissingStoredFile(t *testing.T) {
+	cli, _ := newTestCLI(t)
+
+	// Nothing stored yet, every variable is added
+	output, err := captureStdout(t, func() error {
+		return cli.execute("diff", "new.env", writeTestFile(t, "new.env", "A=1\n"))
+	})
+	if err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+	if output != "+ A=***\n" {
+		t.Errorf("diff printed %q, want %q", output, "+ A=***\n")
+	}
+}
+
+func TestIsDotenvFile(t *testing.T) {
+	tests := map[string]bool{
+		".env":              true,
+		".env.production":   true,
+		"production.env":    true,
+		"team/prod.env":     true,
+		"config/.env.test":  true,
+		"environment.json":  false,
+		"certs.zip":         false,
+		"settings.env.json": false,
+		"nginx.conf":        false,
+	}
+
+	for name, want := range tests {
+		if got := isDotenvFile(name); got != want {
+			t.Errorf("isDotenvFile(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
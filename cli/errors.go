@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/robertokbr/denv/bucket"
+)
+
+// Exit codes of denv, scripts can rely on them to tell failures apart
+const (
+	ExitOK            = 0
+	ExitError         = 1
+	ExitUsage         = 2
+	ExitNotConfigured = 3
+	ExitNotFound      = 4
+	ExitAccessDenied  = 5
+	ExitConflict      = 6
+	ExitNetwork       = 7
+)
+
+// usageError is a command called with wrong arguments, its message was
+// already shown with the usage of the command
+type usageError struct {
+	message string
+}
+
+func (e *usageError) Error() string {
+	return e.message
+}
+
+// exitError ends denv with code once the reason was already shown, such as
+// the exit code of the command started by denv run
+type exitError struct {
+	code int
+}
+
+func (e *exitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+// ExitCode maps err to the exit code documented for its kind
+func ExitCode(err error) int {
+	var usage *usageError
+	var exit *exitError
+
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.As(err, &exit):
+		return exit.code
+	case errors.As(err, &usage):
+		return ExitUsage
+	case errors.Is(err, bucket.ErrNotConfigured):
+		return ExitNotConfigured
+	case errors.Is(err, bucket.ErrNotFound):
+		return ExitNotFound
+	case errors.Is(err, bucket.ErrAccessDenied):
+		return ExitAccessDenied
+	case errors.Is(err, bucket.ErrConflict):
+		return ExitConflict
+	case errors.Is(err, bucket.ErrNetwork):
+		return ExitNetwork
+	default:
+		return ExitError
+	}
+}
+
+// isReported tells whether err was already shown to the user
+func isReported(err error) bool {
+	var usage *usageError
+	var exit *exitError
+
+	return errors.As(err, &usage) || errors.As(err, &exit)
+}
@@ -0,0 +1,95 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/robertokbr/denv/bucket"
+	"github.com/robertokbr/denv/config"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"success", nil, ExitOK},
+		{"plain error", errors.New("boom"), ExitError},
+		{"usage", fmt.Errorf("wrapped: %w", &usageError{message: "wrong"}), ExitUsage},
+		{"exit status of a command", &exitError{code: 42}, 42},
+		{"not configured", &bucket.Error{Kind: bucket.ErrNotConfigured, Err: errors.New("x")}, ExitNotConfigured},
+		{"not found", fmt.Errorf("failed to download: %w", &bucket.Error{Kind: bucket.ErrNotFound, Err: errors.New("x")}), ExitNotFound},
+		{"access denied", &bucket.Error{Kind: bucket.ErrAccessDenied, Err: errors.New("x")}, ExitAccessDenied},
+		{"conflict", &bucket.Error{Kind: bucket.ErrConflict, Err: errors.New("x")}, ExitConflict},
+		{"network", &bucket.Error{Kind: bucket.ErrNetwork, Err: errors.New("x")}, ExitNetwork},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExitCode(tt.err); got != tt.want {
+				t.Errorf("ExitCode(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnknownCommandIsUsageError(t *testing.T) {
+	cli, _ := newTestCLI(t)
+
+	err := cli.execute("nope")
+	if code := ExitCode(err); code != ExitUsage {
+		t.Errorf("unknown command exited with %d, want %d", code, ExitUsage)
+	}
+}
+
+func TestMissingFilesExitNotFound(t *testing.T) {
+	cli, _ := newTestCLI(t)
+
+	tests := []struct {
+		command string
+		args    []string
+	}{
+		{"get", []string{"missing.env", "--out", filepath.Join(t.TempDir(), "out.env")}},
+		{"get", []string{"missing.env", "KEY"}},
+		{"unset", []string{"missing.env", "KEY"}},
+		{"export", []string{"missing.env", "--format", "json"}},
+		{"rm", []string{"missing.env"}},
+		{"history", []string{"missing.env"}},
+	}
+
+	for _, tt := range tests {
+		err := cli.execute(tt.command, tt.args...)
+		if code := ExitCode(err); code != ExitNotFound {
+			t.Errorf("denv %s %s exited with %d (%v), want %d", tt.command, strings.Join(tt.args, " "), code, err, ExitNotFound)
+		}
+	}
+}
+
+func TestMissingSettingsExitNotConfigured(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+	}{
+		{"no local directory", map[string]string{"DENV_LOCAL_PATH": ""}},
+		{"unknown backend", map[string]string{"DENV_BACKEND": "ftp"}},
+		{"no encryption key", map[string]string{"DENV_ENCRYPTION": config.EncryptionKeyFile}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cli, _ := newTestCLI(t)
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			err := cli.execute("ls")
+			if code := ExitCode(err); code != ExitNotConfigured {
+				t.Errorf("ls exited with %d (%v), want %d", code, err, ExitNotConfigured)
+			}
+		})
+	}
+}
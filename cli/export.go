@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/robertokbr/denv/dotenv"
+)
+
+func newExportCommand(cli *CLI) Command {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := flags.String("format", "", "One of: "+strings.Join(dotenv.ExportFormats, ", "))
+	output := flags.String("out", "", "Write to this file instead of the standard output")
+
+	return Command{
+		Name:        "export",
+		Usage:       "denv export [file nickname] --format [format] [--out file]",
+		Description: "Convert a stored env file to another format",
+		Flags:       flags,
+		Execute: func() error {
+			args, err := parseArgs(flags, cli.args)
+			if err != nil {
+				return err
+			}
+
+			if len(args) != 1 || *format == "" {
+				return printCommandError("🌝 Usage: denv export [file nickname] --format [%s] [--out file]", strings.Join(dotenv.ExportFormats, "|"))
+			}
+
+			return cli.handleExport(args[0], *format, *output)
+		},
+	}
+}
+
+func (cli *CLI) handleExport(name, format, output string) error {
+	return cli.executeWithValidation(func() error {
+		doc, err := cli.loadDocument(name)
+		if err != nil {
+			return err
+		}
+
+		content, err := dotenv.Export(doc, format, name)
+		if err != nil {
+			return fmt.Errorf("failed to export %s: %w", name, err)
+		}
+
+		if output == "" {
+			_, err = os.Stdout.Write(content)
+			return err
+		}
+
+		// The output holds secrets, keep it private to the current user
+		err = os.WriteFile(output, content, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", output, err)
+		}
+
+		fmt.Fprintf(messages, "🥳 %s exported to %s!!!\n", name, output)
+		return nil
+	})
+}
@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExport(t *testing.T) {
+	cli, _ := newTestCLI(t)
+
+	if err := cli.execute("set", "app.env", "A=1", "B=it's"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+
+	output, err := captureStdout(t, func() error { return cli.execute("export", "app.env", "--format", "shell") })
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if want := "export A='1'\nexport B='it'\\''s'\n"; output != want {
+		t.Errorf("export printed %q, want %q", output, want)
+	}
+
+	// Written files hold secrets and are kept private
+	outPath := filepath.Join(t.TempDir(), "app.json")
+	if err := cli.execute("export", "app.env", "--format", "json", "--out", outPath); err != nil {
+		t.Fatalf("export --out: %v", err)
+	}
+
+	info, err := os.Stat(outPath)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("%s mode = %o, want 600", outPath, mode)
+	}
+	if content, _ := os.ReadFile(outPath); string(content) != "{\n  \"A\": \"1\",\n  \"B\": \"it's\"\n}\n" {
+		t.Errorf("%s = %q, want the JSON export", outPath, content)
+	}
+}
+
+func TestExportErrors(t *testing.T) {
+	cli, storeDir := newTestCLI(t)
+
+	// Keys no shell accepts can come from files uploaded as they are
+	if err := os.WriteFile(filepath.Join(storeDir, "app.env"), []byte("db.host=localhost\nMULTI=\"a\nb\"\n"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+	}{
+		{"no format", []string{"app.env"}, ExitUsage},
+		{"no file", []string{"--format", "json"}, ExitUsage},
+		{"unknown format", []string{"app.env", "--format", "toml"}, ExitError},
+		{"invalid shell name", []string{"app.env", "--format", "shell"}, ExitError},
+		{"invalid systemd name", []string{"app.env", "--format", "systemd"}, ExitError},
+		{"multi-line docker env", []string{"app.env", "--format", "docker-env"}, ExitError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outPath := filepath.Join(t.TempDir(), "out")
+
+			err := cli.execute("export", append(tt.args, "--out", outPath)...)
+			if code := ExitCode(err); code != tt.wantCode {
+				t.Errorf("export exit code = %d (%v), want %d", code, err, tt.wantCode)
+			}
+			if _, err := os.Stat(outPath); !os.IsNotExist(err) {
+				t.Errorf("a failed export wrote %s: %v", outPath, err)
+			}
+		})
+	}
+}
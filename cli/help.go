@@ -4,23 +4,28 @@ import (
 	"fmt"
 )
 
-func PrintHelp() {
-	fmt.Println("denv --config to start the CLI configuration")
-	fmt.Println("denv --up [file path] --name [file nickname] to upload some env file")
-	fmt.Println("denv --name [file nickname] to download some env file you have uploaded")
-	fmt.Println("denv --name [file nickname] --out [file name] to download some env file you have uploaded with some specific name")
-	fmt.Println("denv --list to list all files in the bucket")
-	fmt.Println("denv --del [file nickname] to delete some file in the bucket")
-	fmt.Println("denv --rename [file nickname] --name [new nickname] to rename a file in the bucket")
-	fmt.Println("denv --setup-completion to install tab completion for commands (bash)")
+func PrintHelp(commands []Command) {
+	fmt.Println("Usage: denv [command] [arguments]")
+	fmt.Println()
+	fmt.Println("Commands:")
+
+	for _, cmd := range commands {
+		fmt.Printf("  %-12s %s\n", cmd.Name, cmd.Description)
+	}
+
+	fmt.Println()
+	fmt.Println("Type denv help [command] to see how to use a command and its flags.")
+	fmt.Println("Add --output json to any command for machine-readable results, or --quiet to hide the progress messages.")
+	fmt.Println("Add --profile [name] to any command to use the settings of another profile.")
+	fmt.Println("denv --setup-completion to install tab completion for commands (zsh)")
 }
 
 func PrintSetupMessage() {
-	fmt.Println("🤔 Hello! Type 'denv --setup' to start setting up the application")
+	fmt.Fprintln(notices, "🤔 Hello! Type 'denv config' to start setting up the application")
 }
 
 func PrintSuccessConfig() {
 	fmt.Println("🔥 Thank you! Everything is right!")
-	fmt.Println("🤓 Type denv --help if you want to see how to use the CLI.")
-	fmt.Println("🫢 Type denv --config again if the CLI is not working properly.")
+	fmt.Println("🤓 Type denv help if you want to see how to use the CLI.")
+	fmt.Println("🫢 Type denv config again if the CLI is not working properly.")
 }
\ No newline at end of file
@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/robertokbr/denv/bucket"
+)
+
+func newHistoryCommand(cli *CLI) Command {
+	return Command{
+		Name:        "history",
+		Usage:       "denv history [file nickname]",
+		Description: "List the previous versions of a file",
+		Execute: func() error {
+			if len(cli.args) != 1 {
+				return printCommandError("🌝 Usage: denv history [file nickname]")
+			}
+
+			return cli.handleHistory()
+		},
+	}
+}
+
+func newRollbackCommand(cli *CLI) Command {
+	return Command{
+		Name:        "rollback",
+		Usage:       "denv rollback [file nickname] [version]",
+		Description: "Restore a previous version of a file",
+		Execute: func() error {
+			if len(cli.args) != 2 {
+				return printCommandError("🌝 Usage: denv rollback [file nickname] [version]")
+			}
+
+			return cli.handleRollback()
+		},
+	}
+}
+
+func (cli *CLI) handleHistory() error {
+	return cli.executeWithValidation(func() error {
+		name := cli.args[0]
+		if !cli.jsonOutput() {
+			return bucket.PrintHistory(cli.store, name)
+		}
+
+		versions, err := bucket.History(cli.store, name)
+		if err != nil {
+			return fmt.Errorf("failed to read the history: %w", err)
+		}
+
+		if len(versions) == 0 {
+			return &bucket.Error{Kind: bucket.ErrNotFound, Err: fmt.Errorf("no versions found for %s", name)}
+		}
+
+		list := make([]versionJSON, 0, len(versions))
+		for _, version := range versions {
+			list = append(list, versionJSON{Number: version.Number, Current: version.Current, objectJSON: newObjectJSON(version.ObjectInfo)})
+		}
+
+		return printJSON(list)
+	})
+}
+
+func (cli *CLI) handleRollback() error {
+	return cli.executeWithValidation(func() error {
+		version, err := strconv.Atoi(cli.args[1])
+		if err != nil || version < 1 {
+			return printCommandError("🌝 The version must be one of the numbers listed by denv history")
+		}
+
+		return bucket.Rollback(cli.store, cli.args[0], version)
+	})
+}
@@ -0,0 +1,92 @@
+package cli
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDeleteAndRollback(t *testing.T) {
+	cli, storeDir := newTestCLI(t)
+
+	if err := cli.execute("set", "app.env", "A=1"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+
+	if err := cli.execute("rm", "app.env"); err != nil {
+		t.Fatalf("rm: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(storeDir, "app.env")); !os.IsNotExist(err) {
+		t.Fatalf("app.env still exists after rm: %v", err)
+	}
+
+	// The deleted file can be brought back from its history
+	if err := cli.execute("rollback", "app.env", "1"); err != nil {
+		t.Fatalf("rollback: %v", err)
+	}
+
+	output, err := captureStdout(t, func() error { return cli.execute("get", "app.env", "A") })
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if output != "1\n" {
+		t.Errorf("get A after rollback printed %q, want %q", output, "1\n")
+	}
+}
+
+func TestHistoryAndRollback(t *testing.T) {
+	cli, _ := newTestCLI(t)
+
+	// Quick edits share their timestamp and must still be kept apart
+	for _, value := range []string{"A=1", "A=2", "A=3"} {
+		if err := cli.execute("set", "app.env", value); err != nil {
+			t.Fatalf("set %s: %v", value, err)
+		}
+	}
+
+	history := func() []versionJSON {
+		t.Helper()
+
+		cli.flagOutputFormat = OutputJSON
+		defer func() { cli.flagOutputFormat = OutputText }()
+
+		output, err := captureStdout(t, func() error { return cli.execute("history", "app.env") })
+		if err != nil {
+			t.Fatalf("history: %v", err)
+		}
+
+		var versions []versionJSON
+		if err := json.Unmarshal([]byte(output), &versions); err != nil {
+			t.Fatalf("history printed %q: %v", output, err)
+		}
+		return versions
+	}
+
+	versions := history()
+	if len(versions) != 3 || !versions[2].Current || versions[0].Current {
+		t.Fatalf("history = %+v, want 3 versions with the last one current", versions)
+	}
+
+	if err := cli.execute("rollback", "app.env", "1"); err != nil {
+		t.Fatalf("rollback: %v", err)
+	}
+
+	output, err := captureStdout(t, func() error { return cli.execute("get", "app.env", "A") })
+	if err != nil || output != "1\n" {
+		t.Errorf("get A after rollback printed %q (%v), want %q", output, err, "1\n")
+	}
+
+	// The rollback is a new version, what it replaced can be restored too
+	if versions := history(); len(versions) != 4 {
+		t.Errorf("history after rollback has %d versions, want 4", len(versions))
+	}
+
+	tests := map[string]int{"0": ExitUsage, "latest": ExitUsage, "5": ExitNotFound}
+	for version, wantCode := range tests {
+		err := cli.execute("rollback", "app.env", version)
+		if code := ExitCode(err); code != wantCode {
+			t.Errorf("rollback to %s exited with %d (%v), want %d", version, code, err, wantCode)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/robertokbr/denv/bucket"
+	"github.com/robertokbr/denv/config"
+	"github.com/robertokbr/denv/dotenv"
+)
+
+func newImportCommand(cli *CLI) Command {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := flags.String("from", "", "One of: "+strings.Join(dotenv.ImportFormats, ", "))
+	name := flags.String("name", "", "Nickname of the stored env file")
+	flags.BoolVar(&cli.flagForce, "force", false, "Upload even if the file was changed in the bucket since it was last synced")
+
+	return Command{
+		Name:        "import",
+		Usage:       "denv import --from [format] [file] --name [file nickname] [--force]",
+		Description: "Store secrets written in another format as an env file",
+		Flags:       flags,
+		Execute: func() error {
+			args, err := parseArgs(flags, cli.args)
+			if err != nil {
+				return err
+			}
+
+			if len(args) != 1 || *format == "" || *name == "" {
+				return printCommandError("🌝 Usage: denv import --from [%s] [file] --name [file nickname]", strings.Join(dotenv.ImportFormats, "|"))
+			}
+
+			return cli.handleImport(args[0], *format, *name)
+		},
+	}
+}
+
+func (cli *CLI) handleImport(filePath, format, name string) error {
+	return cli.executeWithValidation(func() error {
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", filePath, err)
+		}
+
+		doc, err := dotenv.Import(content, format, config.LookupUserEnv)
+		if err != nil {
+			return fmt.Errorf("failed to import %s: %w", filePath, err)
+		}
+
+		// Imported files are always dotenv files
+		if filepath.Ext(name) == "" {
+			name += ".env"
+		}
+
+		fmt.Fprintf(messages, "📥 %d variables read from %s\n", len(doc.Keys()), filePath)
+
+		// The source isn't a dotenv file, so there is nothing to compare or merge
+		err = cli.checkConflict(name, "")
+		if err != nil {
+			return err
+		}
+
+		info, err := bucket.Upload(cli.store, bytes.NewReader(doc.Bytes()), name)
+		if err != nil {
+			return err
+		}
+
+		cli.recordSync(name, info)
+		return nil
+	})
+}
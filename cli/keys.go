@@ -0,0 +1,134 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/robertokbr/denv/bucket"
+	"github.com/robertokbr/denv/dotenv"
+)
+
+func newSetKeyCommand(cli *CLI) Command {
+	return Command{
+		Name:        "set",
+		Usage:       "denv set [file nickname] [KEY=VALUE]...",
+		Description: "Set variables of a stored env file",
+		Execute: func() error {
+			if len(cli.args) < 2 {
+				return printCommandError("🌝 Usage: denv set [file nickname] [KEY=VALUE]...")
+			}
+
+			return cli.handleSetKeys(cli.args[0], cli.args[1:])
+		},
+	}
+}
+
+func newUnsetKeyCommand(cli *CLI) Command {
+	return Command{
+		Name:        "unset",
+		Usage:       "denv unset [file nickname] [KEY]...",
+		Description: "Remove variables from a stored env file",
+		Execute: func() error {
+			if len(cli.args) < 2 {
+				return printCommandError("🌝 Usage: denv unset [file nickname] [KEY]...")
+			}
+
+			return cli.handleUnsetKeys(cli.args[0], cli.args[1:])
+		},
+	}
+}
+
+func (cli *CLI) handleGetKey(name, key string) error {
+	return cli.executeWithValidation(func() error {
+		doc, err := cli.loadDocument(name)
+		if err != nil {
+			return err
+		}
+
+		value, exists := doc.Get(key)
+		if !exists {
+			fmt.Fprintf(notices, "🌝 %s is not set in %s\n", key, name)
+			return &exitError{code: ExitNotFound}
+		}
+
+		// Print the bare value so it can be used in scripts
+		fmt.Println(value)
+		return nil
+	})
+}
+
+func (cli *CLI) handleSetKeys(name string, assignments []string) error {
+	return cli.executeWithValidation(func() error {
+		doc, err := cli.loadDocument(name)
+		// Setting a variable of a missing file creates it
+		if bucket.IsNotFound(err) {
+			doc, err = dotenv.Parse(nil)
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, assignment := range assignments {
+			key, value, found := strings.Cut(assignment, "=")
+			if !found {
+				return printCommandError("🌝 %s is not a KEY=VALUE assignment", assignment)
+			}
+
+			err := doc.Set(key, value)
+			if err != nil {
+				return printCommandError("🌝 %v", err)
+			}
+		}
+
+		_, err = bucket.Upload(cli.store, bytes.NewReader(doc.Bytes()), name)
+		return err
+	})
+}
+
+func (cli *CLI) handleUnsetKeys(name string, keys []string) error {
+	return cli.executeWithValidation(func() error {
+		doc, err := cli.loadDocument(name)
+		if err != nil {
+			return err
+		}
+
+		removed := 0
+		for _, key := range keys {
+			if doc.Unset(key) {
+				removed++
+			}
+		}
+
+		if removed == 0 {
+			fmt.Fprintf(notices, "🌝 None of the variables are set in %s\n", name)
+			return nil
+		}
+
+		_, err = bucket.Upload(cli.store, bytes.NewReader(doc.Bytes()), name)
+		return err
+	})
+}
+
+// loadDocument downloads a stored dotenv file into memory, a missing file
+// fails with bucket.ErrNotFound
+func (cli *CLI) loadDocument(name string) (*dotenv.Document, error) {
+	body, _, err := cli.store.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", name, err)
+	}
+	defer body.Close()
+
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", name, err)
+	}
+
+	doc, err := dotenv.Parse(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+
+	return doc, nil
+}
@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSetAndGetKeys(t *testing.T) {
+	cli, _ := newTestCLI(t)
+
+	// Setting a variable of a missing file creates it
+	if err := cli.execute("set", "keys.env", "A=1", "B=two words"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+
+	output, err := captureStdout(t, func() error { return cli.execute("get", "keys.env", "B") })
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if output != "two words\n" {
+		t.Errorf("get B printed %q, want %q", output, "two words\n")
+	}
+
+	if err := cli.execute("unset", "keys.env", "B"); err != nil {
+		t.Fatalf("unset: %v", err)
+	}
+
+	err = cli.execute("get", "keys.env", "B")
+	if code := ExitCode(err); code != ExitNotFound {
+		t.Errorf("get of an unset key exited with %d, want %d", code, ExitNotFound)
+	}
+}
+
+func TestSetKeepsTheRestOfTheFile(t *testing.T) {
+	cli, storeDir := newTestCLI(t)
+
+	original := "# database\nDB_HOST=localhost # local only\nexport DB_PORT=5432\n"
+	if err := cli.execute("up", writeTestFile(t, "app.env", original), "--name", "app"); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+
+	if err := cli.execute("set", "app.env", "DB_PORT=6543", "NEW=a b"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+
+	want := "# database\nDB_HOST=localhost # local only\nexport DB_PORT=6543\nNEW='a b'\n"
+	if content, _ := os.ReadFile(filepath.Join(storeDir, "app.env")); string(content) != want {
+		t.Errorf("stored %q, want %q", content, want)
+	}
+}
+
+func TestSetWrongUsage(t *testing.T) {
+	cli, storeDir := newTestCLI(t)
+
+	if err := cli.execute("set", "app.env", "A=1"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		command string
+		args    []string
+	}{
+		{"no assignment", "set", []string{"app.env"}},
+		{"not an assignment", "set", []string{"app.env", "B"}},
+		{"invalid key", "set", []string{"app.env", "B=2", "WITH SPACE=3"}},
+		{"unset without keys", "unset", []string{"app.env"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := cli.execute(tt.command, tt.args...)
+			if code := ExitCode(err); code != ExitUsage {
+				t.Errorf("%s exit code = %d (%v), want %d", tt.command, code, err, ExitUsage)
+			}
+
+			// Nothing is uploaded when one of the assignments is wrong
+			if content, _ := os.ReadFile(filepath.Join(storeDir, "app.env")); string(content) != "A=1\n" {
+				t.Errorf("stored %q, want it unchanged", content)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ManifestFileName is the project file listing the stored files a checkout needs
+const ManifestFileName = ".denv.yaml"
+
+// ManifestEntry maps a file nickname to its path in the project
+type ManifestEntry struct {
+	Name string
+	Path string
+}
+
+// Manifest is a parsed .denv.yaml, entries keep the order of the file and
+// their paths are absolute
+type Manifest struct {
+	Dir     string
+	Entries []ManifestEntry
+}
+
+type manifestFile struct {
+	Files yaml.Node `yaml:"files"`
+}
+
+// findManifest looks for the manifest in dir and then in its parents, like git
+// does for its repository
+func findManifest(dir string) (string, error) {
+	for {
+		manifestPath := filepath.Join(dir, ManifestFileName)
+		if _, err := os.Stat(manifestPath); err == nil {
+			return manifestPath, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("no %s found in this directory or its parents", ManifestFileName)
+		}
+		dir = parent
+	}
+}
+
+// loadManifest reads the manifest of the project the current directory belongs to
+func loadManifest() (*Manifest, error) {
+	currentPath, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the current path: %v", err)
+	}
+
+	manifestPath, err := findManifest(currentPath)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", manifestPath, err)
+	}
+
+	manifest, err := parseManifest(content, filepath.Dir(manifestPath))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", manifestPath, err)
+	}
+
+	return manifest, nil
+}
+
+// parseManifest reads the files mapping, paths are relative to dir
+func parseManifest(content []byte, dir string) (*Manifest, error) {
+	var file manifestFile
+	if err := yaml.Unmarshal(content, &file); err != nil {
+		return nil, err
+	}
+
+	if file.Files.Kind != yaml.MappingNode {
+		return nil, errors.New("files must map each file nickname to a local path")
+	}
+
+	manifest := &Manifest{Dir: dir}
+	seen := make(map[string]bool)
+
+	// Mapping nodes alternate keys and values
+	for i := 0; i+1 < len(file.Files.Content); i += 2 {
+		name := strings.TrimSpace(file.Files.Content[i].Value)
+		localPath := strings.TrimSpace(file.Files.Content[i+1].Value)
+
+		if name == "" || localPath == "" || file.Files.Content[i+1].Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("line %d: every file needs a nickname and a local path", file.Files.Content[i].Line)
+		}
+
+		if seen[name] {
+			return nil, fmt.Errorf("line %d: %s is listed twice", file.Files.Content[i].Line, name)
+		}
+		seen[name] = true
+
+		// A cloned manifest must not write files outside of its project
+		if filepath.IsAbs(localPath) || !isInsideDir(dir, filepath.Join(dir, localPath)) {
+			return nil, fmt.Errorf("line %d: %s is outside of the project, paths must be relative to %s", file.Files.Content[i+1].Line, localPath, ManifestFileName)
+		}
+
+		manifest.Entries = append(manifest.Entries, ManifestEntry{Name: name, Path: filepath.Join(dir, localPath)})
+	}
+
+	return manifest, nil
+}
+
+// isInsideDir tells whether target is dir or one of its descendants
+func isInsideDir(dir, target string) bool {
+	relative, err := filepath.Rel(dir, target)
+	if err != nil {
+		return false
+	}
+
+	return relative != ".." && !strings.HasPrefix(relative, ".."+string(filepath.Separator))
+}
+
+// selectEntries keeps the entries named in names, or all of them when names is empty
+func (manifest *Manifest) selectEntries(names []string) ([]ManifestEntry, error) {
+	if len(names) == 0 {
+		return manifest.Entries, nil
+	}
+
+	var selected []ManifestEntry
+	for _, name := range names {
+		found := false
+		for _, entry := range manifest.Entries {
+			if entry.Name == name {
+				selected = append(selected, entry)
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("%s is not listed in %s", name, ManifestFileName)
+		}
+	}
+
+	return selected, nil
+}
+
+// isArchive tells whether the entry is a directory stored as a zip
+func (entry ManifestEntry) isArchive() bool {
+	return strings.HasSuffix(entry.Name, ".zip")
+}
+
+// relativePath shows the entry path relative to the current directory when possible
+func (entry ManifestEntry) relativePath() string {
+	currentPath, err := os.Getwd()
+	if err != nil {
+		return entry.Path
+	}
+
+	relative, err := filepath.Rel(currentPath, entry.Path)
+	if err != nil {
+		return entry.Path
+	}
+
+	return relative
+}
@@ -0,0 +1,142 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseManifest(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		content string
+		want    []ManifestEntry
+		wantErr bool
+	}{
+		{
+			name:    "entries keep the order of the file",
+			content: "files:\n  b.env: .env\n  certs.zip: config/certs\n  a.env: ./sub/../.env.test\n",
+			want: []ManifestEntry{
+				{Name: "b.env", Path: filepath.Join(dir, ".env")},
+				{Name: "certs.zip", Path: filepath.Join(dir, "config", "certs")},
+				{Name: "a.env", Path: filepath.Join(dir, ".env.test")},
+			},
+		},
+		{
+			name:    "dotted names stay inside",
+			content: "files:\n  a.env: ..env\n",
+			want:    []ManifestEntry{{Name: "a.env", Path: filepath.Join(dir, "..env")}},
+		},
+		{name: "parent directory", content: "files:\n  a.env: ../.env\n", wantErr: true},
+		{name: "escape through a subdirectory", content: "files:\n  a.env: sub/../../.env\n", wantErr: true},
+		{name: "the parent itself", content: "files:\n  a.zip: ..\n", wantErr: true},
+		{name: "absolute path", content: "files:\n  a.env: /etc/passwd\n", wantErr: true},
+		{name: "duplicated nickname", content: "files:\n  a.env: .env\n  a.env: .env.other\n", wantErr: true},
+		{name: "missing path", content: "files:\n  a.env:\n", wantErr: true},
+		{name: "files is a list", content: "files:\n  - a.env\n", wantErr: true},
+		{name: "no files", content: "other: 1\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifest, err := parseManifest([]byte(tt.content), dir)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseManifest = %+v, want an error", manifest.Entries)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseManifest: %v", err)
+			}
+
+			if len(manifest.Entries) != len(tt.want) {
+				t.Fatalf("Entries = %+v, want %+v", manifest.Entries, tt.want)
+			}
+			for i, entry := range manifest.Entries {
+				if entry != tt.want[i] {
+					t.Errorf("Entries[%d] = %+v, want %+v", i, entry, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// chdir moves to dir for the rest of the test
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+
+	previous, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Getwd: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("Chdir: %v", err)
+	}
+	t.Cleanup(func() { os.Chdir(previous) })
+}
+
+func TestPull(t *testing.T) {
+	cli, _ := newTestCLI(t)
+
+	for _, name := range []string{"dev", "test"} {
+		if err := cli.execute("up", writeTestFile(t, name+".env", "ENV="+name+"\n"), "--name", name); err != nil {
+			t.Fatalf("up: %v", err)
+		}
+	}
+
+	project := t.TempDir()
+	manifest := "files:\n  dev.env: .env\n  test.env: config/.env.test\n"
+	if err := os.WriteFile(filepath.Join(project, ManifestFileName), []byte(manifest), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	// The manifest is found from a subdirectory, like git finds its repository
+	subdir := filepath.Join(project, "src", "app")
+	if err := os.MkdirAll(subdir, 0755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	chdir(t, subdir)
+
+	if err := cli.execute("pull"); err != nil {
+		t.Fatalf("pull: %v", err)
+	}
+
+	for localPath, want := range map[string]string{".env": "ENV=dev\n", "config/.env.test": "ENV=test\n"} {
+		content, err := os.ReadFile(filepath.Join(project, localPath))
+		if err != nil || string(content) != want {
+			t.Errorf("%s = %q (%v), want %q", localPath, content, err, want)
+		}
+	}
+
+	if err := cli.execute("pull", "missing.env"); err == nil {
+		t.Error("pull of a file missing from the manifest succeeded")
+	}
+}
+
+func TestPullRefusesEscapingPaths(t *testing.T) {
+	cli, _ := newTestCLI(t)
+
+	if err := cli.execute("up", writeTestFile(t, "dev.env", "A=1\n"), "--name", "dev"); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+
+	parent := t.TempDir()
+	project := filepath.Join(parent, "project")
+	if err := os.Mkdir(project, 0755); err != nil {
+		t.Fatalf("Mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(project, ManifestFileName), []byte("files:\n  dev.env: ../stolen.env\n"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	chdir(t, project)
+
+	if err := cli.execute("pull"); err == nil {
+		t.Error("pull of a manifest escaping its project succeeded")
+	}
+	if _, err := os.Stat(filepath.Join(parent, "stolen.env")); !os.IsNotExist(err) {
+		t.Errorf("a file was written outside of the project: %v", err)
+	}
+}
@@ -0,0 +1,250 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/robertokbr/denv/bucket"
+	"github.com/robertokbr/denv/diff"
+	"github.com/robertokbr/denv/dotenv"
+	"golang.org/x/term"
+)
+
+func newMergeCommand(cli *CLI) Command {
+	flags := flag.NewFlagSet("merge", flag.ContinueOnError)
+	markers := flags.Bool("markers", false, "Write conflict markers instead of asking which side to keep")
+
+	return Command{
+		Name:        "merge",
+		Usage:       "denv merge [file nickname] [local file] [--markers]",
+		Description: "Merge the changes of a stored dotenv file into the local one",
+		Flags:       flags,
+		Execute: func() error {
+			args, err := parseArgs(flags, cli.args)
+			if err != nil {
+				return err
+			}
+
+			if len(args) < 1 || len(args) > 2 {
+				return printCommandError("🌝 Usage: denv merge [file nickname] [local file] [--markers]")
+			}
+
+			name := args[0]
+			localPath := name
+			if len(args) == 2 {
+				localPath = args[1]
+			}
+
+			return cli.handleMerge(name, localPath, *markers)
+		},
+	}
+}
+
+func (cli *CLI) handleMerge(name, localPath string, markers bool) error {
+	return cli.executeWithValidation(func() error {
+		ready, err := cli.mergeInto(name, localPath, markers)
+		if err != nil {
+			return err
+		}
+
+		if !ready {
+			return &exitError{code: ExitConflict}
+		}
+
+		fmt.Fprintf(messages, "🤓 Upload it with: denv up %s --name %s\n", localPath, name)
+		return nil
+	})
+}
+
+// mergeInto does a key level three-way merge of the stored file into the local
+// one, using the version last synced on this machine as the base. Keys changed
+// on both sides are asked for on a terminal or marked in the file otherwise.
+// It reports whether the local file is ready to be uploaded
+func (cli *CLI) mergeInto(name, localPath string, markers bool) (bool, error) {
+	if !isDotenvFile(name) && !isDotenvFile(localPath) {
+		return false, fmt.Errorf("only dotenv files can be merged, compare %s with denv diff instead", name)
+	}
+
+	fileStat, err := os.Stat(localPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	localContent, err := os.ReadFile(localPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	if dotenv.HasConflictMarkers(localContent) {
+		return false, fmt.Errorf("%s still has conflict markers, keep one side of each before merging again", localPath)
+	}
+
+	local, err := dotenv.Parse(localContent)
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", localPath, err)
+	}
+
+	body, remoteInfo, err := cli.store.Get(name)
+	if bucket.IsNotFound(err) {
+		fmt.Fprintf(messages, "🌝 %s is not in the bucket, there is nothing to merge\n", name)
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to download the file: %w", err)
+	}
+
+	remoteContent, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		return false, fmt.Errorf("failed to download the file: %w", err)
+	}
+
+	baseValues, err := cli.mergeBase(name)
+	if err != nil {
+		return false, err
+	}
+
+	localValues, err := local.Values()
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", localPath, err)
+	}
+
+	remoteValues, err := parseValues(remoteContent)
+	if err != nil {
+		return false, fmt.Errorf("failed to read the stored %s: %w", name, err)
+	}
+
+	changes, conflicts := diff.Merge3(baseValues, localValues, remoteValues)
+
+	for _, change := range changes {
+		err := applyChange(local, change.Key, diff.Side{Value: change.NewValue, Exists: change.Type != diff.Removed})
+		if err != nil {
+			return false, err
+		}
+	}
+
+	interactive := !markers && term.IsTerminal(int(os.Stdin.Fd()))
+	marked := 0
+
+	for _, conflict := range conflicts {
+		if !interactive {
+			local.MarkConflict(conflict.Key, conflict.Remote.Value, conflict.Remote.Exists)
+			marked++
+			continue
+		}
+
+		keepRemote, err := askConflictSide(conflict)
+		if err != nil {
+			return false, err
+		}
+
+		if keepRemote {
+			err := applyChange(local, conflict.Key, conflict.Remote)
+			if err != nil {
+				return false, err
+			}
+		}
+	}
+
+	err = os.WriteFile(localPath, local.Bytes(), fileStat.Mode().Perm())
+	if err != nil {
+		return false, fmt.Errorf("failed to write %s: %w", localPath, err)
+	}
+
+	// The local file now holds everything the stored one has
+	cli.recordSync(name, remoteInfo)
+
+	fmt.Fprintf(messages, "🥳 %d changes of %s merged into %s!!!\n", len(changes), name, localPath)
+
+	if marked > 0 {
+		fmt.Fprintf(notices, "🚧 %d keys were changed on both sides, keep one side of each in %s\n", marked, localPath)
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// mergeBase returns the values of the version last synced on this machine, or
+// no values when it is unknown, which turns every difference into a conflict
+func (cli *CLI) mergeBase(name string) (map[string]string, error) {
+	record, exists := cli.lastSynced(name)
+	if !exists {
+		fmt.Fprintf(notices, "🚧 %s was never synced on this machine, every different key is a conflict\n", name)
+		return map[string]string{}, nil
+	}
+
+	content, found, err := bucket.ReadMatchingVersion(cli.store, name, record.ETag, record.VersionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the history of %s: %w", name, err)
+	}
+
+	if !found {
+		fmt.Fprintf(notices, "🚧 The version of %s last synced on this machine is not in its history, every different key is a conflict\n", name)
+		return map[string]string{}, nil
+	}
+
+	values, err := parseValues(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the synced version of %s: %w", name, err)
+	}
+
+	return values, nil
+}
+
+func parseValues(content []byte) (map[string]string, error) {
+	doc, err := dotenv.Parse(content)
+	if err != nil {
+		return nil, err
+	}
+
+	return doc.Values()
+}
+
+func applyChange(doc *dotenv.Document, key string, value diff.Side) error {
+	if !value.Exists {
+		doc.Unset(key)
+		return nil
+	}
+
+	err := doc.Set(key, value.Value)
+	if err != nil {
+		return fmt.Errorf("failed to merge %s: %w", key, err)
+	}
+	return nil
+}
+
+// askConflictSide shows both values of a conflicting key and reports whether
+// the remote one should be kept
+func askConflictSide(conflict diff.Conflict) (bool, error) {
+	fmt.Fprintf(notices, "⚔️  %s was changed on both sides\n", conflict.Key)
+	fmt.Fprintf(notices, "   base:   %s\n", describeSide(conflict.Base))
+	fmt.Fprintf(notices, "   local:  %s\n", describeSide(conflict.Local))
+	fmt.Fprintf(notices, "   remote: %s\n", describeSide(conflict.Remote))
+
+	for {
+		fmt.Fprint(notices, "🤔 Keep [l]ocal or [r]emote? ")
+
+		answer, err := stdinReader.ReadString('\n')
+		if err != nil {
+			return false, &bucket.Error{Kind: bucket.ErrConflict, Err: fmt.Errorf("merge aborted")}
+		}
+
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "l", "local":
+			return false, nil
+		case "r", "remote":
+			return true, nil
+		}
+	}
+}
+
+func describeSide(value diff.Side) string {
+	if !value.Exists {
+		return "(not set)"
+	}
+
+	return dotenv.QuoteValue(value.Value)
+}
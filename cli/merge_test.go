@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/robertokbr/denv/bucket"
+)
+
+func TestMerge(t *testing.T) {
+	cli, _ := newTestCLI(t)
+
+	localPath := writeTestFile(t, "app.env", "A=1\nB=1\nC=1\n")
+	if err := cli.execute("up", localPath, "--name", "app"); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+
+	// A teammate uploads their changes from another machine
+	_, err := bucket.Upload(cli.store, strings.NewReader("A=1\nB=remote\nC=remote\nD=new\n"), "app.env")
+	if err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+
+	if err := os.WriteFile(localPath, []byte("A=local\nB=1\nC=local\n"), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	err = cli.execute("merge", "app.env", localPath, "--markers")
+	if code := ExitCode(err); code != ExitConflict {
+		t.Fatalf("merge exit code = %d (%v), want %d for the key changed on both sides", code, err, ExitConflict)
+	}
+
+	want := "A=local\nB=remote\n<<<<<<< local\nC=local\n=======\nC=remote\n>>>>>>> remote\nD=new\n"
+	if content, _ := os.ReadFile(localPath); string(content) != want {
+		t.Errorf("merged file = %q, want %q", content, want)
+	}
+
+	// Files with conflict markers left can't be uploaded
+	if err := cli.execute("up", localPath, "--name", "app"); err == nil {
+		t.Error("a file with conflict markers was uploaded")
+	}
+
+	if err := os.WriteFile(localPath, []byte("A=local\nB=remote\nC=local\nD=new\n"), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	// The merge took the stored file as synced, so this is no conflict
+	if err := cli.execute("up", localPath, "--name", "app"); err != nil {
+		t.Errorf("up of the resolved file: %v", err)
+	}
+}
+
+func TestMergeOnlyDotenvFiles(t *testing.T) {
+	cli, _ := newTestCLI(t)
+
+	if err := cli.execute("merge", "nginx.conf", writeTestFile(t, "nginx.conf", "listen 80;\n")); err == nil {
+		t.Error("merge of a file that isn't a dotenv file succeeded")
+	}
+}
@@ -0,0 +1,144 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/robertokbr/denv/bucket"
+	"github.com/robertokbr/denv/crypt"
+)
+
+// Output formats of --output
+const (
+	OutputText = "text"
+	OutputJSON = "json"
+)
+
+var (
+	// messages receives the progress of what denv is doing, --quiet drops it
+	messages io.Writer = os.Stdout
+	// notices receives what must be seen even with --quiet, such as wrong
+	// usage, conflicts and questions
+	notices io.Writer = os.Stdout
+)
+
+// objectJSON is a stored file as printed with --output json
+type objectJSON struct {
+	Key          string            `json:"key"`
+	Size         int64             `json:"size"`
+	LastModified time.Time         `json:"last_modified"`
+	ETag         string            `json:"etag,omitempty"`
+	Version      string            `json:"version,omitempty"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+	Owner        string            `json:"owner,omitempty"`
+}
+
+func newObjectJSON(info bucket.ObjectInfo) objectJSON {
+	return objectJSON{
+		Key:          info.Key,
+		Size:         info.Size,
+		LastModified: info.LastModified,
+		ETag:         info.ETag,
+		Version:      info.VersionID,
+		Metadata:     info.Metadata,
+		Owner:        info.Owner,
+	}
+}
+
+// versionJSON is an entry of denv history as printed with --output json
+type versionJSON struct {
+	Number  int  `json:"number"`
+	Current bool `json:"current"`
+	objectJSON
+}
+
+// transferJSON is an uploaded or downloaded file as printed with --output json
+type transferJSON struct {
+	objectJSON
+	Path string `json:"path"`
+}
+
+// extractGlobalFlags takes --output, --quiet and --profile out of the
+// arguments of a command, so they can be given after it as well as before it
+func (cli *CLI) extractGlobalFlags(args []string) ([]string, error) {
+	var rest []string
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		// Arguments after -- belong to another program
+		if arg == "--" {
+			return append(rest, args[i:]...), nil
+		}
+
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") {
+			name = ""
+		}
+
+		switch name {
+		case "quiet":
+			cli.flagQuiet = !hasValue || value == "true"
+		case "output":
+			if !hasValue {
+				if i+1 == len(args) {
+					return nil, printCommandError("🌝 --output needs a format: %s or %s", OutputText, OutputJSON)
+				}
+				i++
+				value = args[i]
+			}
+			cli.flagOutputFormat = value
+		case "profile":
+			if !hasValue {
+				if i+1 == len(args) {
+					return nil, printCommandError("🌝 --profile needs the name of a profile")
+				}
+				i++
+				value = args[i]
+			}
+			cli.flagProfile = value
+		default:
+			rest = append(rest, arg)
+		}
+	}
+
+	return rest, nil
+}
+
+// setupOutput sends the messages meant for people to stderr when stdout
+// carries JSON, and drops the progress ones with --quiet
+func (cli *CLI) setupOutput() error {
+	switch cli.flagOutputFormat {
+	case OutputText:
+	case OutputJSON:
+		messages = os.Stderr
+		notices = os.Stderr
+	default:
+		return printCommandError("🌝 Unknown output format %s, use %s or %s", cli.flagOutputFormat, OutputText, OutputJSON)
+	}
+
+	if cli.flagQuiet {
+		messages = io.Discard
+	}
+
+	bucket.Messages = messages
+	crypt.Warnings = notices
+	return nil
+}
+
+func (cli *CLI) jsonOutput() bool {
+	return cli.flagOutputFormat == OutputJSON
+}
+
+// printJSON writes value to stdout as a single line of JSON
+func printJSON(value interface{}) error {
+	err := json.NewEncoder(os.Stdout).Encode(value)
+	if err != nil {
+		return fmt.Errorf("failed to write the output: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// stdinReader is shared by every question so none loses input buffered by another
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// readLine reads the answer to a question without the surrounding spaces, an
+// answer cut short by the end of the input is kept as it is
+func readLine() string {
+	answer, _ := stdinReader.ReadString('\n')
+	return strings.TrimSpace(answer)
+}
+
+// readPassphrase asks for a secret without echoing it, the envName variable
+// takes precedence so scripts can run without a terminal
+func readPassphrase(prompt, envName string) (string, error) {
+	if passphrase := os.Getenv(envName); passphrase != "" {
+		return passphrase, nil
+	}
+
+	stdin := int(os.Stdin.Fd())
+	if !term.IsTerminal(stdin) {
+		return "", fmt.Errorf("no terminal to read the passphrase from, set %s instead", envName)
+	}
+
+	// Prompt on stderr so the output of the command stays clean
+	fmt.Fprint(os.Stderr, prompt)
+	passphrase, err := term.ReadPassword(stdin)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase: %v", err)
+	}
+
+	return string(passphrase), nil
+}
+
+// readNewPassphrase asks for a new secret twice so a typo doesn't lock the
+// user out, the envName variable takes precedence like in readPassphrase
+func readNewPassphrase(prompt, envName string) (string, error) {
+	if passphrase := os.Getenv(envName); passphrase != "" {
+		return passphrase, nil
+	}
+
+	passphrase, err := readPassphrase(prompt, envName)
+	if err != nil {
+		return "", err
+	}
+
+	if passphrase == "" {
+		return "", errors.New("the passphrase can't be empty")
+	}
+
+	confirmation, err := readPassphrase("🔑 Insert it again: ", envName)
+	if err != nil {
+		return "", err
+	}
+
+	if confirmation != passphrase {
+		return "", errors.New("the passphrases don't match")
+	}
+
+	return passphrase, nil
+}
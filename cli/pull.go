@@ -0,0 +1,142 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func newPullCommand(cli *CLI) Command {
+	flags := flag.NewFlagSet("pull", flag.ContinueOnError)
+
+	return Command{
+		Name:        "pull",
+		Usage:       "denv pull [file nickname]...",
+		Description: "Download every file listed in the project manifest, or only the given ones",
+		Flags:       flags,
+		Execute: func() error {
+			names, err := parseArgs(flags, cli.args)
+			if err != nil {
+				return err
+			}
+
+			return cli.handlePull(names)
+		},
+	}
+}
+
+func newPushCommand(cli *CLI) Command {
+	flags := flag.NewFlagSet("push", flag.ContinueOnError)
+	flags.BoolVar(&cli.flagForce, "force", false, "Upload even if files were changed in the bucket since they were last synced")
+
+	return Command{
+		Name:        "push",
+		Usage:       "denv push [file nickname]... [--force]",
+		Description: "Upload every file listed in the project manifest, or only the given ones",
+		Flags:       flags,
+		Execute: func() error {
+			names, err := parseArgs(flags, cli.args)
+			if err != nil {
+				return err
+			}
+
+			return cli.handlePush(names)
+		},
+	}
+}
+
+// handlePull downloads the manifest files, or only the ones named in names
+func (cli *CLI) handlePull(names []string) error {
+	return cli.executeWithValidation(func() error {
+		entries, err := manifestEntries(names)
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			fmt.Fprintf(messages, "📄 %s -> %s\n", entry.Name, entry.relativePath())
+
+			err := os.MkdirAll(filepath.Dir(entry.Path), 0755)
+			if err != nil {
+				return fmt.Errorf("failed to create the directory of %s: %w", entry.Path, err)
+			}
+
+			// Archives are saved next to their directory and extracted into it
+			outputPath := entry.Path
+			if entry.isArchive() && !strings.HasSuffix(outputPath, ".zip") {
+				outputPath += ".zip"
+			}
+
+			err = cli.downloadTo(entry.Name, outputPath, 0)
+			if err != nil {
+				return err
+			}
+		}
+
+		fmt.Fprintf(messages, "🥳 %d files pulled!!!\n", len(entries))
+		return nil
+	})
+}
+
+// handlePush uploads the manifest files, or only the ones named in names
+func (cli *CLI) handlePush(names []string) error {
+	return cli.executeWithValidation(func() error {
+		entries, err := manifestEntries(names)
+		if err != nil {
+			return err
+		}
+		pushed := 0
+
+		for _, entry := range entries {
+			fileStat, err := os.Stat(entry.Path)
+			if os.IsNotExist(err) {
+				fmt.Fprintf(notices, "🚧 Skipping %s, %s doesn't exist\n", entry.Name, entry.relativePath())
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", entry.Path, err)
+			}
+
+			if fileStat.IsDir() != entry.isArchive() {
+				fmt.Fprintf(notices, "🚧 Skipping %s, directories must be stored with a .zip nickname and files without one\n", entry.Name)
+				continue
+			}
+
+			fmt.Fprintf(messages, "📄 %s -> %s\n", entry.relativePath(), entry.Name)
+
+			// The nickname is used as it is so the next pull finds the same file
+			if fileStat.IsDir() {
+				err = cli.uploadPath(entry.Path, entry.Name, true)
+			} else {
+				err = cli.uploadFile(entry.Path, entry.Path, entry.Name)
+			}
+			if err != nil {
+				return err
+			}
+			pushed++
+		}
+
+		fmt.Fprintf(messages, "🥳 %d files pushed!!!\n", pushed)
+		return nil
+	})
+}
+
+func manifestEntries(names []string) ([]ManifestEntry, error) {
+	manifest, err := loadManifest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the manifest: %w", err)
+	}
+
+	entries, err := manifest.selectEntries(names)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the manifest: %w", err)
+	}
+
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no files listed in %s", filepath.Join(manifest.Dir, ManifestFileName))
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,206 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/robertokbr/denv/bucket"
+	"github.com/robertokbr/denv/config"
+	"github.com/robertokbr/denv/crypt"
+)
+
+func newRecipientsCommand(cli *CLI) Command {
+	return Command{
+		Name:        "recipients",
+		Usage:       "denv recipients add|remove|list",
+		Description: "Manage the public keys files are encrypted to",
+		Execute: func() error {
+			return cli.handleRecipients()
+		},
+	}
+}
+
+func (cli *CLI) handleRecipients() error {
+	return cli.executeWithValidation(func() error {
+		if config.GetAWSCredentials().Encryption != config.EncryptionKeyFile {
+			fmt.Fprintln(notices, "🌝 Recipients need the keyfile encryption mode, type denv config to enable it")
+			return &exitError{code: ExitNotConfigured}
+		}
+
+		if len(cli.args) == 0 {
+			return printRecipientsUsage()
+		}
+
+		switch cli.args[0] {
+		case "add":
+			return cli.addRecipient(cli.args[1:])
+		case "remove":
+			return cli.removeRecipient(cli.args[1:])
+		case "list":
+			return cli.listRecipients()
+		default:
+			return printRecipientsUsage()
+		}
+	})
+}
+
+func printRecipientsUsage() error {
+	fmt.Fprintln(notices, "🌝 Usage:")
+	fmt.Fprintln(notices, "denv recipients add [public key] [comment] to encrypt every upload to a teammate")
+	fmt.Fprintln(notices, "denv recipients remove [public key or comment] to stop encrypting to a teammate")
+	fmt.Fprintln(notices, "denv recipients list to list everyone files are encrypted to")
+	return &usageError{message: "wrong usage of denv recipients"}
+}
+
+func (cli *CLI) addRecipient(args []string) error {
+	publicKey, comment := splitPublicKey(args)
+	if publicKey == "" {
+		return printRecipientsUsage()
+	}
+
+	list, err := crypt.LoadRecipients(cli.backend)
+	if err != nil {
+		return fmt.Errorf("failed to load recipients: %w", err)
+	}
+
+	err = list.Add(publicKey, comment)
+	if err != nil {
+		return printCommandError("🚧 Failed to add recipient: %v", err)
+	}
+
+	err = list.Save(cli.backend)
+	if err != nil {
+		return fmt.Errorf("failed to save recipients: %w", err)
+	}
+
+	fmt.Fprintln(messages, "🥳 Recipient added!!!")
+
+	// Files uploaded before the recipient was added are not readable by them yet
+	if askYesNo("🤔 Re-encrypt the existing files so the new recipient can read them? (y/n)") {
+		return cli.reencryptFiles()
+	}
+	return nil
+}
+
+func (cli *CLI) removeRecipient(args []string) error {
+	if len(args) == 0 {
+		return printRecipientsUsage()
+	}
+
+	list, err := crypt.LoadRecipients(cli.backend)
+	if err != nil {
+		return fmt.Errorf("failed to load recipients: %w", err)
+	}
+
+	removed := list.Remove(strings.Join(args, " "))
+	if len(removed) == 0 {
+		fmt.Fprintln(notices, "🚧 No recipient matches that public key or comment")
+		return &exitError{code: ExitNotFound}
+	}
+
+	err = list.Save(cli.backend)
+	if err != nil {
+		return fmt.Errorf("failed to save recipients: %w", err)
+	}
+
+	fmt.Fprintf(messages, "🥳 %d recipient(s) removed!!!\n", len(removed))
+
+	if askYesNo("🤔 Re-encrypt the existing files so the removed recipient can't read them anymore? (y/n)") {
+		err := cli.reencryptFiles()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(notices, "⚠️  Anything they downloaded before is still known to them, and previous versions kept by S3 versioning are still encrypted to them, consider rotating those secrets.")
+	}
+	return nil
+}
+
+func (cli *CLI) listRecipients() error {
+	list, err := crypt.LoadRecipients(cli.backend)
+	if err != nil {
+		return fmt.Errorf("failed to load recipients: %w", err)
+	}
+
+	if len(list.Recipients) == 0 {
+		fmt.Println("No recipients found, files are only encrypted to your own key.")
+	} else {
+		fmt.Printf("%-70s | %-20s\n", "Public Key", "Comment")
+
+		for _, recipient := range list.Recipients {
+			fmt.Printf("%-70s | %-20s\n", recipient.PublicKey, recipient.Comment)
+		}
+	}
+
+	// Make it easy to hand our own key to whoever manages the list
+	if keyFile, err := crypt.LoadKeyFile(config.KeyPath); err == nil {
+		fmt.Printf("🔑 Your public key is %s\n", keyFile.PublicKey())
+	}
+	return nil
+}
+
+// reencryptFiles encrypts every stored file again to the current recipient
+// list, and fails once every file was tried when some of them couldn't be.
+// Previous versions kept by S3 versioning can't be rewritten and stay
+// readable by the old recipients.
+func (cli *CLI) reencryptFiles() error {
+	fmt.Fprintln(messages, "🚚 Re-encryption in progress...")
+
+	encryptedStore, ok := cli.store.(*crypt.Store)
+	if !ok {
+		return nil
+	}
+
+	files, err := cli.backend.List("")
+	if err != nil {
+		return fmt.Errorf("failed to list files: %w", err)
+	}
+
+	count := 0
+	var failed []string
+	for _, file := range files {
+		if file.Key == crypt.RecipientsKey {
+			continue
+		}
+
+		record, exists := cli.lastSynced(file.Key)
+
+		info, err := encryptedStore.Reencrypt(file.Key)
+		if err != nil {
+			fmt.Fprintf(notices, "🚧 Failed to re-encrypt %s: %v\n", file.Key, err)
+			failed = append(failed, file.Key)
+			continue
+		}
+
+		if info != nil && !bucket.IsInternal(file.Key) {
+			count++
+
+			// The content didn't change, a local copy in sync stays in sync
+			if exists && isSynced(record, &file) {
+				cli.recordSync(file.Key, info)
+			}
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%d file(s) couldn't be re-encrypted and still use the previous recipients: %s", len(failed), strings.Join(failed, ", "))
+	}
+
+	fmt.Fprintf(messages, "🥳 %d file(s) re-encrypted!!!\n", count)
+	return nil
+}
+
+// splitPublicKey separates the public key from its comment, SSH public keys
+// are made of the key type and the key itself, usually followed by user@host
+func splitPublicKey(args []string) (string, string) {
+	fields := strings.Fields(strings.Join(args, " "))
+	if len(fields) == 0 {
+		return "", ""
+	}
+
+	keyFields := 1
+	if strings.HasPrefix(fields[0], "ssh-") && len(fields) > 1 {
+		keyFields = 2
+	}
+
+	return strings.Join(fields[:keyFields], " "), strings.Join(fields[keyFields:], " ")
+}
@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/robertokbr/denv/config"
+	"github.com/robertokbr/denv/crypt"
+)
+
+func TestReencryptKeepsSyncedFilesInSync(t *testing.T) {
+	cli, _ := newTestCLI(t)
+	t.Setenv("DENV_ENCRYPTION", config.EncryptionKeyFile)
+	if _, err := crypt.GenerateKeyFile(config.KeyPath); err != nil {
+		t.Fatalf("GenerateKeyFile: %v", err)
+	}
+
+	if err := cli.execute("up", writeTestFile(t, "app.env", "A=1\n"), "--name", "app"); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	before, err := cli.store.Stat("app.env")
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+
+	if err := cli.reencryptFiles(); err != nil {
+		t.Fatalf("reencryptFiles: %v", err)
+	}
+
+	after, err := cli.store.Stat("app.env")
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if after.ETag == before.ETag {
+		t.Fatal("app.env wasn't rewritten")
+	}
+
+	record, exists := cli.lastSynced("app.env")
+	if !exists || !isSynced(record, after) {
+		t.Errorf("sync record = %+v, want it to follow the re-encrypted file", record)
+	}
+
+	// The next upload is not taken for a conflict
+	if err := cli.execute("up", writeTestFile(t, "app.env", "A=2\n"), "--name", "app"); err != nil {
+		t.Errorf("up after re-encryption: %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRename(t *testing.T) {
+	cli, storeDir := newTestCLI(t)
+
+	if err := cli.execute("set", "old.env", "A=1"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+
+	if err := cli.execute("mv", "old.env", "new.env"); err != nil {
+		t.Fatalf("mv: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(storeDir, "old.env")); !os.IsNotExist(err) {
+		t.Errorf("old.env still exists after mv: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(storeDir, "new.env")); err != nil {
+		t.Errorf("new.env doesn't exist after mv: %v", err)
+	}
+
+	if err := cli.execute("set", "other.env", "B=1"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+
+	err := cli.execute("mv", "other.env", "new.env")
+	if code := ExitCode(err); code != ExitConflict {
+		t.Errorf("mv onto an existing file exited with %d (%v), want %d", code, err, ExitConflict)
+	}
+
+	if err := cli.execute("mv", "other.env", "new.env", "--force"); err != nil {
+		t.Fatalf("mv --force: %v", err)
+	}
+	output, err := captureStdout(t, func() error { return cli.execute("get", "new.env", "B") })
+	if err != nil || output != "1\n" {
+		t.Errorf("get B after mv --force printed %q (%v), want %q", output, err, "1\n")
+	}
+}
+
+func TestRenameKeepsHistoryAndSync(t *testing.T) {
+	cli, _ := newTestCLI(t)
+
+	for _, content := range []string{"A=1\n", "A=2\n"} {
+		if err := cli.execute("up", writeTestFile(t, "app.env", content), "--name", "app"); err != nil {
+			t.Fatalf("up: %v", err)
+		}
+	}
+
+	if err := cli.execute("mv", "app.env", "renamed.env"); err != nil {
+		t.Fatalf("mv: %v", err)
+	}
+
+	cli.flagOutputFormat = OutputJSON
+	output, err := captureStdout(t, func() error { return cli.execute("history", "renamed.env") })
+	cli.flagOutputFormat = OutputText
+	if err != nil {
+		t.Fatalf("history: %v", err)
+	}
+
+	var versions []versionJSON
+	if err := json.Unmarshal([]byte(output), &versions); err != nil {
+		t.Fatalf("history printed %q: %v", output, err)
+	}
+	if len(versions) != 2 {
+		t.Errorf("history of the renamed file has %d versions, want 2", len(versions))
+	}
+
+	if _, exists := cli.lastSynced("app.env"); exists {
+		t.Error("the old nickname is still remembered as synced")
+	}
+
+	// The local copy still matches the renamed file, so uploading it again is no conflict
+	var notes bytes.Buffer
+	notices = &notes
+	if err := cli.execute("up", writeTestFile(t, "app.env", "A=3\n"), "--name", "renamed"); err != nil {
+		t.Fatalf("up after mv: %v", err)
+	}
+	if strings.Contains(notes.String(), "synced") {
+		t.Errorf("up after mv warned %q, want the renamed file known as synced", notes.String())
+	}
+}
+
+func TestRenamePrefix(t *testing.T) {
+	cli, storeDir := newTestCLI(t)
+
+	for _, name := range []string{"team/a.env", "team/b.env", "teammate.env"} {
+		if err := cli.execute("set", name, "A=1"); err != nil {
+			t.Fatalf("set %s: %v", name, err)
+		}
+	}
+
+	if err := cli.execute("mv", "team/", "crew"); err != nil {
+		t.Fatalf("mv: %v", err)
+	}
+
+	for name, wantExists := range map[string]bool{
+		"team/a.env": false, "team/b.env": false, "crew/a.env": true, "crew/b.env": true, "teammate.env": true,
+	} {
+		_, err := os.Stat(filepath.Join(storeDir, filepath.FromSlash(name)))
+		if exists := err == nil; exists != wantExists {
+			t.Errorf("%s exists = %v, want %v", name, exists, wantExists)
+		}
+	}
+
+	err := cli.execute("mv", "missing/", "other/")
+	if code := ExitCode(err); code != ExitNotFound {
+		t.Errorf("mv of an empty prefix exited with %d (%v), want %d", code, err, ExitNotFound)
+	}
+}
@@ -0,0 +1,145 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/robertokbr/denv/config"
+	"github.com/robertokbr/denv/dotenv"
+)
+
+// stringList is a flag that can be repeated, keeping every value in order
+type stringList []string
+
+func (sl *stringList) String() string {
+	return strings.Join(*sl, ",")
+}
+
+func (sl *stringList) Set(value string) error {
+	*sl = append(*sl, value)
+	return nil
+}
+
+func newRunCommand(cli *CLI) Command {
+	var names stringList
+
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	flags.Var(&names, "name", "Nickname of an env file to inject, can be repeated")
+
+	return Command{
+		Name:        "run",
+		Usage:       "denv run --name [file nickname] [--name other] -- [command]...",
+		Description: "Run a command with stored env files injected",
+		Flags:       flags,
+		Execute: func() error {
+			// Everything after -- belongs to the command, the flag set already
+			// printed what went wrong along with the usage
+			err := flags.Parse(cli.args)
+			if err != nil {
+				return &usageError{message: err.Error()}
+			}
+
+			if len(names) == 0 || flags.NArg() == 0 {
+				return printCommandError("🌝 Usage: denv run --name [file nickname] [--name other] -- [command]...")
+			}
+
+			return cli.handleRun(names, flags.Args())
+		},
+	}
+}
+
+// handleRun downloads the env files into memory and runs command with them
+// merged over the user's environment, nothing is written to disk
+func (cli *CLI) handleRun(names []string, command []string) error {
+	return cli.executeWithValidation(func() error {
+		env := make(map[string]string)
+		// denv's own settings stay out, unless the user exported them
+		for _, variable := range config.UserEnviron() {
+			key, value, _ := strings.Cut(variable, "=")
+			env[key] = value
+		}
+
+		// Later files override earlier ones, all of them override the environment
+		for _, name := range names {
+			body, _, err := cli.store.Get(name)
+			if err != nil {
+				return fmt.Errorf("failed to download %s: %w", name, err)
+			}
+
+			content, err := io.ReadAll(body)
+			body.Close()
+			if err != nil {
+				return fmt.Errorf("failed to download %s: %w", name, err)
+			}
+
+			doc, err := dotenv.Parse(content)
+			if err != nil {
+				return fmt.Errorf("failed to parse %s: %w", name, err)
+			}
+
+			values, err := doc.Values()
+			if err != nil {
+				return fmt.Errorf("failed to parse %s: %w", name, err)
+			}
+
+			for key, value := range values {
+				env[key] = value
+			}
+		}
+
+		code := runCommand(command, env)
+		if code != ExitOK {
+			return &exitError{code: code}
+		}
+		return nil
+	})
+}
+
+// runCommand runs command attached to the terminal and returns its exit code
+func runCommand(command []string, env map[string]string) int {
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	for key, value := range env {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+
+	err := cmd.Start()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "🚧 Failed to run %s: %v\n", command[0], err)
+		return 127
+	}
+
+	// Hand the signals over to the command and let it decide when to exit
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	go func() {
+		for sig := range signals {
+			cmd.Process.Signal(sig)
+		}
+	}()
+
+	err = cmd.Wait()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "🚧 Failed to run %s: %v\n", command[0], err)
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,35 @@
+package cli
+
+import "testing"
+
+func TestRunExitCodes(t *testing.T) {
+	cli, _ := newTestCLI(t)
+
+	if err := cli.execute("up", writeTestFile(t, "app.env", "A=1\n"), "--name", "app"); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+	}{
+		{"variables are injected", []string{"--name", "app.env", "--", "sh", "-c", `test "$A" = 1`}, ExitOK},
+		{"exit code of the command", []string{"--name", "app.env", "--", "sh", "-c", "exit 9"}, 9},
+		{"unknown flag", []string{"--nmae", "app.env", "--", "true"}, ExitUsage},
+		{"no command", []string{"--name", "app.env"}, ExitUsage},
+		{"missing file", []string{"--name", "missing.env", "--", "true"}, ExitNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Each run parses its flags from scratch
+			cli.registerCommands()
+
+			err := cli.execute("run", tt.args...)
+			if code := ExitCode(err); code != tt.wantCode {
+				t.Errorf("run exit code = %d (%v), want %d", code, err, tt.wantCode)
+			}
+		})
+	}
+}
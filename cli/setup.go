@@ -2,30 +2,170 @@ package cli
 
 import (
 	"fmt"
-	"log"
+	"os"
+	"path"
+	"strings"
 
 	"github.com/robertokbr/denv/config"
+	"github.com/robertokbr/denv/crypt"
 )
 
-func ConfigureApplication() {
+func ConfigureApplication() error {
 	var creds config.AWSCredentials
+
+	// Keep the settings that are not asked for below
+	current := config.GetAWSCredentials()
+	creds.PartSizeMB, creds.Concurrency = current.PartSizeMB, current.Concurrency
+	creds.RoleSessionName, creds.RoleDurationMinutes, creds.STSEndpoint = current.RoleSessionName, current.RoleDurationMinutes, current.STSEndpoint
 	
-	fmt.Println("🚧 Insert your AWS Access key")
-	fmt.Scan(&creds.AccessKey)
-	
-	fmt.Println("🚧 Insert your AWS Secret key")
-	fmt.Scan(&creds.SecretKey)
+	fmt.Println("🚧 Insert the storage backend (s3 or local)")
+	creds.Backend = readLine()
 	
-	fmt.Println("🚧 Insert your AWS Bucket name")
-	fmt.Scan(&creds.BucketName)
+	switch creds.Backend {
+	case config.BackendLocal:
+		fmt.Println("🚧 Insert the directory where the files will be stored")
+		creds.LocalPath = readLine()
+		if creds.LocalPath == "" {
+			return printCommandError("🚧 The directory can't be empty")
+		}
+	case config.BackendS3:
+		if err := configureS3(&creds); err != nil {
+			return err
+		}
+	default:
+		return printCommandError("🚧 Unknown storage backend: %s", creds.Backend)
+	}
 	
-	fmt.Println("🚧 Insert your AWS Bucket region")
-	fmt.Scan(&creds.BucketRegion)
+	if err := configureEncryption(&creds); err != nil {
+		return err
+	}
 	
 	err := config.SaveCredentials(creds)
 	if err != nil {
-		log.Fatalf("Failed to save configuration: %v", err)
+		return fmt.Errorf("failed to save configuration: %w", err)
 	}
 	
 	PrintSuccessConfig()
+	return nil
+}
+
+func configureS3(creds *config.AWSCredentials) error {
+	if askYesNo("🚧 Use the AWS credentials of this machine (~/.aws, AWS_PROFILE, SSO or an instance role) instead of storing keys? (y/n)") {
+		creds.Credentials = config.CredentialsChain
+
+		if askYesNo("🚧 Use a named profile of ~/.aws/config? (y/n)") {
+			fmt.Println("🚧 Insert the name of the AWS profile")
+			creds.AWSProfile = readLine()
+		}
+	} else {
+		creds.Credentials = config.CredentialsStatic
+
+		fmt.Println("🚧 Insert your AWS Access key")
+		creds.AccessKey = readLine()
+
+		fmt.Println("🚧 Insert your AWS Secret key")
+		creds.SecretKey = readLine()
+	}
+	
+	fmt.Println("🚧 Insert your AWS Bucket name")
+	creds.BucketName = readLine()
+	
+	fmt.Println("🚧 Insert your AWS Bucket region")
+	creds.BucketRegion = readLine()
+
+	if creds.BucketName == "" || creds.BucketRegion == "" {
+		return printCommandError("🚧 The bucket name and region can't be empty")
+	}
+
+	if askYesNo("🚧 Is the bucket only reachable by assuming an IAM role? (y/n)") {
+		configureRole(creds)
+	}
+
+	if !askYesNo("🚧 Do you use an S3-compatible service such as MinIO, Ceph, R2 or LocalStack? (y/n)") {
+		return nil
+	}
+
+	fmt.Println("🚧 Insert the endpoint URL, e.g. http://localhost:9000")
+	creds.Endpoint = readLine()
+
+	creds.PathStyle = askYesNo("🚧 Use path-style addressing? Most self-hosted services need it (y/n)")
+	creds.InsecureSkipVerify = askYesNo("🚧 Skip TLS certificate verification? (y/n)")
+
+	if askYesNo("🚧 Do you need a custom CA bundle? (y/n)") {
+		fmt.Println("🚧 Insert the path of the PEM CA bundle")
+		creds.CABundle = readLine()
+	}
+	return nil
+}
+
+func configureRole(creds *config.AWSCredentials) {
+	fmt.Println("🚧 Insert the ARN of the role, e.g. arn:aws:iam::123456789012:role/secrets")
+	creds.RoleARN = readLine()
+
+	if askYesNo("🚧 Does the role ask for an external ID? (y/n)") {
+		fmt.Println("🚧 Insert the external ID")
+		creds.ExternalID = readLine()
+	}
+
+	if askYesNo("🚧 Does the role ask for MFA? (y/n)") {
+		fmt.Println("🚧 Insert the serial number or ARN of your MFA device")
+		creds.MFASerial = readLine()
+	}
+}
+
+func configureEncryption(creds *config.AWSCredentials) error {
+	fmt.Println("🚧 How should files be encrypted before uploading? (none, passphrase or keyfile)")
+	creds.Encryption = readLine()
+
+	switch creds.Encryption {
+	case config.EncryptionNone, config.EncryptionPassphrase:
+		return nil
+	case config.EncryptionKeyFile:
+		if askYesNo("🚧 Do you want to decrypt with your SSH key (ed25519 or RSA) instead of a denv key? (y/n)") {
+			fmt.Println("🚧 Insert the path of your SSH private key, e.g. ~/.ssh/id_ed25519")
+			creds.SSHKeyPath = readLine()
+			creds.SSHKeyPath = expandHome(creds.SSHKeyPath)
+			return nil
+		}
+
+		keyFile, err := loadOrGenerateKeyFile()
+		if err != nil {
+			return fmt.Errorf("failed to setup the encryption key: %w", err)
+		}
+
+		fmt.Printf("🔑 Your key is stored at %s, keep a backup of it somewhere safe\n", config.KeyPath)
+		fmt.Printf("🔑 Your public key is %s\n", keyFile.PublicKey())
+		return nil
+	default:
+		return printCommandError("🚧 Unknown encryption mode: %s", creds.Encryption)
+	}
+}
+
+// loadOrGenerateKeyFile keeps an existing key so files encrypted with it stay readable
+func loadOrGenerateKeyFile() (*crypt.KeyFile, error) {
+	if _, err := os.Stat(config.KeyPath); err == nil {
+		return crypt.LoadKeyFile(config.KeyPath)
+	}
+
+	return crypt.GenerateKeyFile(config.KeyPath)
+}
+
+// expandHome resolves a leading ~ since the path doesn't go through a shell
+func expandHome(filePath string) string {
+	if !strings.HasPrefix(filePath, "~/") {
+		return filePath
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filePath
+	}
+
+	return path.Join(home, filePath[2:])
+}
+
+func askYesNo(question string) bool {
+	fmt.Println(question)
+	answer := strings.ToLower(readLine())
+	return answer == "y" || answer == "yes"
 }
\ No newline at end of file
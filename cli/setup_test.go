@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/robertokbr/denv/config"
+)
+
+func TestConfigureApplication(t *testing.T) {
+	tests := []struct {
+		name     string
+		answers  []string
+		wantCode int
+	}{
+		{"local backend", []string{"local", t.TempDir(), "none"}, ExitOK},
+		{"unknown backend", []string{"ftp"}, ExitUsage},
+		{"empty directory", []string{"local", ""}, ExitUsage},
+		{"empty bucket name", []string{"s3", "y", "n", "", "us-east-1"}, ExitUsage},
+		{"unknown encryption", []string{"local", t.TempDir(), "rot13"}, ExitUsage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newTestCLI(t)
+
+			previous := stdinReader
+			stdinReader = bufio.NewReader(strings.NewReader(strings.Join(tt.answers, "\n") + "\n"))
+			t.Cleanup(func() { stdinReader = previous })
+
+			_, err := captureStdout(t, func() error {
+				return ConfigureApplication()
+			})
+			if code := ExitCode(err); code != tt.wantCode {
+				t.Fatalf("ConfigureApplication exit code = %d (%v), want %d", code, err, tt.wantCode)
+			}
+
+			// Nothing is saved when a question got a wrong answer
+			values, err := config.ReadSettings()
+			if err != nil {
+				t.Fatalf("ReadSettings: %v", err)
+			}
+			if saved := values["DENV_BACKEND"] != ""; saved != (tt.wantCode == ExitOK) {
+				t.Errorf("settings saved = %v, want %v", saved, tt.wantCode == ExitOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/robertokbr/denv/bucket"
+	"github.com/robertokbr/denv/config"
+	"github.com/robertokbr/denv/crypt"
+)
+
+// newEncryptedStore wraps backend with client-side encryption when it is enabled
+func newEncryptedStore(backend bucket.Store, creds config.AWSCredentials) (bucket.Store, error) {
+	switch creds.Encryption {
+	case config.EncryptionNone:
+		return &plaintextStore{Store: backend}, nil
+	case config.EncryptionPassphrase:
+		keys := crypt.NewPassphraseKeys(func() (string, error) {
+			return readCachedPassphrase(passphraseSecret(creds.StorageID()), "🔑 Insert your encryption passphrase: ", "DENV_PASSPHRASE")
+		})
+		return crypt.NewStore(backend, keys, creds.AllowPlaintext), nil
+	case config.EncryptionKeyFile:
+		ownKeys, err := loadOwnKeys(creds)
+		if err != nil {
+			return nil, err
+		}
+		// Encrypt to the team recipients listed in the bucket as well
+		return crypt.NewStore(backend, crypt.NewTeamKeys(backend, ownKeys...), creds.AllowPlaintext), nil
+	default:
+		return nil, fmt.Errorf("unknown encryption mode: %s", creds.Encryption)
+	}
+}
+
+// plaintextStore uploads files as they are, warning about it once so setups
+// made before encryption existed don't keep uploading plaintext unnoticed
+type plaintextStore struct {
+	bucket.Store
+	warning sync.Once
+}
+
+func (ps *plaintextStore) Put(key string, body io.Reader, metadata map[string]string) (*bucket.ObjectInfo, error) {
+	if !bucket.IsInternal(key) {
+		ps.warning.Do(func() {
+			fmt.Fprintln(notices, "⚠️  Files are uploaded without encryption, anyone who can read the bucket can read them. Type denv config set encryption passphrase (or keyfile) to encrypt them")
+		})
+	}
+
+	return ps.Store.Put(key, body, metadata)
+}
+
+func (ps *plaintextStore) VersioningEnabled() (bool, error) {
+	versioner, ok := ps.Store.(bucket.Versioner)
+	if !ok {
+		return false, nil
+	}
+
+	return versioner.VersioningEnabled()
+}
+
+func (ps *plaintextStore) ListVersions(key string) ([]bucket.ObjectInfo, error) {
+	versioner, ok := ps.Store.(bucket.Versioner)
+	if !ok {
+		return nil, errors.New("the storage backend has no versioning")
+	}
+
+	return versioner.ListVersions(key)
+}
+
+func (ps *plaintextStore) GetVersion(key, versionID string) (io.ReadCloser, *bucket.ObjectInfo, error) {
+	versioner, ok := ps.Store.(bucket.Versioner)
+	if !ok {
+		return nil, nil, errors.New("the storage backend has no versioning")
+	}
+
+	return versioner.GetVersion(key, versionID)
+}
+
+// loadOwnKeys loads the denv key file and the SSH key this machine decrypts with
+func loadOwnKeys(creds config.AWSCredentials) ([]crypt.Keys, error) {
+	var ownKeys []crypt.Keys
+
+	if _, err := os.Stat(config.KeyPath); err == nil {
+		keyFile, err := crypt.LoadKeyFile(config.KeyPath)
+		if err != nil {
+			return nil, err
+		}
+		ownKeys = append(ownKeys, keyFile)
+	}
+
+	if creds.SSHKeyPath != "" {
+		sshKey, err := crypt.LoadSSHKey(creds.SSHKeyPath, func() (string, error) {
+			return readCachedPassphrase(sshSecret(creds.SSHKeyPath), "🔑 Insert your SSH key passphrase: ", "DENV_SSH_PASSPHRASE")
+		})
+		if err != nil {
+			forgetCachedPassphrase(sshSecret(creds.SSHKeyPath))
+			return nil, err
+		}
+		ownKeys = append(ownKeys, sshKey)
+	}
+
+	if len(ownKeys) == 0 {
+		return nil, errors.New("no encryption key found, run denv config to create one")
+	}
+
+	return ownKeys, nil
+}
+
+func newBackend(creds config.AWSCredentials) (bucket.Store, error) {
+	switch creds.Backend {
+	case config.BackendS3:
+		// Role credentials would be stored in plaintext, locked settings mean
+		// the user doesn't want secrets on disk
+		cacheDir := config.CachePath
+		if config.IsLocked() {
+			cacheDir = ""
+		}
+
+		return bucket.NewS3Bucket(bucket.S3Options{
+			AccessKey:          creds.AccessKey,
+			SecretKey:          creds.SecretKey,
+			BucketName:         creds.BucketName,
+			BucketRegion:       creds.BucketRegion,
+			Endpoint:           creds.Endpoint,
+			PathStyle:          creds.PathStyle,
+			InsecureSkipVerify: creds.InsecureSkipVerify,
+			CABundle:           creds.CABundle,
+			PartSize:           int64(creds.PartSizeMB) * 1024 * 1024,
+			Concurrency:        creds.Concurrency,
+			// The credential chain ignores the keys above
+			UseDefaultCredentials: creds.Credentials == config.CredentialsChain,
+			Profile:               creds.AWSProfile,
+			MFATokenProvider: func() (string, error) {
+				return readPassphrase("🔑 Insert the MFA code of your AWS profile: ", "DENV_MFA_CODE")
+			},
+			Role: bucket.RoleOptions{
+				RoleARN:     creds.RoleARN,
+				ExternalID:  creds.ExternalID,
+				SessionName: creds.RoleSessionName,
+				MFASerial:   creds.MFASerial,
+				Duration:    time.Duration(creds.RoleDurationMinutes) * time.Minute,
+				STSEndpoint: creds.STSEndpoint,
+				CacheDir:    cacheDir,
+			},
+		})
+	case config.BackendLocal:
+		return bucket.NewLocalStore(creds.LocalPath)
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %s", creds.Backend)
+	}
+}
@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/robertokbr/denv/config"
+	"github.com/robertokbr/denv/crypt"
+)
+
+func TestPlaintextUploadsWarn(t *testing.T) {
+	cli, _ := newTestCLI(t)
+
+	var output bytes.Buffer
+	notices = &output
+
+	if err := cli.execute("set", "app.env", "A=1"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if strings.Count(output.String(), "without encryption") != 1 {
+		t.Errorf("notices = %q, want one warning about the upload without encryption", output.String())
+	}
+
+	output.Reset()
+	if _, err := captureStdout(t, func() error { return cli.execute("get", "app.env", "A") }); err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if output.Len() != 0 {
+		t.Errorf("notices = %q after a download, want no warning", output.String())
+	}
+}
+
+func TestEncryptedUploads(t *testing.T) {
+	cli, storeDir := newTestCLI(t)
+	t.Setenv("DENV_ENCRYPTION", config.EncryptionKeyFile)
+	if _, err := crypt.GenerateKeyFile(config.KeyPath); err != nil {
+		t.Fatalf("GenerateKeyFile: %v", err)
+	}
+
+	if err := cli.execute("set", "app.env", "SECRET=hunter2"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+
+	stored, err := os.ReadFile(filepath.Join(storeDir, "app.env"))
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if bytes.Contains(stored, []byte("hunter2")) {
+		t.Errorf("the stored file holds the plaintext: %q", stored)
+	}
+
+	output, err := captureStdout(t, func() error { return cli.execute("get", "app.env", "SECRET") })
+	if err != nil || output != "hunter2\n" {
+		t.Errorf("get SECRET printed %q (%v), want it decrypted", output, err)
+	}
+
+	// A file put in the bucket without encryption is only read when allowed
+	if err := os.WriteFile(filepath.Join(storeDir, "plain.env"), []byte("A=1\n"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if _, err := captureStdout(t, func() error { return cli.execute("get", "plain.env", "A") }); err == nil {
+		t.Error("a plaintext file was read while encryption is on")
+	}
+
+	t.Setenv("DENV_ALLOW_PLAINTEXT", "true")
+	output, err = captureStdout(t, func() error { return cli.execute("get", "plain.env", "A") })
+	if err != nil || output != "1\n" {
+		t.Errorf("get A with allow_plaintext printed %q (%v), want %q", output, err, "1\n")
+	}
+}
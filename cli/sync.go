@@ -0,0 +1,179 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/robertokbr/denv/bucket"
+	"github.com/robertokbr/denv/config"
+	"golang.org/x/term"
+)
+
+// recordSync remembers that the local copy of name matches info, so the next
+// upload can tell whether someone else changed the file in the meantime
+func (cli *CLI) recordSync(name string, info *bucket.ObjectInfo) {
+	if info == nil {
+		return
+	}
+
+	cli.updateSyncState(func(state *config.SyncState) {
+		state.Set(cli.storageID, name, config.SyncRecord{
+			ETag:      info.ETag,
+			VersionID: info.VersionID,
+			SyncedAt:  time.Now().UTC(),
+		})
+	})
+}
+
+// forgetSync drops what is known about name, once it was deleted or renamed
+func (cli *CLI) forgetSync(name string) {
+	cli.updateSyncState(func(state *config.SyncState) {
+		state.Delete(cli.storageID, name)
+	})
+}
+
+// updateSyncState only warns on failure, the state is a safety net and must
+// not break a download or upload that already succeeded
+func (cli *CLI) updateSyncState(update func(state *config.SyncState)) {
+	state, err := config.LoadSyncState()
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		return
+	}
+
+	update(state)
+
+	err = state.Save()
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// moveSync follows a renamed file, the local copy still matches it when it
+// matched the file before the rename
+func (cli *CLI) moveSync(oldName, newName string) {
+	if record, exists := cli.lastSynced(oldName); exists {
+		info, err := cli.store.Stat(newName)
+		if err == nil && record.ETag == info.ETag {
+			cli.recordSync(newName, info)
+		}
+	}
+	cli.forgetSync(oldName)
+}
+
+// lastSynced returns the version of name this machine last downloaded or uploaded
+func (cli *CLI) lastSynced(name string) (config.SyncRecord, bool) {
+	state, err := config.LoadSyncState()
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		return config.SyncRecord{}, false
+	}
+
+	return state.Get(cli.storageID, name)
+}
+
+// isSynced tells whether the stored file is still the one last synced
+func isSynced(record config.SyncRecord, remote *bucket.ObjectInfo) bool {
+	if record.VersionID != "" && remote.VersionID != "" {
+		return record.VersionID == remote.VersionID
+	}
+
+	return record.ETag == remote.ETag
+}
+
+// checkConflict makes sure uploading over name doesn't silently discard changes
+// someone else uploaded since this machine last synced it, localPath is the
+// file or directory being uploaded or empty when there is none to compare
+func (cli *CLI) checkConflict(name, localPath string) error {
+	if cli.flagForce {
+		return nil
+	}
+
+	remote, err := cli.store.Stat(name)
+	if bucket.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check %s for changes: %w", name, err)
+	}
+
+	// Without a record there is no telling whether the stored file changed, it
+	// is overwritten like before sync records existed and its version is kept
+	record, exists := cli.lastSynced(name)
+	if !exists {
+		fmt.Fprintf(notices, "⚠️  %s already exists in the bucket and was never synced on this machine, it is overwritten and its previous content is listed by denv history %s\n", name, name)
+		return nil
+	}
+	if isSynced(record, remote) {
+		return nil
+	}
+
+	fmt.Fprintf(notices, "⚔️  %s was changed in the bucket after you last synced it on %s\n", name, record.SyncedAt.Local().Format("2006-01-02 15:04:05"))
+
+	return cli.resolveConflict(name, localPath)
+}
+
+// resolveConflict asks how to go on, without a terminal the upload is aborted
+func (cli *CLI) resolveConflict(name, localPath string) error {
+	mergeable := localPath != "" && (isDotenvFile(name) || isDotenvFile(localPath))
+	aborted := &bucket.Error{Kind: bucket.ErrConflict, Err: fmt.Errorf("upload of %s aborted because of a conflict", name)}
+
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		if localPath != "" {
+			fmt.Fprintf(notices, "🤓 Compare both with: denv diff %s %s\n", name, localPath)
+		}
+		if mergeable {
+			fmt.Fprintf(notices, "🤓 Merge both with: denv merge %s %s\n", name, localPath)
+		}
+		fmt.Fprintln(notices, "🤓 Upload again with --force to overwrite it anyway")
+		return aborted
+	}
+
+	question := "🤔 [o]verwrite or [a]bort? "
+	if mergeable {
+		question = "🤔 [o]verwrite, [d]iff, [m]erge or [a]bort? "
+	} else if localPath != "" {
+		question = "🤔 [o]verwrite, [d]iff or [a]bort? "
+	}
+
+	for {
+		fmt.Fprint(notices, question)
+
+		answer, err := stdinReader.ReadString('\n')
+		if err != nil {
+			return aborted
+		}
+
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "o", "overwrite":
+			return nil
+		case "d", "diff":
+			if localPath != "" {
+				report, err := cli.compareFiles(name, localPath, false)
+				if err != nil {
+					return err
+				}
+				writeDiff(notices, report)
+			}
+		case "m", "merge":
+			if !mergeable {
+				continue
+			}
+
+			// Upload the merged file once every conflict was settled
+			ready, err := cli.mergeInto(name, localPath, false)
+			if err != nil {
+				return err
+			}
+			if ready {
+				return nil
+			}
+			return &bucket.Error{Kind: bucket.ErrConflict, Err: fmt.Errorf("upload of %s aborted, upload it again once the conflicts are solved", name)}
+		case "a", "abort", "":
+			return aborted
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUploadConflicts(t *testing.T) {
+	tests := []struct {
+		name        string
+		synced      bool
+		changed     bool
+		force       bool
+		wantCode    int
+		wantWarning bool
+	}{
+		{name: "synced and unchanged", synced: true, wantCode: ExitOK},
+		{name: "changed since the last sync", synced: true, changed: true, wantCode: ExitConflict},
+		{name: "changed and forced", synced: true, changed: true, force: true, wantCode: ExitOK},
+		{name: "never synced on this machine", changed: true, wantCode: ExitOK, wantWarning: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cli, storeDir := newTestCLI(t)
+			storedPath := filepath.Join(storeDir, "app.env")
+
+			if tt.synced {
+				if err := cli.execute("up", writeTestFile(t, "app.env", "A=1\n"), "--name", "app"); err != nil {
+					t.Fatalf("up: %v", err)
+				}
+			} else if err := os.WriteFile(storedPath, []byte("A=1\n"), 0600); err != nil {
+				t.Fatalf("WriteFile: %v", err)
+			}
+
+			// Someone else uploads from another machine
+			if tt.changed {
+				if err := os.WriteFile(storedPath, []byte("A=theirs\n"), 0600); err != nil {
+					t.Fatalf("WriteFile: %v", err)
+				}
+			}
+
+			var output bytes.Buffer
+			notices = &output
+
+			args := []string{writeTestFile(t, "app.env", "A=mine\n"), "--name", "app"}
+			if tt.force {
+				args = append(args, "--force")
+			}
+			err := cli.execute("up", args...)
+			if code := ExitCode(err); code != tt.wantCode {
+				t.Fatalf("up exit code = %d (%v), want %d", code, err, tt.wantCode)
+			}
+
+			if warned := strings.Contains(output.String(), "never synced"); warned != tt.wantWarning {
+				t.Errorf("warning printed = %v, want %v: %q", warned, tt.wantWarning, output.String())
+			}
+
+			want := "A=mine\n"
+			if tt.wantCode == ExitConflict {
+				want = "A=theirs\n"
+			}
+			if content, _ := os.ReadFile(storedPath); string(content) != want {
+				t.Errorf("stored %q, want %q", content, want)
+			}
+		})
+	}
+}
@@ -1,8 +1,12 @@
 package main
 
-import "github.com/robertokbr/denv/cli"
+import (
+	"os"
+
+	"github.com/robertokbr/denv/cli"
+)
 
 func main() {
 	appCLI := cli.New()
-	appCLI.Run()
+	os.Exit(appCLI.Run())
 }
\ No newline at end of file
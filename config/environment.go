@@ -3,21 +3,64 @@ package config
 import (
 	"errors"
 	"fmt"
-	"io"
 	"os"
-	"strings"
+	"strconv"
+)
+
+const (
+	BackendS3    = "s3"
+	BackendLocal = "local"
+)
+
+// Where the S3 backend gets its AWS credentials from
+const (
+	// CredentialsStatic uses the access and secret keys stored by denv
+	CredentialsStatic = "static"
+	// CredentialsChain looks for credentials the way the AWS CLI does
+	CredentialsChain = "chain"
+)
 
-	"github.com/joho/godotenv"
+const (
+	EncryptionNone       = "none"
+	EncryptionPassphrase = "passphrase"
+	EncryptionKeyFile    = "keyfile"
 )
 
 type AWSCredentials struct {
-	AccessKey   string
-	SecretKey   string
-	BucketName  string
+	Backend      string
+	LocalPath    string
+	Encryption   string
+	SSHKeyPath   string
+	AccessKey    string
+	SecretKey    string
+	BucketName   string
 	BucketRegion string
+	// Where the S3 credentials come from, CredentialsStatic or CredentialsChain,
+	// and the profile of ~/.aws/config the chain uses
+	Credentials string
+	AWSProfile  string
+	// Settings for S3-compatible services such as MinIO
+	Endpoint           string
+	PathStyle          bool
+	InsecureSkipVerify bool
+	CABundle           string
+	// Multipart uploads, zero uses the defaults
+	PartSizeMB  int
+	Concurrency int
+	// IAM role assumed with the credentials above, empty RoleARN assumes none
+	RoleARN             string
+	ExternalID          string
+	RoleSessionName     string
+	MFASerial           string
+	RoleDurationMinutes int
+	STSEndpoint         string
+	// AllowPlaintext reads the files that aren't encrypted while encryption is on
+	AllowPlaintext bool
 }
 
 func SetupEnvironment() error {
+	rememberUserEnviron()
+
 	// Ensure project directory exists
 	if err := ensureProjectDir(); err != nil {
 		return err
@@ -33,11 +76,6 @@ func ensureProjectDir() error {
 		if err != nil {
 			return fmt.Errorf("failed to create denv directory: %s", err.Error())
 		}
-		
-		_, err = os.Create(EnvPath)
-		if err != nil {
-			return fmt.Errorf("failed to create env file: %s", err.Error())
-		}
 	}
 	
 	return nil
@@ -45,15 +83,28 @@ func ensureProjectDir() error {
 
 func loadEnv() error {
 	if _, err := os.Stat(EnvPath); err != nil {
+		// Other profiles are only created by denv config
+		if Profile != DefaultProfile {
+			return nil
+		}
+
 		// File doesn't exist, create it
-		_, err = os.Create(EnvPath)
+		file, err := os.OpenFile(EnvPath, os.O_WRONLY|os.O_CREATE, SettingsFilePermission)
 		if err != nil {
 			return fmt.Errorf("failed to create env file: %s", err.Error())
 		}
+		return file.Close()
+	}
+	
+	// Files created before the settings were kept private
+	os.Chmod(EnvPath, SettingsFilePermission)
+	
+	// Locked settings are only decrypted when a command needs them
+	if IsLocked() {
 		return nil
 	}
 	
-	err := godotenv.Load(EnvPath)
+	err := LoadSettings()
 	if err != nil {
 		return fmt.Errorf("failed to load environment: %s", err.Error())
 	}
@@ -61,16 +112,62 @@ func loadEnv() error {
 	return nil
 }
 
+// LoadSettings sets the settings of the selected profile as environment
+// variables, decrypting them when they are locked. Variables that are
+// already set win over the stored values.
+func LoadSettings() error {
+	values, err := readSettingsFile(EnvPath)
+	if err != nil {
+		return err
+	}
+
+	credentials, exists := os.LookupEnv("DENV_AWS_CREDENTIALS")
+	if !exists {
+		credentials = values["DENV_AWS_CREDENTIALS"]
+	}
+
+	for key, value := range values {
+		// The AWS SDK reads these names before any other source, so the keys
+		// kept from the static mode would win over the credential chain
+		if credentials == CredentialsChain && (key == "AWS_ACCESS_KEY" || key == "AWS_SECRET_KEY") {
+			continue
+		}
+
+		if _, exists := os.LookupEnv(key); !exists {
+			os.Setenv(key, value)
+		}
+	}
+
+	return nil
+}
+
 func ValidateEnvironment() error {
-	err := godotenv.Load(EnvPath)
+	if Profile != DefaultProfile && !ProfileExists(Profile) {
+		return fmt.Errorf("profile %s is not configured, run denv config --profile %s", Profile, Profile)
+	}
+
+	err := LoadSettings()
 	if err != nil {
-		return fmt.Errorf("failed to read environment: %s", err.Error())
+		return fmt.Errorf("failed to read environment: %w", err)
+	}
+	
+	// The local backend only needs to know where the files live
+	if os.Getenv("DENV_BACKEND") == BackendLocal {
+		if os.Getenv("DENV_LOCAL_PATH") == "" {
+			return errors.New("environment variables not properly set")
+		}
+		return nil
 	}
 	
 	accessKey := os.Getenv("AWS_ACCESS_KEY")
 	secretKey := os.Getenv("AWS_SECRET_KEY")
 	bucketName := os.Getenv("AWS_BUCKET_NAME")
 	
+	// The credential chain finds its keys somewhere else, if at all
+	if os.Getenv("DENV_AWS_CREDENTIALS") == CredentialsChain {
+		accessKey, secretKey = "-", "-"
+	}
+	
 	if accessKey == "" || secretKey == "" || bucketName == "" {
 		return errors.New("environment variables not properly set")
 	}
@@ -78,29 +175,114 @@ func ValidateEnvironment() error {
 	return nil
 }
 
+// SaveCredentials replaces the settings of the selected profile with creds,
+// keeping the ones creds doesn't hold
 func SaveCredentials(creds AWSCredentials) error {
-	file, err := os.Create(EnvPath)
+	values, err := ReadSettings()
 	if err != nil {
-		return fmt.Errorf("failed to create env file: %s", err.Error())
+		return err
 	}
-	defer file.Close()
-	
-	data := "AWS_ACCESS_KEY=%s\nAWS_SECRET_KEY=%s\nAWS_BUCKET_NAME=%s\nAWS_BUCKET_REGION=%s"
-	data = fmt.Sprintf(data, creds.AccessKey, creds.SecretKey, creds.BucketName, creds.BucketRegion)
-	
-	_, err = io.Copy(file, strings.NewReader(data))
-	if err != nil {
-		return fmt.Errorf("failed to write credentials: %s", err.Error())
+
+	settings := map[string]string{
+		"DENV_BACKEND":                   creds.Backend,
+		"DENV_LOCAL_PATH":                creds.LocalPath,
+		"DENV_ENCRYPTION":                creds.Encryption,
+		"DENV_SSH_KEY":                   creds.SSHKeyPath,
+		"AWS_ACCESS_KEY":                 creds.AccessKey,
+		"AWS_SECRET_KEY":                 creds.SecretKey,
+		"DENV_AWS_CREDENTIALS":           creds.Credentials,
+		"DENV_AWS_PROFILE":               creds.AWSProfile,
+		"AWS_BUCKET_NAME":                creds.BucketName,
+		"AWS_BUCKET_REGION":              creds.BucketRegion,
+		"DENV_S3_ENDPOINT":               creds.Endpoint,
+		"DENV_S3_PATH_STYLE":             strconv.FormatBool(creds.PathStyle),
+		"DENV_S3_INSECURE_SKIP_VERIFY":   strconv.FormatBool(creds.InsecureSkipVerify),
+		"DENV_S3_CA_BUNDLE":              creds.CABundle,
+		"DENV_S3_PART_SIZE_MB":           "",
+		"DENV_S3_CONCURRENCY":            "",
+		"DENV_AWS_ROLE_ARN":              creds.RoleARN,
+		"DENV_AWS_EXTERNAL_ID":           creds.ExternalID,
+		"DENV_AWS_ROLE_SESSION_NAME":     creds.RoleSessionName,
+		"DENV_AWS_MFA_SERIAL":            creds.MFASerial,
+		"DENV_AWS_ROLE_DURATION_MINUTES": "",
+		"DENV_STS_ENDPOINT":              creds.STSEndpoint,
 	}
-	
-	return nil
+
+	if creds.PartSizeMB > 0 {
+		settings["DENV_S3_PART_SIZE_MB"] = strconv.Itoa(creds.PartSizeMB)
+	}
+	if creds.Concurrency > 0 {
+		settings["DENV_S3_CONCURRENCY"] = strconv.Itoa(creds.Concurrency)
+	}
+	if creds.RoleDurationMinutes > 0 {
+		settings["DENV_AWS_ROLE_DURATION_MINUTES"] = strconv.Itoa(creds.RoleDurationMinutes)
+	}
+
+	for key, value := range settings {
+		values[key] = value
+	}
+
+	return writeSettings(values)
 }
 
 func GetAWSCredentials() AWSCredentials {
+	backend := os.Getenv("DENV_BACKEND")
+	if backend == "" {
+		backend = BackendS3
+	}
+
+	credentials := os.Getenv("DENV_AWS_CREDENTIALS")
+	if credentials == "" {
+		credentials = CredentialsStatic
+	}
+
+	encryption := os.Getenv("DENV_ENCRYPTION")
+	if encryption == "" {
+		encryption = EncryptionNone
+	}
+
 	return AWSCredentials{
+		Backend:      backend,
+		LocalPath:    os.Getenv("DENV_LOCAL_PATH"),
+		Encryption:   encryption,
+		SSHKeyPath:   os.Getenv("DENV_SSH_KEY"),
 		AccessKey:    os.Getenv("AWS_ACCESS_KEY"),
 		SecretKey:    os.Getenv("AWS_SECRET_KEY"),
+		Credentials:  credentials,
+		AWSProfile:   os.Getenv("DENV_AWS_PROFILE"),
 		BucketName:   os.Getenv("AWS_BUCKET_NAME"),
 		BucketRegion: os.Getenv("AWS_BUCKET_REGION"),
+
+		Endpoint:           os.Getenv("DENV_S3_ENDPOINT"),
+		PathStyle:          getBoolEnv("DENV_S3_PATH_STYLE"),
+		InsecureSkipVerify: getBoolEnv("DENV_S3_INSECURE_SKIP_VERIFY"),
+		CABundle:           os.Getenv("DENV_S3_CA_BUNDLE"),
+
+		PartSizeMB:  getIntEnv("DENV_S3_PART_SIZE_MB"),
+		Concurrency: getIntEnv("DENV_S3_CONCURRENCY"),
+
+		RoleARN:             os.Getenv("DENV_AWS_ROLE_ARN"),
+		ExternalID:          os.Getenv("DENV_AWS_EXTERNAL_ID"),
+		RoleSessionName:     os.Getenv("DENV_AWS_ROLE_SESSION_NAME"),
+		MFASerial:           os.Getenv("DENV_AWS_MFA_SERIAL"),
+		RoleDurationMinutes: getIntEnv("DENV_AWS_ROLE_DURATION_MINUTES"),
+		STSEndpoint:         os.Getenv("DENV_STS_ENDPOINT"),
+
+		AllowPlaintext: getBoolEnv("DENV_ALLOW_PLAINTEXT"),
+	}
+}
+
+// getBoolEnv reads a boolean variable, anything that doesn't parse counts as false
+func getBoolEnv(name string) bool {
+	value, err := strconv.ParseBool(os.Getenv(name))
+	return err == nil && value
+}
+
+// getIntEnv reads a number, anything that doesn't parse counts as zero
+func getIntEnv(name string) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil {
+		return 0
 	}
+	return value
 }
\ No newline at end of file
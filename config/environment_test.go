@@ -0,0 +1,139 @@
+package config
+
+import (
+	"os"
+	"testing"
+)
+
+// newTestConfig points the settings to a temporary home with the default
+// profile selected
+func newTestConfig(t *testing.T) {
+	t.Helper()
+
+	t.Setenv("DENV_PROFILE", "")
+	SetPaths(t.TempDir())
+	if err := SelectProfile(DefaultProfile); err != nil {
+		t.Fatalf("SelectProfile: %v", err)
+	}
+	if err := ensureProjectDir(); err != nil {
+		t.Fatalf("ensureProjectDir: %v", err)
+	}
+
+	// Keep locking fast, the cost of scrypt isn't what is tested
+	previous, previousWorkFactor := MasterPassphrase, lockWorkFactor
+	lockWorkFactor = 10
+	t.Cleanup(func() {
+		MasterPassphrase, lockWorkFactor = previous, previousWorkFactor
+		masterPassphrase = ""
+	})
+}
+
+// unsetEnv removes variables for the test, restoring them afterwards
+func unsetEnv(t *testing.T, keys ...string) {
+	t.Helper()
+
+	for _, key := range keys {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+}
+
+func TestLoadSettings(t *testing.T) {
+	newTestConfig(t)
+	unsetEnv(t, "AWS_BUCKET_NAME", "AWS_BUCKET_REGION")
+	t.Setenv("AWS_BUCKET_REGION", "eu-west-1")
+
+	err := writeSettings(map[string]string{"AWS_BUCKET_NAME": "stored", "AWS_BUCKET_REGION": "us-east-1"})
+	if err != nil {
+		t.Fatalf("writeSettings: %v", err)
+	}
+
+	if err := LoadSettings(); err != nil {
+		t.Fatalf("LoadSettings: %v", err)
+	}
+
+	if got := os.Getenv("AWS_BUCKET_NAME"); got != "stored" {
+		t.Errorf("AWS_BUCKET_NAME = %q, want the stored value", got)
+	}
+	if got := os.Getenv("AWS_BUCKET_REGION"); got != "eu-west-1" {
+		t.Errorf("AWS_BUCKET_REGION = %q, want the variable already set to win", got)
+	}
+}
+
+func TestChainCredentialsIgnoreStoredKeys(t *testing.T) {
+	tests := []struct {
+		name        string
+		stored      string
+		env         string
+		wantExports bool
+	}{
+		{"static", CredentialsStatic, "", true},
+		{"no mode is static", "", "", true},
+		{"chain", CredentialsChain, "", false},
+		{"chain from the environment", CredentialsStatic, CredentialsChain, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newTestConfig(t)
+			unsetEnv(t, "AWS_ACCESS_KEY", "AWS_SECRET_KEY", "DENV_AWS_CREDENTIALS")
+			if tt.env != "" {
+				t.Setenv("DENV_AWS_CREDENTIALS", tt.env)
+			}
+
+			// Keys kept from before the switch to the credential chain
+			err := writeSettings(map[string]string{
+				"AWS_ACCESS_KEY":       "AKIAOLD",
+				"AWS_SECRET_KEY":       "old-secret",
+				"DENV_AWS_CREDENTIALS": tt.stored,
+			})
+			if err != nil {
+				t.Fatalf("writeSettings: %v", err)
+			}
+
+			if err := LoadSettings(); err != nil {
+				t.Fatalf("LoadSettings: %v", err)
+			}
+
+			for _, key := range []string{"AWS_ACCESS_KEY", "AWS_SECRET_KEY"} {
+				if _, exported := os.LookupEnv(key); exported != tt.wantExports {
+					t.Errorf("%s exported = %v, want %v", key, exported, tt.wantExports)
+				}
+			}
+
+			// They are still stored for a switch back to the static mode
+			values, err := ReadSettings()
+			if err != nil {
+				t.Fatalf("ReadSettings: %v", err)
+			}
+			if values["AWS_ACCESS_KEY"] != "AKIAOLD" {
+				t.Errorf("stored AWS_ACCESS_KEY = %q, want it kept", values["AWS_ACCESS_KEY"])
+			}
+		})
+	}
+}
+
+func TestLookupUserEnv(t *testing.T) {
+	newTestConfig(t)
+	unsetEnv(t, "AWS_BUCKET_NAME")
+	t.Setenv("DENV_USER_VARIABLE", "mine")
+
+	previous := userEnv
+	userEnv = nil
+	t.Cleanup(func() { userEnv = previous })
+	rememberUserEnviron()
+
+	if err := writeSettings(map[string]string{"AWS_BUCKET_NAME": "stored"}); err != nil {
+		t.Fatalf("writeSettings: %v", err)
+	}
+	if err := LoadSettings(); err != nil {
+		t.Fatalf("LoadSettings: %v", err)
+	}
+
+	if value, exists := LookupUserEnv("DENV_USER_VARIABLE"); !exists || value != "mine" {
+		t.Errorf("LookupUserEnv(DENV_USER_VARIABLE) = %q, %v, want the user's value", value, exists)
+	}
+	if value, exists := LookupUserEnv("AWS_BUCKET_NAME"); exists {
+		t.Errorf("LookupUserEnv(AWS_BUCKET_NAME) = %q, want the loaded setting hidden", value)
+	}
+}
@@ -13,6 +13,15 @@ const (
 var (
 	ProjectPath string
 	EnvPath     string
+	KeyPath     string
+	StatePath   string
+	// ProfilesPath holds the settings of the profiles other than the default one
+	ProfilesPath       string
+	DefaultProfilePath string
+	// CachePath holds the credentials of assumed roles until they expire
+	CachePath string
+	// AgentSocketPath is where denv agent listens, inside a private directory
+	AgentSocketPath string
 )
 
 func InitPaths() error {
@@ -35,4 +44,10 @@ func SetPaths(home string) {
 	}
 	
 	EnvPath = path.Join(ProjectPath, ".env")
+	KeyPath = path.Join(ProjectPath, "key.txt")
+	StatePath = path.Join(ProjectPath, "state.json")
+	ProfilesPath = path.Join(ProjectPath, "profiles")
+	DefaultProfilePath = path.Join(ProjectPath, "profile")
+	CachePath = path.Join(ProjectPath, "cache")
+	AgentSocketPath = path.Join(ProjectPath, "agent", "agent.sock")
 }
\ No newline at end of file
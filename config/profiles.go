@@ -0,0 +1,109 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DefaultProfile is the profile kept in the .env file denv always had
+const DefaultProfile = "default"
+
+// Profile is the name of the profile the settings are read from
+var Profile = DefaultProfile
+
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// SelectProfile reads the settings of the named profile from now on. An empty
+// name falls back to DENV_PROFILE, then to the profile chosen with
+// SetDefaultProfile and last to the default one.
+func SelectProfile(name string) error {
+	if name == "" {
+		name = os.Getenv("DENV_PROFILE")
+	}
+
+	if name == "" {
+		name = readDefaultProfile()
+	}
+
+	if name == "" {
+		name = DefaultProfile
+	}
+
+	if !profileNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid profile name %s, use letters, digits, dots, dashes and underscores", name)
+	}
+
+	Profile = name
+	EnvPath = profileEnvPath(name)
+	return nil
+}
+
+// ProfileExists tells whether the settings file of the profile exists
+func ProfileExists(name string) bool {
+	_, err := os.Stat(profileEnvPath(name))
+	return err == nil
+}
+
+// ListProfiles returns the names of the configured profiles
+func ListProfiles() ([]string, error) {
+	profiles := []string{DefaultProfile}
+
+	entries, err := os.ReadDir(ProfilesPath)
+	if os.IsNotExist(err) {
+		return profiles, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the profiles: %v", err)
+	}
+
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".env")
+		if !entry.IsDir() && name != entry.Name() && name != DefaultProfile {
+			profiles = append(profiles, name)
+		}
+	}
+
+	sort.Strings(profiles[1:])
+	return profiles, nil
+}
+
+// DefaultProfileName returns the profile used when none is selected
+func DefaultProfileName() string {
+	if name := readDefaultProfile(); name != "" {
+		return name
+	}
+	return DefaultProfile
+}
+
+// SetDefaultProfile makes name the profile used when none is selected
+func SetDefaultProfile(name string) error {
+	if !ProfileExists(name) {
+		return fmt.Errorf("profile %s doesn't exist, create it with denv config --profile %s", name, name)
+	}
+
+	err := os.WriteFile(DefaultProfilePath, []byte(name+"\n"), 0600)
+	if err != nil {
+		return fmt.Errorf("failed to save the default profile: %v", err)
+	}
+
+	return nil
+}
+
+func readDefaultProfile() string {
+	content, err := os.ReadFile(DefaultProfilePath)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(content))
+}
+
+func profileEnvPath(name string) string {
+	if name == DefaultProfile {
+		return path.Join(ProjectPath, ".env")
+	}
+	return path.Join(ProfilesPath, name+".env")
+}
@@ -0,0 +1,217 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/joho/godotenv"
+)
+
+// Setting is a value of a profile, stored under the name of the environment
+// variable that overrides it
+type Setting struct {
+	Name        string
+	Env         string
+	Description string
+	// Secret settings are masked when listed
+	Secret bool
+	// Choices are the only values accepted, when there are any
+	Choices []string
+	// Kind is "bool" or "int" for values that must parse as such
+	Kind string
+}
+
+// Settings are the values denv config set accepts
+var Settings = []Setting{
+	{Name: "backend", Env: "DENV_BACKEND", Description: "Storage backend", Choices: []string{BackendS3, BackendLocal}},
+	{Name: "local_path", Env: "DENV_LOCAL_PATH", Description: "Directory of the local backend"},
+	{Name: "encryption", Env: "DENV_ENCRYPTION", Description: "How files are encrypted", Choices: []string{EncryptionNone, EncryptionPassphrase, EncryptionKeyFile}},
+	{Name: "allow_plaintext", Env: "DENV_ALLOW_PLAINTEXT", Description: "Read files that aren't encrypted, such as the ones uploaded before encryption was enabled", Kind: "bool"},
+	{Name: "ssh_key", Env: "DENV_SSH_KEY", Description: "SSH private key decrypting the keyfile mode"},
+	{Name: "access_key", Env: "AWS_ACCESS_KEY", Description: "AWS access key"},
+	{Name: "secret_key", Env: "AWS_SECRET_KEY", Description: "AWS secret key", Secret: true},
+	{Name: "credentials", Env: "DENV_AWS_CREDENTIALS", Description: "Use the stored keys, or find credentials like the AWS CLI", Choices: []string{CredentialsStatic, CredentialsChain}},
+	{Name: "aws_profile", Env: "DENV_AWS_PROFILE", Description: "Profile of ~/.aws/config used by the chain credentials"},
+	{Name: "bucket", Env: "AWS_BUCKET_NAME", Description: "S3 bucket name"},
+	{Name: "region", Env: "AWS_BUCKET_REGION", Description: "S3 bucket region"},
+	{Name: "endpoint", Env: "DENV_S3_ENDPOINT", Description: "Endpoint of an S3-compatible service"},
+	{Name: "path_style", Env: "DENV_S3_PATH_STYLE", Description: "Use path-style addressing", Kind: "bool"},
+	{Name: "insecure_skip_verify", Env: "DENV_S3_INSECURE_SKIP_VERIFY", Description: "Skip TLS certificate verification", Kind: "bool"},
+	{Name: "ca_bundle", Env: "DENV_S3_CA_BUNDLE", Description: "PEM CA bundle to trust"},
+	{Name: "part_size_mb", Env: "DENV_S3_PART_SIZE_MB", Description: "Size of the parts of large uploads", Kind: "int"},
+	{Name: "concurrency", Env: "DENV_S3_CONCURRENCY", Description: "Parts uploaded at once", Kind: "int"},
+	{Name: "role_arn", Env: "DENV_AWS_ROLE_ARN", Description: "IAM role assumed to reach the bucket"},
+	{Name: "external_id", Env: "DENV_AWS_EXTERNAL_ID", Description: "External ID the role trust policy asks for"},
+	{Name: "role_session_name", Env: "DENV_AWS_ROLE_SESSION_NAME", Description: "Session name of the assumed role"},
+	{Name: "mfa_serial", Env: "DENV_AWS_MFA_SERIAL", Description: "MFA device the role trust policy asks for"},
+	{Name: "role_duration_minutes", Env: "DENV_AWS_ROLE_DURATION_MINUTES", Description: "How long the role credentials last", Kind: "int"},
+	{Name: "sts_endpoint", Env: "DENV_STS_ENDPOINT", Description: "Endpoint of an STS stand-in such as LocalStack"},
+}
+
+// FindSetting looks a setting up by name or by environment variable, dashes
+// are read as underscores so flag names work too
+func FindSetting(name string) (Setting, bool) {
+	name = strings.ReplaceAll(name, "-", "_")
+
+	for _, setting := range Settings {
+		if strings.EqualFold(setting.Name, name) || strings.EqualFold(setting.Env, name) {
+			return setting, true
+		}
+	}
+
+	return Setting{}, false
+}
+
+// Validate checks value is accepted by the setting, an empty value clears it
+func (setting Setting) Validate(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	if len(setting.Choices) > 0 {
+		for _, choice := range setting.Choices {
+			if value == choice {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s must be one of: %s", setting.Name, strings.Join(setting.Choices, ", "))
+	}
+
+	switch setting.Kind {
+	case "bool":
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("%s must be true or false", setting.Name)
+		}
+	case "int":
+		if number, err := strconv.Atoi(value); err != nil || number < 0 {
+			return fmt.Errorf("%s must be a positive number", setting.Name)
+		}
+	}
+
+	return nil
+}
+
+// userEnv holds the variables set before denv loaded the settings
+var userEnv map[string]bool
+
+// rememberUserEnviron notes which variables the user set, the first time only
+func rememberUserEnviron() {
+	if userEnv != nil {
+		return
+	}
+
+	userEnv = make(map[string]bool)
+	for _, variable := range os.Environ() {
+		key, _, _ := strings.Cut(variable, "=")
+		userEnv[key] = true
+	}
+}
+
+// UserEnviron is os.Environ without the settings denv loaded itself, so the
+// credentials it keeps don't leak into the commands it runs
+func UserEnviron() []string {
+	environ := os.Environ()
+	if userEnv == nil {
+		return environ
+	}
+
+	var kept []string
+	for _, variable := range environ {
+		key, _, _ := strings.Cut(variable, "=")
+		if userEnv[key] {
+			kept = append(kept, variable)
+		}
+	}
+
+	return kept
+}
+
+// ReadSettings reads the settings file of the selected profile, without the
+// environment variables overriding it
+func ReadSettings() (map[string]string, error) {
+	values, err := readSettingsFile(EnvPath)
+	if os.IsNotExist(err) {
+		return make(map[string]string), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", EnvPath, err)
+	}
+
+	return values, nil
+}
+
+// SetSettings stores values in the selected profile, keyed by setting name or
+// environment variable, and creates the profile when it doesn't exist yet
+func SetSettings(values map[string]string) error {
+	current, err := ReadSettings()
+	if err != nil {
+		return err
+	}
+
+	for name, value := range values {
+		setting, ok := FindSetting(name)
+		if !ok {
+			return fmt.Errorf("unknown setting %s", name)
+		}
+
+		err = setting.Validate(value)
+		if err != nil {
+			return err
+		}
+
+		current[setting.Env] = value
+		// Later reads in this process see the new value as well
+		os.Setenv(setting.Env, value)
+	}
+
+	return writeSettings(current)
+}
+
+// writeSettings replaces the settings file of the selected profile
+func writeSettings(values map[string]string) error {
+	err := os.MkdirAll(path.Dir(EnvPath), ReadWriteExecutePermission)
+	if err != nil {
+		return fmt.Errorf("failed to create the profile directory: %v", err)
+	}
+
+	// Drop the cleared settings instead of writing them empty
+	for key, value := range values {
+		if value == "" {
+			delete(values, key)
+		}
+	}
+
+	content, err := godotenv.Marshal(values)
+	if err != nil {
+		return err
+	}
+
+	// A new profile is locked like the default one
+	lock := IsLocked()
+	if _, err := os.Stat(EnvPath); os.IsNotExist(err) && isLockedFile(profileEnvPath(DefaultProfile)) {
+		_, err = readSettingsFile(profileEnvPath(DefaultProfile))
+		if err != nil {
+			return err
+		}
+		lock = true
+	}
+
+	err = writeSettingsFile(EnvPath, []byte(content+"\n"), lock)
+	if err != nil {
+		return fmt.Errorf("failed to write credentials: %v", err)
+	}
+
+	return nil
+}
+
+// LookupUserEnv is os.LookupEnv limited to the variables of UserEnviron
+func LookupUserEnv(key string) (string, bool) {
+	if userEnv != nil && !userEnv[key] {
+		return "", false
+	}
+
+	return os.LookupEnv(key)
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// SyncRecord is the version of a file this machine last downloaded or uploaded
+type SyncRecord struct {
+	ETag      string    `json:"etag"`
+	VersionID string    `json:"version_id,omitempty"`
+	SyncedAt  time.Time `json:"synced_at"`
+}
+
+// SyncState keeps a SyncRecord per storage and file nickname, so the same
+// nickname in two buckets is tracked separately
+type SyncState struct {
+	Storages map[string]map[string]SyncRecord `json:"storages"`
+}
+
+// LoadSyncState reads the state file, a missing file is an empty state
+func LoadSyncState() (*SyncState, error) {
+	state := &SyncState{Storages: make(map[string]map[string]SyncRecord)}
+
+	content, err := os.ReadFile(StatePath)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the sync state: %v", err)
+	}
+
+	err = json.Unmarshal(content, state)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", StatePath, err)
+	}
+
+	if state.Storages == nil {
+		state.Storages = make(map[string]map[string]SyncRecord)
+	}
+
+	return state, nil
+}
+
+// Save writes the state file
+func (state *SyncState) Save() error {
+	content, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(StatePath, append(content, '\n'), 0600)
+	if err != nil {
+		return fmt.Errorf("failed to save the sync state: %v", err)
+	}
+
+	return nil
+}
+
+func (state *SyncState) Get(storage, name string) (SyncRecord, bool) {
+	record, exists := state.Storages[storage][name]
+	return record, exists
+}
+
+func (state *SyncState) Set(storage, name string, record SyncRecord) {
+	if state.Storages[storage] == nil {
+		state.Storages[storage] = make(map[string]SyncRecord)
+	}
+
+	state.Storages[storage][name] = record
+}
+
+func (state *SyncState) Delete(storage, name string) {
+	delete(state.Storages[storage], name)
+}
+
+// StorageID identifies the bucket or directory files are stored in
+func (creds AWSCredentials) StorageID() string {
+	if creds.Backend == BackendLocal {
+		return "local:" + creds.LocalPath
+	}
+
+	if creds.Endpoint != "" {
+		return "s3:" + creds.Endpoint + "/" + creds.BucketName
+	}
+
+	return "s3:" + creds.BucketName
+}
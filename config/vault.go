@@ -0,0 +1,220 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"filippo.io/age"
+	"github.com/joho/godotenv"
+)
+
+// lockedHeader starts the settings files encrypted with the master passphrase
+const lockedHeader = "age-encryption.org/v1\n"
+
+// SettingsFilePermission keeps the settings readable by their owner only,
+// they hold the AWS secret key
+const SettingsFilePermission = 0600
+
+// MasterPassphrase is asked for the passphrase of locked settings files the
+// first time one is read, nil when there is nobody to ask
+var MasterPassphrase func() (string, error)
+
+// ErrWrongPassphrase is returned when the master passphrase doesn't decrypt the settings
+var ErrWrongPassphrase = errors.New("wrong master passphrase")
+
+// lockWorkFactor is the scrypt cost of the master passphrase, the age default
+var lockWorkFactor = 18
+
+// masterPassphrase is kept once known, so writing the settings back locks
+// them with the same passphrase
+var masterPassphrase string
+
+// IsLocked tells whether the settings of the selected profile are encrypted
+func IsLocked() bool {
+	return isLockedFile(EnvPath)
+}
+
+func isLockedFile(filePath string) bool {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	header := make([]byte, len(lockedHeader))
+	_, err = io.ReadFull(file, header)
+	return err == nil && string(header) == lockedHeader
+}
+
+// readSettingsFile parses a settings file, decrypting it when it is locked
+func readSettingsFile(filePath string) (map[string]string, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.HasPrefix(content, []byte(lockedHeader)) {
+		content, err = decryptSettings(content)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return godotenv.UnmarshalBytes(content)
+}
+
+func decryptSettings(content []byte) ([]byte, error) {
+	if masterPassphrase == "" {
+		if MasterPassphrase == nil {
+			return nil, errors.New("the settings are locked, run denv config unlock")
+		}
+
+		passphrase, err := MasterPassphrase()
+		if err != nil {
+			return nil, err
+		}
+		masterPassphrase = passphrase
+	}
+
+	plaintext, err := decryptWithPassphrase(content, masterPassphrase)
+	if err != nil {
+		// Ask again next time instead of retrying a wrong passphrase
+		masterPassphrase = ""
+		return nil, err
+	}
+
+	return plaintext, nil
+}
+
+func decryptWithPassphrase(content []byte, passphrase string) ([]byte, error) {
+	identity, err := age.NewScryptIdentity(passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	decrypter, err := age.Decrypt(bytes.NewReader(content), identity)
+	if err != nil {
+		var noMatch *age.NoIdentityMatchError
+		if errors.As(err, &noMatch) {
+			return nil, ErrWrongPassphrase
+		}
+		return nil, fmt.Errorf("failed to decrypt the settings: %v", err)
+	}
+
+	return io.ReadAll(decrypter)
+}
+
+func encryptWithPassphrase(plaintext []byte, passphrase string) ([]byte, error) {
+	recipient, err := age.NewScryptRecipient(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	recipient.SetWorkFactor(lockWorkFactor)
+
+	var encrypted bytes.Buffer
+
+	encrypter, err := age.Encrypt(&encrypted, recipient)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = encrypter.Write(plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	err = encrypter.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return encrypted.Bytes(), nil
+}
+
+// writeSettingsFile replaces a settings file, encrypted when lock is true
+func writeSettingsFile(filePath string, content []byte, lock bool) error {
+	if lock {
+		encrypted, err := encryptWithPassphrase(content, masterPassphrase)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt the settings: %v", err)
+		}
+		content = encrypted
+	}
+
+	err := os.WriteFile(filePath, content, SettingsFilePermission)
+	if err != nil {
+		return err
+	}
+
+	// WriteFile keeps the permissions of files that already existed
+	return os.Chmod(filePath, SettingsFilePermission)
+}
+
+// CheckMasterPassphrase tells whether passphrase decrypts the selected profile
+func CheckMasterPassphrase(passphrase string) error {
+	content, err := os.ReadFile(EnvPath)
+	if err != nil {
+		return err
+	}
+
+	_, err = decryptWithPassphrase(content, passphrase)
+	return err
+}
+
+// LockSettings encrypts the settings of every profile with passphrase, the
+// ones already locked must be locked with it too
+func LockSettings(passphrase string) error {
+	if passphrase == "" {
+		return errors.New("the master passphrase can't be empty")
+	}
+
+	return rewriteProfiles(passphrase, true)
+}
+
+// UnlockSettings decrypts the settings of every profile for good
+func UnlockSettings(passphrase string) error {
+	return rewriteProfiles(passphrase, false)
+}
+
+func rewriteProfiles(passphrase string, lock bool) error {
+	profiles, err := ListProfiles()
+	if err != nil {
+		return err
+	}
+
+	masterPassphrase = passphrase
+
+	for _, profile := range profiles {
+		filePath := profileEnvPath(profile)
+
+		content, err := os.ReadFile(filePath)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if bytes.HasPrefix(content, []byte(lockedHeader)) {
+			content, err = decryptWithPassphrase(content, passphrase)
+			if err != nil {
+				return fmt.Errorf("failed to decrypt the %s profile: %w", profile, err)
+			}
+		}
+
+		err = writeSettingsFile(filePath, content, lock)
+		if err != nil {
+			return fmt.Errorf("failed to write the %s profile: %v", profile, err)
+		}
+	}
+
+	// Role credentials outlive the lock otherwise
+	if lock {
+		os.RemoveAll(CachePath)
+	}
+
+	return nil
+}
@@ -0,0 +1,167 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+// askPassphrase answers the master passphrase prompt with passphrase, as if it
+// was typed in a new process
+func askPassphrase(passphrase string) {
+	masterPassphrase = ""
+	MasterPassphrase = func() (string, error) {
+		return passphrase, nil
+	}
+}
+
+// checkSettingsFile makes sure filePath is only readable by its owner and
+// tells whether it is locked
+func checkSettingsFile(t *testing.T, filePath string) bool {
+	t.Helper()
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if mode := info.Mode().Perm(); mode != SettingsFilePermission {
+		t.Errorf("%s mode = %o, want %o", filePath, mode, SettingsFilePermission)
+	}
+
+	return isLockedFile(filePath)
+}
+
+func TestLockAndUnlockSettings(t *testing.T) {
+	newTestConfig(t)
+
+	if err := writeSettings(map[string]string{"AWS_BUCKET_NAME": "secret-bucket"}); err != nil {
+		t.Fatalf("writeSettings: %v", err)
+	}
+	if err := SelectProfile("staging"); err != nil {
+		t.Fatalf("SelectProfile: %v", err)
+	}
+	if err := writeSettings(map[string]string{"AWS_BUCKET_NAME": "staging-bucket"}); err != nil {
+		t.Fatalf("writeSettings: %v", err)
+	}
+
+	if err := LockSettings("master"); err != nil {
+		t.Fatalf("LockSettings: %v", err)
+	}
+
+	// Every profile is locked and nothing is left in plaintext
+	for _, profile := range []string{DefaultProfile, "staging"} {
+		filePath := profileEnvPath(profile)
+		if !checkSettingsFile(t, filePath) {
+			t.Errorf("the %s profile isn't locked", profile)
+		}
+		if content, _ := os.ReadFile(filePath); strings.Contains(string(content), "bucket") {
+			t.Errorf("the %s profile holds plaintext: %q", profile, content)
+		}
+	}
+
+	askPassphrase("master")
+	values, err := ReadSettings()
+	if err != nil {
+		t.Fatalf("ReadSettings: %v", err)
+	}
+	if values["AWS_BUCKET_NAME"] != "staging-bucket" {
+		t.Errorf("AWS_BUCKET_NAME = %q, want it decrypted", values["AWS_BUCKET_NAME"])
+	}
+
+	if err := UnlockSettings("master"); err != nil {
+		t.Fatalf("UnlockSettings: %v", err)
+	}
+
+	for _, profile := range []string{DefaultProfile, "staging"} {
+		if checkSettingsFile(t, profileEnvPath(profile)) {
+			t.Errorf("the %s profile is still locked", profile)
+		}
+	}
+
+	MasterPassphrase = nil
+	values, err = ReadSettings()
+	if err != nil {
+		t.Fatalf("ReadSettings after unlock: %v", err)
+	}
+	if values["AWS_BUCKET_NAME"] != "staging-bucket" {
+		t.Errorf("AWS_BUCKET_NAME = %q after unlock, want it kept", values["AWS_BUCKET_NAME"])
+	}
+}
+
+func TestWrongMasterPassphrase(t *testing.T) {
+	newTestConfig(t)
+
+	if err := writeSettings(map[string]string{"AWS_BUCKET_NAME": "bucket"}); err != nil {
+		t.Fatalf("writeSettings: %v", err)
+	}
+	if err := LockSettings("right"); err != nil {
+		t.Fatalf("LockSettings: %v", err)
+	}
+
+	askPassphrase("wrong")
+	if _, err := ReadSettings(); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("ReadSettings = %v, want ErrWrongPassphrase", err)
+	}
+	if masterPassphrase != "" {
+		t.Error("the wrong passphrase was kept for the next read")
+	}
+
+	if err := CheckMasterPassphrase("wrong"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("CheckMasterPassphrase = %v, want ErrWrongPassphrase", err)
+	}
+	if err := UnlockSettings("wrong"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("UnlockSettings = %v, want ErrWrongPassphrase", err)
+	}
+	if !IsLocked() {
+		t.Error("a wrong passphrase unlocked the settings")
+	}
+
+	// Nobody to ask, e.g. without a terminal
+	masterPassphrase = ""
+	MasterPassphrase = nil
+	if _, err := ReadSettings(); err == nil {
+		t.Error("ReadSettings of locked settings succeeded without a passphrase")
+	}
+}
+
+func TestNewProfileInheritsTheLock(t *testing.T) {
+	newTestConfig(t)
+
+	if err := writeSettings(map[string]string{"AWS_BUCKET_NAME": "bucket"}); err != nil {
+		t.Fatalf("writeSettings: %v", err)
+	}
+	if err := LockSettings("master"); err != nil {
+		t.Fatalf("LockSettings: %v", err)
+	}
+
+	askPassphrase("master")
+	if err := SelectProfile("new"); err != nil {
+		t.Fatalf("SelectProfile: %v", err)
+	}
+	if err := SetSettings(map[string]string{"bucket": "new-bucket"}); err != nil {
+		t.Fatalf("SetSettings: %v", err)
+	}
+
+	if !checkSettingsFile(t, profileEnvPath("new")) {
+		t.Fatal("the new profile was written in plaintext")
+	}
+
+	askPassphrase("master")
+	values, err := ReadSettings()
+	if err != nil {
+		t.Fatalf("ReadSettings: %v", err)
+	}
+	if values["AWS_BUCKET_NAME"] != "new-bucket" {
+		t.Errorf("AWS_BUCKET_NAME = %q, want the new profile readable with the master passphrase", values["AWS_BUCKET_NAME"])
+	}
+
+	// The default profile can't be used to guess another passphrase
+	askPassphrase("other")
+	if err := SelectProfile("other"); err != nil {
+		t.Fatalf("SelectProfile: %v", err)
+	}
+	if err := SetSettings(map[string]string{"bucket": "x"}); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("SetSettings with a wrong passphrase = %v, want ErrWrongPassphrase", err)
+	}
+}
@@ -0,0 +1,134 @@
+package crypt
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"filippo.io/age"
+)
+
+// Keys provides the recipients files are encrypted to and the identities used to decrypt them
+type Keys interface {
+	Recipients() ([]age.Recipient, error)
+	Identities() ([]age.Identity, error)
+}
+
+// passphraseWorkFactor is the scrypt cost of the passphrase, the age default
+var passphraseWorkFactor = 18
+
+// PassphraseKeys derives the keys from a passphrase, which is only asked for
+// the first time it is needed
+type PassphraseKeys struct {
+	ask        func() (string, error)
+	once       sync.Once
+	passphrase string
+	err        error
+}
+
+func NewPassphraseKeys(ask func() (string, error)) *PassphraseKeys {
+	return &PassphraseKeys{ask: ask}
+}
+
+func (pk *PassphraseKeys) Recipients() ([]age.Recipient, error) {
+	passphrase, err := pk.getPassphrase()
+	if err != nil {
+		return nil, err
+	}
+
+	recipient, err := age.NewScryptRecipient(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	recipient.SetWorkFactor(passphraseWorkFactor)
+
+	return []age.Recipient{recipient}, nil
+}
+
+func (pk *PassphraseKeys) Identities() ([]age.Identity, error) {
+	passphrase, err := pk.getPassphrase()
+	if err != nil {
+		return nil, err
+	}
+
+	identity, err := age.NewScryptIdentity(passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	return []age.Identity{identity}, nil
+}
+
+func (pk *PassphraseKeys) getPassphrase() (string, error) {
+	pk.once.Do(func() {
+		pk.passphrase, pk.err = pk.ask()
+		if pk.err == nil && pk.passphrase == "" {
+			pk.err = errors.New("the passphrase can't be empty")
+		}
+	})
+
+	return pk.passphrase, pk.err
+}
+
+// KeyFile uses an age X25519 identity stored on disk
+type KeyFile struct {
+	identity *age.X25519Identity
+}
+
+func LoadKeyFile(keyPath string) (*KeyFile, error) {
+	content, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %v", err)
+	}
+
+	identities, err := age.ParseIdentities(strings.NewReader(string(content)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse key file: %v", err)
+	}
+
+	for _, identity := range identities {
+		if x25519, ok := identity.(*age.X25519Identity); ok {
+			return &KeyFile{identity: x25519}, nil
+		}
+	}
+
+	return nil, errors.New("no X25519 identity found in the key file")
+}
+
+// GenerateKeyFile creates a new identity at keyPath, readable only by the current user
+func GenerateKeyFile(keyPath string) (*KeyFile, error) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %v", err)
+	}
+
+	content := fmt.Sprintf("# public key: %s\n%s\n", identity.Recipient(), identity)
+
+	file, err := os.OpenFile(keyPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create key file: %v", err)
+	}
+	defer file.Close()
+
+	_, err = file.WriteString(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write key file: %v", err)
+	}
+
+	return &KeyFile{identity: identity}, nil
+}
+
+// PublicKey returns the recipient string teammates need to encrypt files for this key
+func (kf *KeyFile) PublicKey() string {
+	return kf.identity.Recipient().String()
+}
+
+func (kf *KeyFile) Recipients() ([]age.Recipient, error) {
+	return []age.Recipient{kf.identity.Recipient()}, nil
+}
+
+func (kf *KeyFile) Identities() ([]age.Identity, error) {
+	return []age.Identity{kf.identity}, nil
+}
@@ -0,0 +1,193 @@
+package crypt
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/agessh"
+	"github.com/robertokbr/denv/bucket"
+)
+
+// RecipientsKey is where the team recipient list lives in the store. It only
+// holds public keys, so it is stored unencrypted.
+const RecipientsKey = bucket.InternalPrefix + "recipients"
+
+type Recipient struct {
+	PublicKey string
+	Comment   string
+}
+
+// RecipientList is the set of public keys every upload is encrypted to
+type RecipientList struct {
+	Recipients []Recipient
+}
+
+// ParseRecipient accepts age X25519 public keys and ssh-ed25519 or ssh-rsa public keys
+func ParseRecipient(publicKey string) (age.Recipient, error) {
+	if strings.HasPrefix(publicKey, "age1") {
+		return age.ParseX25519Recipient(publicKey)
+	}
+
+	if strings.HasPrefix(publicKey, "ssh-") {
+		return agessh.ParseRecipient(publicKey)
+	}
+
+	return nil, fmt.Errorf("unknown public key format: %s", publicKey)
+}
+
+// LoadRecipients reads the recipient list from the store, a missing list is empty
+func LoadRecipients(store bucket.Store) (*RecipientList, error) {
+	list := &RecipientList{}
+
+	body, _, err := store.Get(RecipientsKey)
+	if bucket.IsNotFound(err) {
+		return list, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recipients: %v", err)
+	}
+	defer body.Close()
+
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		recipient, err := splitRecipient(line)
+		if err != nil {
+			return nil, err
+		}
+
+		list.Recipients = append(list.Recipients, recipient)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read recipients: %v", err)
+	}
+
+	return list, nil
+}
+
+// Save writes the recipient list back to the store
+func (rl *RecipientList) Save(store bucket.Store) error {
+	var builder strings.Builder
+	builder.WriteString("# Public keys every denv upload is encrypted to\n")
+
+	for _, recipient := range rl.Recipients {
+		builder.WriteString(recipient.PublicKey)
+		if recipient.Comment != "" {
+			builder.WriteString(" # " + recipient.Comment)
+		}
+		builder.WriteString("\n")
+	}
+
+	_, err := store.Put(RecipientsKey, strings.NewReader(builder.String()), nil)
+	if err != nil {
+		return fmt.Errorf("failed to save recipients: %v", err)
+	}
+
+	return nil
+}
+
+// Add validates and appends a recipient, the comment usually names its owner
+func (rl *RecipientList) Add(publicKey, comment string) error {
+	if _, err := ParseRecipient(publicKey); err != nil {
+		return err
+	}
+
+	for _, recipient := range rl.Recipients {
+		if recipient.PublicKey == publicKey {
+			return errors.New("recipient already exists")
+		}
+	}
+
+	rl.Recipients = append(rl.Recipients, Recipient{PublicKey: publicKey, Comment: comment})
+	return nil
+}
+
+// Remove drops the recipients matching the public key or the comment
+func (rl *RecipientList) Remove(publicKeyOrComment string) []Recipient {
+	var kept, removed []Recipient
+
+	for _, recipient := range rl.Recipients {
+		if recipient.PublicKey == publicKeyOrComment || recipient.Comment == publicKeyOrComment {
+			removed = append(removed, recipient)
+			continue
+		}
+		kept = append(kept, recipient)
+	}
+
+	rl.Recipients = kept
+	return removed
+}
+
+// splitRecipient separates a "public-key # comment" line
+func splitRecipient(line string) (Recipient, error) {
+	publicKey, comment, _ := strings.Cut(line, "#")
+	publicKey = strings.TrimSpace(publicKey)
+
+	if _, err := ParseRecipient(publicKey); err != nil {
+		return Recipient{}, fmt.Errorf("invalid recipient %q: %v", publicKey, err)
+	}
+
+	return Recipient{PublicKey: publicKey, Comment: strings.TrimSpace(comment)}, nil
+}
+
+// TeamKeys encrypts to the local keys plus every recipient listed in the store,
+// and decrypts with whichever local key matches
+type TeamKeys struct {
+	own   []Keys
+	store bucket.Store
+}
+
+// NewTeamKeys reads the team recipients from store, which must be the raw
+// backend and not an encrypting Store
+func NewTeamKeys(store bucket.Store, own ...Keys) *TeamKeys {
+	return &TeamKeys{own: own, store: store}
+}
+
+func (tk *TeamKeys) Recipients() ([]age.Recipient, error) {
+	var recipients []age.Recipient
+
+	for _, keys := range tk.own {
+		ownRecipients, err := keys.Recipients()
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, ownRecipients...)
+	}
+
+	list, err := LoadRecipients(tk.store)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, recipient := range list.Recipients {
+		parsed, err := ParseRecipient(recipient.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, parsed)
+	}
+
+	return recipients, nil
+}
+
+func (tk *TeamKeys) Identities() ([]age.Identity, error) {
+	var identities []age.Identity
+
+	for _, keys := range tk.own {
+		ownIdentities, err := keys.Identities()
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, ownIdentities...)
+	}
+
+	return identities, nil
+}
@@ -0,0 +1,89 @@
+package crypt
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"filippo.io/age"
+	"filippo.io/age/agessh"
+	"golang.org/x/crypto/ssh"
+)
+
+// SSHKey uses an ed25519 or RSA SSH private key, so teammates can decrypt files
+// with the key they already have instead of a dedicated age key
+type SSHKey struct {
+	identity  age.Identity
+	recipient age.Recipient
+}
+
+// LoadSSHKey reads the private key at keyPath, askPassphrase is only called when
+// the key is protected and a file actually needs to be decrypted
+func LoadSSHKey(keyPath string, askPassphrase func() (string, error)) (*SSHKey, error) {
+	pemBytes, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SSH key: %v", err)
+	}
+
+	identity, err := agessh.ParseIdentity(pemBytes)
+	if err == nil {
+		return newSSHKey(identity)
+	}
+
+	var missingErr *ssh.PassphraseMissingError
+	if !errors.As(err, &missingErr) {
+		return nil, fmt.Errorf("failed to parse SSH key: %v", err)
+	}
+
+	// Older key formats don't embed the public key, fall back to the .pub file
+	publicKey := missingErr.PublicKey
+	if publicKey == nil {
+		publicKey, err = readSSHPublicKey(keyPath + ".pub")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	encrypted, err := agessh.NewEncryptedSSHIdentity(publicKey, pemBytes, func() ([]byte, error) {
+		passphrase, err := askPassphrase()
+		return []byte(passphrase), err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SSH key: %v", err)
+	}
+
+	return &SSHKey{identity: encrypted, recipient: encrypted.Recipient()}, nil
+}
+
+func newSSHKey(identity age.Identity) (*SSHKey, error) {
+	switch key := identity.(type) {
+	case *agessh.Ed25519Identity:
+		return &SSHKey{identity: key, recipient: key.Recipient()}, nil
+	case *agessh.RSAIdentity:
+		return &SSHKey{identity: key, recipient: key.Recipient()}, nil
+	default:
+		return nil, errors.New("unsupported SSH key type, use ed25519 or RSA")
+	}
+}
+
+func readSSHPublicKey(publicKeyPath string) (ssh.PublicKey, error) {
+	content, err := os.ReadFile(publicKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SSH public key: %v", err)
+	}
+
+	publicKey, _, _, _, err := ssh.ParseAuthorizedKey(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SSH public key: %v", err)
+	}
+
+	return publicKey, nil
+}
+
+func (sk *SSHKey) Recipients() ([]age.Recipient, error) {
+	return []age.Recipient{sk.recipient}, nil
+}
+
+func (sk *SSHKey) Identities() ([]age.Identity, error) {
+	return []age.Identity{sk.identity}, nil
+}
@@ -0,0 +1,210 @@
+package crypt
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"filippo.io/age"
+	"github.com/robertokbr/denv/bucket"
+)
+
+// ageHeader is how every binary age payload starts
+const ageHeader = "age-encryption.org/v1\n"
+
+// Warnings receives what must be seen about the files read, the CLI sends it
+// elsewhere when stdout is reserved for results
+var Warnings io.Writer = os.Stdout
+
+// errNotEncrypted refuses an object that isn't encrypted, anyone who can write
+// to the bucket could have put it there
+var errNotEncrypted = errors.New("the file isn't encrypted and may have been replaced by anyone with write access to the bucket, set allow_plaintext to true to read files uploaded before encryption was enabled")
+
+// Store wraps another bucket.Store encrypting every object before it is sent
+// and decrypting it back when it is read, so the backend only ever sees ciphertext
+type Store struct {
+	bucket.Store
+	keys Keys
+	// allowPlaintext reads objects that aren't encrypted as they are, such as
+	// the ones uploaded before encryption was enabled
+	allowPlaintext bool
+}
+
+func NewStore(inner bucket.Store, keys Keys, allowPlaintext bool) *Store {
+	return &Store{
+		Store:          inner,
+		keys:           keys,
+		allowPlaintext: allowPlaintext,
+	}
+}
+
+// Put encrypts body on the fly and streams the ciphertext to the backend. The
+// backend only gets a pipe, so an interrupted upload can't be resumed: age picks
+// a new file key on every encryption, and the parts already sent would never
+// match the ones of a second attempt anyway.
+func (cs *Store) Put(key string, body io.Reader, metadata map[string]string) (*bucket.ObjectInfo, error) {
+	recipients, err := cs.keys.Recipients()
+	if err != nil {
+		return nil, err
+	}
+
+	reader, writer := io.Pipe()
+
+	// Encrypt while the backend reads so the plaintext is never fully buffered here
+	go func() {
+		encrypter, err := age.Encrypt(writer, recipients...)
+		if err != nil {
+			writer.CloseWithError(fmt.Errorf("failed to encrypt: %v", err))
+			return
+		}
+
+		_, err = io.Copy(encrypter, body)
+		if err != nil {
+			writer.CloseWithError(err)
+			return
+		}
+
+		writer.CloseWithError(encrypter.Close())
+	}()
+
+	info, err := cs.Store.Put(key, reader, metadata)
+	reader.Close()
+
+	return info, err
+}
+
+func (cs *Store) Get(key string) (io.ReadCloser, *bucket.ObjectInfo, error) {
+	body, info, err := cs.Store.Get(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	plaintext, err := cs.decrypt(key, body)
+	if err != nil {
+		body.Close()
+		return nil, nil, fmt.Errorf("failed to decrypt %s: %w", key, err)
+	}
+
+	return plaintext, info, nil
+}
+
+func (cs *Store) VersioningEnabled() (bool, error) {
+	versioner, ok := cs.Store.(bucket.Versioner)
+	if !ok {
+		return false, nil
+	}
+
+	return versioner.VersioningEnabled()
+}
+
+func (cs *Store) ListVersions(key string) ([]bucket.ObjectInfo, error) {
+	versioner, ok := cs.Store.(bucket.Versioner)
+	if !ok {
+		return nil, errors.New("the storage backend has no versioning")
+	}
+
+	return versioner.ListVersions(key)
+}
+
+func (cs *Store) GetVersion(key, versionID string) (io.ReadCloser, *bucket.ObjectInfo, error) {
+	versioner, ok := cs.Store.(bucket.Versioner)
+	if !ok {
+		return nil, nil, errors.New("the storage backend has no versioning")
+	}
+
+	body, info, err := versioner.GetVersion(key, versionID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	plaintext, err := cs.decrypt(key, body)
+	if err != nil {
+		body.Close()
+		return nil, nil, fmt.Errorf("failed to decrypt %s: %w", key, err)
+	}
+
+	return plaintext, info, nil
+}
+
+// decrypt returns the plaintext of body, objects that aren't encrypted are
+// refused unless plaintext is allowed
+func (cs *Store) decrypt(key string, body io.ReadCloser) (io.ReadCloser, error) {
+	buffered := bufio.NewReader(body)
+
+	header, _ := buffered.Peek(len(ageHeader))
+	if !bytes.Equal(header, []byte(ageHeader)) {
+		if !cs.allowPlaintext {
+			return nil, errNotEncrypted
+		}
+
+		fmt.Fprintf(Warnings, "⚠️  %s isn't encrypted, it is read as it is because allow_plaintext is set\n", key)
+		return readCloser{Reader: buffered, Closer: body}, nil
+	}
+
+	identities, err := cs.keys.Identities()
+	if err != nil {
+		return nil, err
+	}
+
+	decrypter, err := age.Decrypt(buffered, identities...)
+	if err != nil {
+		return nil, noIdentityError(err)
+	}
+
+	return readCloser{Reader: decrypter, Closer: body}, nil
+}
+
+// noIdentityError reports a file encrypted to someone else as access denied
+func noIdentityError(err error) error {
+	var noMatch *age.NoIdentityMatchError
+	if errors.As(err, &noMatch) {
+		return &bucket.Error{Kind: bucket.ErrAccessDenied, Err: err}
+	}
+
+	return err
+}
+
+// Reencrypt encrypts the object stored under key again for the current recipients
+// and returns what was stored. It returns nil without touching the object when it
+// isn't encrypted.
+func (cs *Store) Reencrypt(key string) (*bucket.ObjectInfo, error) {
+	body, info, err := cs.Store.Get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.HasPrefix(ciphertext, []byte(ageHeader)) {
+		return nil, nil
+	}
+
+	identities, err := cs.keys.Identities()
+	if err != nil {
+		return nil, err
+	}
+
+	decrypter, err := age.Decrypt(bytes.NewReader(ciphertext), identities...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s: %w", key, noIdentityError(err))
+	}
+
+	plaintext, err := io.ReadAll(decrypter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s: %v", key, err)
+	}
+
+	return cs.Put(key, bytes.NewReader(plaintext), info.Metadata)
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
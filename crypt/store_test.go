@@ -0,0 +1,269 @@
+package crypt
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/robertokbr/denv/bucket"
+)
+
+// newTestBackend returns a local store in a temporary directory
+func newTestBackend(t *testing.T) bucket.Store {
+	t.Helper()
+
+	backend, err := bucket.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStore: %v", err)
+	}
+	return backend
+}
+
+func newTestKeyFile(t *testing.T) *KeyFile {
+	t.Helper()
+
+	keyFile, err := GenerateKeyFile(filepath.Join(t.TempDir(), "key.txt"))
+	if err != nil {
+		t.Fatalf("GenerateKeyFile: %v", err)
+	}
+	return keyFile
+}
+
+// newTestPassphrase returns keys derived from passphrase, with a scrypt cost
+// low enough to keep the tests fast
+func newTestPassphrase(t *testing.T, passphrase string) *PassphraseKeys {
+	t.Helper()
+
+	previous := passphraseWorkFactor
+	passphraseWorkFactor = 10
+	t.Cleanup(func() { passphraseWorkFactor = previous })
+
+	return NewPassphraseKeys(func() (string, error) {
+		return passphrase, nil
+	})
+}
+
+func readObject(store bucket.Store, key string) (string, error) {
+	body, _, err := store.Get(key)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	content, err := io.ReadAll(body)
+	return string(content), err
+}
+
+func TestPlaintextObjects(t *testing.T) {
+	backend := newTestBackend(t)
+	keys := newTestKeyFile(t)
+
+	// Uploaded before encryption was enabled, or by someone with write access
+	if _, err := backend.Put("old.env", strings.NewReader("LD_PRELOAD=/tmp/evil.so\n"), nil); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	_, err := readObject(NewStore(backend, keys, false), "old.env")
+	if !errors.Is(err, errNotEncrypted) {
+		t.Errorf("reading plaintext = %v, want it refused", err)
+	}
+
+	var warnings bytes.Buffer
+	previous := Warnings
+	Warnings = &warnings
+	t.Cleanup(func() { Warnings = previous })
+
+	content, err := readObject(NewStore(backend, keys, true), "old.env")
+	if err != nil {
+		t.Fatalf("reading plaintext with allowPlaintext: %v", err)
+	}
+	if content != "LD_PRELOAD=/tmp/evil.so\n" {
+		t.Errorf("content = %q, want the object as it is", content)
+	}
+	if !strings.Contains(warnings.String(), "old.env isn't encrypted") {
+		t.Errorf("warnings = %q, want one about old.env", warnings.String())
+	}
+}
+
+func TestPutAndGet(t *testing.T) {
+	tests := []struct {
+		name string
+		keys func(t *testing.T) Keys
+	}{
+		{"passphrase", func(t *testing.T) Keys { return newTestPassphrase(t, "correct horse") }},
+		{"key file", func(t *testing.T) Keys { return newTestKeyFile(t) }},
+	}
+
+	const content = "DB_PASSWORD=hunter2\n"
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newTestBackend(t)
+			store := NewStore(backend, tt.keys(t), false)
+
+			_, err := store.Put("app.env", strings.NewReader(content), map[string]string{"owner": "me"})
+			if err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+
+			// The backend only ever sees ciphertext
+			stored, err := readObject(backend, "app.env")
+			if err != nil {
+				t.Fatalf("reading the backend: %v", err)
+			}
+			if !strings.HasPrefix(stored, ageHeader) || strings.Contains(stored, "hunter2") {
+				t.Errorf("the backend holds %q, want an age payload", stored)
+			}
+
+			body, info, err := store.Get("app.env")
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			defer body.Close()
+
+			plaintext, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatalf("reading the plaintext: %v", err)
+			}
+			if string(plaintext) != content {
+				t.Errorf("Get = %q, want %q", plaintext, content)
+			}
+			if info.Metadata["owner"] != "me" {
+				t.Errorf("Metadata = %v, want it kept", info.Metadata)
+			}
+		})
+	}
+}
+
+func TestWrongKeyIsAccessDenied(t *testing.T) {
+	tests := []struct {
+		name          string
+		writer, other func(t *testing.T) Keys
+	}{
+		{
+			name:   "passphrase",
+			writer: func(t *testing.T) Keys { return newTestPassphrase(t, "right") },
+			other:  func(t *testing.T) Keys { return newTestPassphrase(t, "wrong") },
+		},
+		{
+			name:   "key file",
+			writer: func(t *testing.T) Keys { return newTestKeyFile(t) },
+			other:  func(t *testing.T) Keys { return newTestKeyFile(t) },
+		},
+		{
+			name:   "passphrase over a key file",
+			writer: func(t *testing.T) Keys { return newTestKeyFile(t) },
+			other:  func(t *testing.T) Keys { return newTestPassphrase(t, "guess") },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newTestBackend(t)
+
+			if _, err := NewStore(backend, tt.writer(t), false).Put("app.env", strings.NewReader("A=1\n"), nil); err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+
+			_, err := readObject(NewStore(backend, tt.other(t), false), "app.env")
+			if !errors.Is(err, bucket.ErrAccessDenied) {
+				t.Errorf("reading with another key = %v, want ErrAccessDenied", err)
+			}
+		})
+	}
+}
+
+func TestEmptyPassphrase(t *testing.T) {
+	store := NewStore(newTestBackend(t), newTestPassphrase(t, ""), false)
+
+	if _, err := store.Put("app.env", strings.NewReader("A=1\n"), nil); err == nil {
+		t.Error("Put with an empty passphrase succeeded")
+	}
+}
+
+func TestReencrypt(t *testing.T) {
+	backend := newTestBackend(t)
+	owner, teammate := newTestKeyFile(t), newTestKeyFile(t)
+
+	list, err := LoadRecipients(backend)
+	if err != nil {
+		t.Fatalf("LoadRecipients: %v", err)
+	}
+	if err := list.Add(teammate.PublicKey(), "teammate"); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := list.Save(backend); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	store := NewStore(backend, NewTeamKeys(backend, owner), false)
+	if _, err := store.Put("app.env", strings.NewReader("A=1\n"), map[string]string{"owner": "me"}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	teammateStore := NewStore(backend, teammate, false)
+	if content, err := readObject(teammateStore, "app.env"); err != nil || content != "A=1\n" {
+		t.Fatalf("teammate read %q (%v), want the file", content, err)
+	}
+
+	// Once removed, the teammate can't read the rewritten file anymore
+	list.Remove("teammate")
+	if err := list.Save(backend); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	before, err := backend.Stat("app.env")
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+
+	info, err := store.Reencrypt("app.env")
+	if err != nil {
+		t.Fatalf("Reencrypt: %v", err)
+	}
+	if info == nil || info.ETag == before.ETag {
+		t.Fatalf("Reencrypt = %+v, want the file rewritten", info)
+	}
+	if info.Metadata["owner"] != "me" {
+		t.Errorf("Metadata = %v after Reencrypt, want it kept", info.Metadata)
+	}
+
+	if _, err := readObject(teammateStore, "app.env"); !errors.Is(err, bucket.ErrAccessDenied) {
+		t.Errorf("removed teammate read = %v, want ErrAccessDenied", err)
+	}
+	if content, err := readObject(store, "app.env"); err != nil || content != "A=1\n" {
+		t.Errorf("owner read %q (%v), want the file", content, err)
+	}
+}
+
+func TestReencryptSkipsPlaintext(t *testing.T) {
+	backend := newTestBackend(t)
+	if _, err := backend.Put("old.env", strings.NewReader("A=1\n"), nil); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	info, err := NewStore(backend, newTestKeyFile(t), false).Reencrypt("old.env")
+	if err != nil || info != nil {
+		t.Errorf("Reencrypt = %+v, %v, want a plaintext file left alone", info, err)
+	}
+
+	if content, _ := readObject(backend, "old.env"); content != "A=1\n" {
+		t.Errorf("old.env = %q after Reencrypt, want it untouched", content)
+	}
+}
+
+func TestReencryptWithoutAccess(t *testing.T) {
+	backend := newTestBackend(t)
+
+	if _, err := NewStore(backend, newTestKeyFile(t), false).Put("app.env", strings.NewReader("A=1\n"), nil); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	_, err := NewStore(backend, newTestKeyFile(t), false).Reencrypt("app.env")
+	if !errors.Is(err, bucket.ErrAccessDenied) {
+		t.Errorf("Reencrypt with another key = %v, want ErrAccessDenied", err)
+	}
+}
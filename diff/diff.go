@@ -0,0 +1,51 @@
+package diff
+
+import (
+	"sort"
+)
+
+type ChangeType string
+
+const (
+	Added   ChangeType = "added"
+	Removed ChangeType = "removed"
+	Changed ChangeType = "changed"
+)
+
+// Change is a key, or a file path, that differs between two sides
+type Change struct {
+	Type     ChangeType
+	Key      string
+	OldValue string
+	NewValue string
+}
+
+// Maps compares two sets of key/value pairs, such as parsed dotenv files or the
+// content hashes of two file trees, sorting the changes by key
+func Maps(oldValues, newValues map[string]string) []Change {
+	var changes []Change
+
+	for key, oldValue := range oldValues {
+		newValue, exists := newValues[key]
+		if !exists {
+			changes = append(changes, Change{Type: Removed, Key: key, OldValue: oldValue})
+			continue
+		}
+
+		if newValue != oldValue {
+			changes = append(changes, Change{Type: Changed, Key: key, OldValue: oldValue, NewValue: newValue})
+		}
+	}
+
+	for key, newValue := range newValues {
+		if _, exists := oldValues[key]; !exists {
+			changes = append(changes, Change{Type: Added, Key: key, NewValue: newValue})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+
+	return changes
+}
@@ -0,0 +1,23 @@
+package diff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMaps(t *testing.T) {
+	changes := Maps(
+		map[string]string{"A": "1", "B": "1", "C": "1"},
+		map[string]string{"A": "1", "B": "2", "D": "4"},
+	)
+
+	want := []Change{
+		{Type: Changed, Key: "B", OldValue: "1", NewValue: "2"},
+		{Type: Removed, Key: "C", OldValue: "1"},
+		{Type: Added, Key: "D", NewValue: "4"},
+	}
+
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("Maps = %+v, want %+v", changes, want)
+	}
+}
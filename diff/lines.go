@@ -0,0 +1,150 @@
+package diff
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// maxCells caps the memory the line comparison may use, about 64 MB
+const maxCells = 16 * 1024 * 1024
+
+// ErrTooLarge is returned when two files are too large to be compared line by line
+var ErrTooLarge = errors.New("files are too large to compare line by line")
+
+type edit struct {
+	op      byte
+	text    string
+	oldLine int
+	newLine int
+}
+
+// Unified returns the unified diff between two texts with context lines around
+// every change, or an empty string when they are equal
+func Unified(oldName, newName, oldText, newText string, context int) (string, error) {
+	oldLines := splitLines(oldText)
+	newLines := splitLines(newText)
+
+	if (len(oldLines)+1)*(len(newLines)+1) > maxCells {
+		return "", ErrTooLarge
+	}
+
+	edits := lineEdits(oldLines, newLines)
+
+	var builder strings.Builder
+	for _, hunk := range hunks(edits, context) {
+		if builder.Len() == 0 {
+			fmt.Fprintf(&builder, "--- %s\n+++ %s\n", oldName, newName)
+		}
+		writeHunk(&builder, edits[hunk[0]:hunk[1]])
+	}
+
+	return builder.String(), nil
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// lineEdits turns the longest common subsequence of both sides into the list of
+// kept, removed and added lines
+func lineEdits(oldLines, newLines []string) []edit {
+	rows, cols := len(oldLines)+1, len(newLines)+1
+	lcs := make([]int32, rows*cols)
+
+	for i := len(oldLines) - 1; i >= 0; i-- {
+		for j := len(newLines) - 1; j >= 0; j-- {
+			if oldLines[i] == newLines[j] {
+				lcs[i*cols+j] = lcs[(i+1)*cols+j+1] + 1
+			} else if lcs[(i+1)*cols+j] >= lcs[i*cols+j+1] {
+				lcs[i*cols+j] = lcs[(i+1)*cols+j]
+			} else {
+				lcs[i*cols+j] = lcs[i*cols+j+1]
+			}
+		}
+	}
+
+	edits := make([]edit, 0, rows+cols)
+	i, j := 0, 0
+
+	for i < len(oldLines) || j < len(newLines) {
+		switch {
+		case i < len(oldLines) && j < len(newLines) && oldLines[i] == newLines[j]:
+			edits = append(edits, edit{op: ' ', text: oldLines[i], oldLine: i, newLine: j})
+			i++
+			j++
+		case j < len(newLines) && (i == len(oldLines) || lcs[i*cols+j+1] > lcs[(i+1)*cols+j]):
+			edits = append(edits, edit{op: '+', text: newLines[j], oldLine: i, newLine: j})
+			j++
+		default:
+			edits = append(edits, edit{op: '-', text: oldLines[i], oldLine: i, newLine: j})
+			i++
+		}
+	}
+
+	return edits
+}
+
+// hunks groups the changed edits with their surrounding context, changes closer
+// than twice the context end up in the same hunk
+func hunks(edits []edit, context int) [][2]int {
+	var groups [][2]int
+
+	for index, current := range edits {
+		if current.op == ' ' {
+			continue
+		}
+
+		start := index - context
+		if start < 0 {
+			start = 0
+		}
+
+		end := index + context + 1
+		if end > len(edits) {
+			end = len(edits)
+		}
+
+		if len(groups) > 0 && start <= groups[len(groups)-1][1] {
+			groups[len(groups)-1][1] = end
+			continue
+		}
+
+		groups = append(groups, [2]int{start, end})
+	}
+
+	return groups
+}
+
+func writeHunk(builder *strings.Builder, edits []edit) {
+	oldCount, newCount := 0, 0
+	for _, current := range edits {
+		if current.op != '+' {
+			oldCount++
+		}
+		if current.op != '-' {
+			newCount++
+		}
+	}
+
+	// Empty ranges point at the line before them
+	oldStart, newStart := edits[0].oldLine, edits[0].newLine
+	if oldCount > 0 {
+		oldStart++
+	}
+	if newCount > 0 {
+		newStart++
+	}
+
+	fmt.Fprintf(builder, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount)
+
+	for _, current := range edits {
+		builder.WriteByte(current.op)
+		builder.WriteString(current.text)
+		builder.WriteByte('\n')
+	}
+}
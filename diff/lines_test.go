@@ -0,0 +1,94 @@
+package diff
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// numberedLines returns n lines holding their number, or the text of changes
+func numberedLines(n int, changes map[int]string) string {
+	var builder strings.Builder
+	for i := 1; i <= n; i++ {
+		if text, changed := changes[i]; changed {
+			builder.WriteString(text + "\n")
+			continue
+		}
+		fmt.Fprintf(&builder, "%d\n", i)
+	}
+	return builder.String()
+}
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name    string
+		oldText string
+		newText string
+		want    string
+	}{
+		{
+			name:    "equal",
+			oldText: "a\nb\n",
+			newText: "a\nb\n",
+			want:    "",
+		},
+		{
+			name:    "changed line",
+			oldText: "a\nb\nc\n",
+			newText: "a\nB\nc\n",
+			want:    "--- old\n+++ new\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		{
+			name:    "from nothing",
+			oldText: "",
+			newText: "a\n",
+			want:    "--- old\n+++ new\n@@ -0,0 +1,1 @@\n+a\n",
+		},
+		{
+			name:    "to nothing",
+			oldText: "a\nb\n",
+			newText: "",
+			want:    "--- old\n+++ new\n@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+		{
+			name:    "no trailing newline",
+			oldText: "a\nb",
+			newText: "a\nc",
+			want:    "--- old\n+++ new\n@@ -1,2 +1,2 @@\n a\n-b\n+c\n",
+		},
+		{
+			name:    "distant changes get their own hunks",
+			oldText: numberedLines(20, nil),
+			newText: numberedLines(20, map[int]string{2: "two", 18: "eighteen"}),
+			want: "--- old\n+++ new\n@@ -1,5 +1,5 @@\n 1\n-2\n+two\n 3\n 4\n 5\n" +
+				"@@ -15,6 +15,6 @@\n 15\n 16\n 17\n-18\n+eighteen\n 19\n 20\n",
+		},
+		{
+			name:    "close changes share a hunk",
+			oldText: numberedLines(10, nil),
+			newText: numberedLines(10, map[int]string{3: "three", 8: "eight"}),
+			want:    "--- old\n+++ new\n@@ -1,10 +1,10 @@\n 1\n 2\n-3\n+three\n 4\n 5\n 6\n 7\n-8\n+eight\n 9\n 10\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Unified("old", "new", tt.oldText, tt.newText, 3)
+			if err != nil {
+				t.Fatalf("Unified: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Unified =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnifiedTooLarge(t *testing.T) {
+	large := strings.Repeat("line\n", 5000)
+
+	if _, err := Unified("old", "new", large, large+"more\n", 3); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Unified = %v, want ErrTooLarge", err)
+	}
+}
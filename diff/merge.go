@@ -0,0 +1,78 @@
+package diff
+
+import (
+	"sort"
+)
+
+// Side is the value of a key on one side of a merge, Exists is false when the
+// key is missing there
+type Side struct {
+	Value  string
+	Exists bool
+}
+
+// Conflict is a key changed differently on both sides since the base
+type Conflict struct {
+	Key    string
+	Base   Side
+	Local  Side
+	Remote Side
+}
+
+// Merge3 combines the remote changes into the local values, both made from
+// base. It returns the changes to apply to the local values, as Changes from
+// local to remote, and the keys both sides changed differently, sorted by key
+func Merge3(base, local, remote map[string]string) ([]Change, []Conflict) {
+	var changes []Change
+	var conflicts []Conflict
+
+	keys := make(map[string]bool)
+	for _, values := range []map[string]string{base, local, remote} {
+		for key := range values {
+			keys[key] = true
+		}
+	}
+
+	for key := range keys {
+		baseSide := side(base, key)
+		localSide := side(local, key)
+		remoteSide := side(remote, key)
+
+		switch {
+		// Both sides agree, or only the local side changed it
+		case localSide == remoteSide, remoteSide == baseSide:
+			continue
+		// Only the remote side changed it
+		case localSide == baseSide:
+			changes = append(changes, change(key, localSide, remoteSide))
+		default:
+			conflicts = append(conflicts, Conflict{Key: key, Base: baseSide, Local: localSide, Remote: remoteSide})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+
+	sort.Slice(conflicts, func(i, j int) bool {
+		return conflicts[i].Key < conflicts[j].Key
+	})
+
+	return changes, conflicts
+}
+
+func side(values map[string]string, key string) Side {
+	value, exists := values[key]
+	return Side{Value: value, Exists: exists}
+}
+
+func change(key string, oldSide, newSide Side) Change {
+	switch {
+	case !oldSide.Exists:
+		return Change{Type: Added, Key: key, NewValue: newSide.Value}
+	case !newSide.Exists:
+		return Change{Type: Removed, Key: key, OldValue: oldSide.Value}
+	default:
+		return Change{Type: Changed, Key: key, OldValue: oldSide.Value, NewValue: newSide.Value}
+	}
+}
@@ -0,0 +1,118 @@
+package diff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMerge3(t *testing.T) {
+	tests := []struct {
+		name          string
+		base          map[string]string
+		local         map[string]string
+		remote        map[string]string
+		wantChanges   []Change
+		wantConflicts []Conflict
+	}{
+		{
+			name:   "nothing changed",
+			base:   map[string]string{"A": "1"},
+			local:  map[string]string{"A": "1"},
+			remote: map[string]string{"A": "1"},
+		},
+		{
+			name:   "only local changes are kept",
+			base:   map[string]string{"A": "1", "B": "1"},
+			local:  map[string]string{"A": "2", "C": "3"},
+			remote: map[string]string{"A": "1", "B": "1"},
+		},
+		{
+			name:   "remote changes are applied",
+			base:   map[string]string{"A": "1", "B": "1", "C": "1"},
+			local:  map[string]string{"A": "1", "B": "1", "C": "1"},
+			remote: map[string]string{"A": "2", "C": "1", "D": "4"},
+			wantChanges: []Change{
+				{Type: Changed, Key: "A", OldValue: "1", NewValue: "2"},
+				{Type: Removed, Key: "B", OldValue: "1"},
+				{Type: Added, Key: "D", NewValue: "4"},
+			},
+		},
+		{
+			name:   "both sides made the same change",
+			base:   map[string]string{"A": "1", "B": "1"},
+			local:  map[string]string{"A": "2", "C": "3"},
+			remote: map[string]string{"A": "2", "C": "3"},
+		},
+		{
+			name:   "both sides changed a value differently",
+			base:   map[string]string{"A": "1"},
+			local:  map[string]string{"A": "2"},
+			remote: map[string]string{"A": "3"},
+			wantConflicts: []Conflict{
+				{Key: "A", Base: Side{"1", true}, Local: Side{"2", true}, Remote: Side{"3", true}},
+			},
+		},
+		{
+			name:   "both sides added a key with different values",
+			local:  map[string]string{"A": "local"},
+			remote: map[string]string{"A": "remote"},
+			wantConflicts: []Conflict{
+				{Key: "A", Local: Side{"local", true}, Remote: Side{"remote", true}},
+			},
+		},
+		{
+			name:   "local removed what remote changed",
+			base:   map[string]string{"A": "1"},
+			local:  map[string]string{},
+			remote: map[string]string{"A": "2"},
+			wantConflicts: []Conflict{
+				{Key: "A", Base: Side{"1", true}, Remote: Side{"2", true}},
+			},
+		},
+		{
+			name:   "remote removed what local changed",
+			base:   map[string]string{"A": "1"},
+			local:  map[string]string{"A": "2"},
+			remote: map[string]string{},
+			wantConflicts: []Conflict{
+				{Key: "A", Base: Side{"1", true}, Local: Side{"2", true}},
+			},
+		},
+		{
+			name:   "an empty value isn't a missing key",
+			base:   map[string]string{"A": ""},
+			local:  map[string]string{"A": ""},
+			remote: map[string]string{},
+			wantChanges: []Change{
+				{Type: Removed, Key: "A"},
+			},
+		},
+		{
+			name:   "changes and conflicts together are sorted by key",
+			base:   map[string]string{"Z": "1", "M": "1", "B": "1"},
+			local:  map[string]string{"Z": "2", "M": "1", "B": "2"},
+			remote: map[string]string{"Z": "3", "M": "3", "B": "3", "A": "new"},
+			wantChanges: []Change{
+				{Type: Added, Key: "A", NewValue: "new"},
+				{Type: Changed, Key: "M", OldValue: "1", NewValue: "3"},
+			},
+			wantConflicts: []Conflict{
+				{Key: "B", Base: Side{"1", true}, Local: Side{"2", true}, Remote: Side{"3", true}},
+				{Key: "Z", Base: Side{"1", true}, Local: Side{"2", true}, Remote: Side{"3", true}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes, conflicts := Merge3(tt.base, tt.local, tt.remote)
+
+			if !reflect.DeepEqual(changes, tt.wantChanges) {
+				t.Errorf("changes = %+v, want %+v", changes, tt.wantChanges)
+			}
+			if !reflect.DeepEqual(conflicts, tt.wantConflicts) {
+				t.Errorf("conflicts = %+v, want %+v", conflicts, tt.wantConflicts)
+			}
+		})
+	}
+}
@@ -0,0 +1,316 @@
+package dotenv
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/joho/godotenv"
+)
+
+var (
+	keyRegex        = regexp.MustCompile(`^[A-Za-z0-9_.]+$`)
+	plainValueRegex = regexp.MustCompile(`^[A-Za-z0-9_./:@,+=-]*$`)
+)
+
+// entry is one statement of the document, either a variable possibly spanning
+// several lines or a comment or blank line kept as it is
+type entry struct {
+	key   string
+	lines []string
+}
+
+// Document is a dotenv file that can be edited key by key while keeping its
+// comments, blank lines and ordering untouched
+type Document struct {
+	entries         []entry
+	trailingNewline bool
+}
+
+// Parse reads a dotenv file, the values follow the same rules as godotenv
+func Parse(content []byte) (*Document, error) {
+	text := strings.ReplaceAll(string(content), "\r\n", "\n")
+
+	if _, err := godotenv.Unmarshal(text); err != nil {
+		return nil, fmt.Errorf("invalid dotenv file: %v", err)
+	}
+
+	doc := &Document{trailingNewline: text == "" || strings.HasSuffix(text, "\n")}
+	if text == "" {
+		return doc, nil
+	}
+
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+
+	for i := 0; i < len(lines); i++ {
+		key, value, ok := splitStatement(lines[i])
+		if !ok {
+			doc.entries = append(doc.entries, entry{lines: []string{lines[i]}})
+			continue
+		}
+
+		// Quoted values may span several lines until the closing quote
+		current := entry{key: key, lines: []string{lines[i]}}
+		if quote, open := openQuote(value); open {
+			for i+1 < len(lines) {
+				i++
+				current.lines = append(current.lines, lines[i])
+				if closingQuoteIndex(lines[i], quote) >= 0 {
+					break
+				}
+			}
+		}
+
+		doc.entries = append(doc.entries, current)
+	}
+
+	return doc, nil
+}
+
+// Values returns every variable of the document
+func (doc *Document) Values() (map[string]string, error) {
+	return godotenv.Unmarshal(string(doc.Bytes()))
+}
+
+// Get returns the value of key as godotenv would load it
+func (doc *Document) Get(key string) (string, bool) {
+	values, err := doc.Values()
+	if err != nil {
+		return "", false
+	}
+
+	value, exists := values[key]
+	return value, exists
+}
+
+// Keys returns the variable names in the order they first appear
+func (doc *Document) Keys() []string {
+	var keys []string
+	seen := make(map[string]bool)
+
+	for _, current := range doc.entries {
+		if current.key != "" && !seen[current.key] {
+			seen[current.key] = true
+			keys = append(keys, current.key)
+		}
+	}
+
+	return keys
+}
+
+// Set updates key in place, keeping its export prefix and inline comment, or
+// appends it at the end of the document when it doesn't exist yet
+func (doc *Document) Set(key, value string) error {
+	if !keyRegex.MatchString(key) {
+		return fmt.Errorf("invalid variable name: %s", key)
+	}
+
+	last := -1
+	for index, current := range doc.entries {
+		if current.key == key {
+			last = index
+		}
+	}
+
+	if last == -1 {
+		doc.entries = append(doc.entries, entry{key: key, lines: []string{key + "=" + QuoteValue(value)}})
+		return nil
+	}
+
+	previous := doc.entries[last]
+	line := key + "=" + QuoteValue(value)
+
+	if strings.HasPrefix(strings.TrimSpace(previous.lines[0]), "export ") {
+		line = "export " + line
+	}
+
+	if comment := inlineComment(previous.lines); comment != "" {
+		line += " " + comment
+	}
+
+	// Only the last occurrence is effective, earlier duplicates stay untouched
+	doc.entries[last] = entry{key: key, lines: []string{line}}
+	return nil
+}
+
+// Unset removes every occurrence of key and reports whether it existed
+func (doc *Document) Unset(key string) bool {
+	kept := doc.entries[:0]
+	for _, current := range doc.entries {
+		if current.key != key {
+			kept = append(kept, current)
+		}
+	}
+
+	removed := len(kept) != len(doc.entries)
+	doc.entries = kept
+	return removed
+}
+
+// Bytes renders the document back to the dotenv format
+func (doc *Document) Bytes() []byte {
+	var lines []string
+	for _, current := range doc.entries {
+		lines = append(lines, current.lines...)
+	}
+
+	text := strings.Join(lines, "\n")
+	if doc.trailingNewline && text != "" {
+		text += "\n"
+	}
+
+	return []byte(text)
+}
+
+// QuoteValue formats value so godotenv reads it back unchanged
+func QuoteValue(value string) string {
+	if plainValueRegex.MatchString(value) {
+		return value
+	}
+
+	// Single quotes are taken literally, so nothing needs escaping
+	if !strings.ContainsAny(value, "'\n\r") {
+		return "'" + value + "'"
+	}
+
+	replacer := strings.NewReplacer(
+		`\`, `\\`,
+		"\n", `\n`,
+		"\r", `\r`,
+		`"`, `\"`,
+		`$`, `\$`,
+		"`", "\\`",
+		`!`, `\!`,
+	)
+
+	return `"` + replacer.Replace(value) + `"`
+}
+
+// splitStatement returns the key and the raw value of a variable line
+func splitStatement(line string) (string, string, bool) {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+		return "", "", false
+	}
+
+	if strings.HasPrefix(trimmed, "export ") {
+		trimmed = strings.TrimSpace(strings.TrimPrefix(trimmed, "export "))
+	}
+
+	separator := strings.IndexAny(trimmed, "=:")
+	if separator <= 0 {
+		return "", "", false
+	}
+
+	key := strings.TrimSpace(trimmed[:separator])
+	if !keyRegex.MatchString(key) {
+		return "", "", false
+	}
+
+	return key, strings.TrimSpace(trimmed[separator+1:]), true
+}
+
+// openQuote tells whether value starts a quoted string that isn't closed on the same line
+func openQuote(value string) (byte, bool) {
+	if value == "" || (value[0] != '"' && value[0] != '\'') {
+		return 0, false
+	}
+
+	return value[0], closingQuoteIndex(value[1:], value[0]) == -1
+}
+
+// closingQuoteIndex finds the first unescaped quote in text
+func closingQuoteIndex(text string, quote byte) int {
+	for i := 0; i < len(text); i++ {
+		if text[i] == quote && (i == 0 || text[i-1] != '\\') {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// inlineComment returns the "# comment" following the value of an entry
+func inlineComment(lines []string) string {
+	_, value, _ := splitStatement(lines[0])
+	quote, _ := openQuote(value)
+
+	rest := lines[len(lines)-1]
+	if len(lines) == 1 {
+		rest = value
+	}
+
+	// The comment of a quoted value can only follow the closing quote
+	if quote != 0 {
+		if len(lines) == 1 {
+			rest = rest[1:]
+		}
+
+		end := closingQuoteIndex(rest, quote)
+		if end == -1 {
+			return ""
+		}
+
+		rest = rest[end+1:]
+		if index := strings.Index(rest, "#"); index != -1 {
+			return strings.TrimSpace(rest[index:])
+		}
+		return ""
+	}
+
+	// Like godotenv, an unquoted value ends at the last # preceded by a space
+	index := strings.LastIndex(rest, " #")
+	if index == -1 {
+		return ""
+	}
+
+	return strings.TrimSpace(rest[index:])
+}
+
+const (
+	conflictStart     = "<<<<<<< local"
+	conflictSeparator = "======="
+	conflictEnd       = ">>>>>>> remote"
+)
+
+// MarkConflict replaces key with git style conflict markers around the local
+// statement and the remote value, the document can't be loaded until someone
+// keeps one side. A missing side leaves its half of the block empty
+func (doc *Document) MarkConflict(key, remoteValue string, remoteExists bool) {
+	lines := []string{conflictStart}
+
+	last := -1
+	for index, current := range doc.entries {
+		if current.key == key {
+			last = index
+		}
+	}
+
+	if last != -1 {
+		lines = append(lines, doc.entries[last].lines...)
+	}
+
+	lines = append(lines, conflictSeparator)
+	if remoteExists {
+		lines = append(lines, key+"="+QuoteValue(remoteValue))
+	}
+	lines = append(lines, conflictEnd)
+
+	if last == -1 {
+		doc.entries = append(doc.entries, entry{lines: lines})
+		return
+	}
+
+	doc.entries[last] = entry{lines: lines}
+}
+
+// HasConflictMarkers tells whether content still holds markers left by MarkConflict
+func HasConflictMarkers(content []byte) bool {
+	for _, line := range strings.Split(string(content), "\n") {
+		if strings.HasPrefix(line, conflictStart) || strings.HasPrefix(line, conflictEnd) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,317 @@
+package dotenv
+
+import (
+	"reflect"
+	"testing"
+)
+
+func parseString(t *testing.T, content string) *Document {
+	t.Helper()
+
+	doc, err := Parse([]byte(content))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	return doc
+}
+
+func TestParseKeepsTheFileUnchanged(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    map[string]string
+	}{
+		{
+			name: "empty",
+			want: map[string]string{},
+		},
+		{
+			name:    "comments and blank lines",
+			content: "# database\nDB_HOST=localhost\n\n  # indented comment\nDB_PORT=5432\n",
+			want:    map[string]string{"DB_HOST": "localhost", "DB_PORT": "5432"},
+		},
+		{
+			name:    "no trailing newline",
+			content: "A=1\nB=2",
+			want:    map[string]string{"A": "1", "B": "2"},
+		},
+		{
+			name:    "export prefix",
+			content: "export A=1\nexport B='two words'\n",
+			want:    map[string]string{"A": "1", "B": "two words"},
+		},
+		{
+			name:    "inline comments",
+			content: "A=1 # first\nB=\"quoted # not a comment\" # second\nC=value#kept\n",
+			want:    map[string]string{"A": "1", "B": "quoted # not a comment", "C": "value#kept"},
+		},
+		{
+			name:    "multiline quoted value",
+			content: "CERT=\"-----BEGIN-----\nline\n-----END-----\"\nAFTER=1\n",
+			want:    map[string]string{"CERT": "-----BEGIN-----\nline\n-----END-----", "AFTER": "1"},
+		},
+		{
+			name:    "duplicated keys",
+			content: "A=1\nA=2\n",
+			want:    map[string]string{"A": "2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := parseString(t, tt.content)
+
+			if got := string(doc.Bytes()); got != tt.content {
+				t.Errorf("Bytes = %q, want %q", got, tt.content)
+			}
+
+			values, err := doc.Values()
+			if err != nil {
+				t.Fatalf("Values: %v", err)
+			}
+			if !reflect.DeepEqual(values, tt.want) {
+				t.Errorf("Values = %v, want %v", values, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseWindowsLineEndings(t *testing.T) {
+	doc := parseString(t, "A=1\r\nB=2\r\n")
+
+	if got := string(doc.Bytes()); got != "A=1\nB=2\n" {
+		t.Errorf("Bytes = %q, want the line endings normalized", got)
+	}
+}
+
+func TestParseInvalidFile(t *testing.T) {
+	if _, err := Parse([]byte("A=\"never closed\n")); err == nil {
+		t.Error("an unclosed quote was accepted")
+	}
+}
+
+func TestKeys(t *testing.T) {
+	doc := parseString(t, "# comment\nB=1\nA=1\nB=2\nexport C=3\n")
+
+	want := []string{"B", "A", "C"}
+	if got := doc.Keys(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Keys = %v, want %v", got, want)
+	}
+}
+
+func TestSet(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		key     string
+		value   string
+		want    string
+	}{
+		{
+			name:    "new key is appended",
+			content: "# comment\nA=1\n",
+			key:     "B",
+			value:   "2",
+			want:    "# comment\nA=1\nB=2\n",
+		},
+		{
+			name:    "existing key is replaced in place",
+			content: "A=1\nB=2\nC=3\n",
+			key:     "B",
+			value:   "new",
+			want:    "A=1\nB=new\nC=3\n",
+		},
+		{
+			name:    "export prefix is kept",
+			content: "export A=1\n",
+			key:     "A",
+			value:   "2",
+			want:    "export A=2\n",
+		},
+		{
+			name:    "inline comment is kept",
+			content: "A=1 # the answer\n",
+			key:     "A",
+			value:   "2",
+			want:    "A=2 # the answer\n",
+		},
+		{
+			name:    "inline comment after a quoted value is kept",
+			content: "A=\"x # y\" # note\n",
+			key:     "A",
+			value:   "z",
+			want:    "A=z # note\n",
+		},
+		{
+			name:    "multiline value is replaced by one line",
+			content: "A=\"first\nsecond\"\nB=1\n",
+			key:     "A",
+			value:   "one",
+			want:    "A=one\nB=1\n",
+		},
+		{
+			name:    "only the last duplicate is replaced",
+			content: "A=1\nA=2\n",
+			key:     "A",
+			value:   "3",
+			want:    "A=1\nA=3\n",
+		},
+		{
+			name:    "file without trailing newline",
+			content: "A=1",
+			key:     "B",
+			value:   "2",
+			want:    "A=1\nB=2",
+		},
+		{
+			name:  "empty file",
+			key:   "A",
+			value: "with space",
+			want:  "A='with space'\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := parseString(t, tt.content)
+
+			if err := doc.Set(tt.key, tt.value); err != nil {
+				t.Fatalf("Set: %v", err)
+			}
+			if got := string(doc.Bytes()); got != tt.want {
+				t.Errorf("Bytes = %q, want %q", got, tt.want)
+			}
+			if got, _ := doc.Get(tt.key); got != tt.value {
+				t.Errorf("Get = %q, want %q", got, tt.value)
+			}
+		})
+	}
+}
+
+func TestSetInvalidKey(t *testing.T) {
+	doc := parseString(t, "A=1\n")
+
+	for _, key := range []string{"", "WITH SPACE", "A=B", "A-B", "É"} {
+		if err := doc.Set(key, "x"); err == nil {
+			t.Errorf("Set %q succeeded, want an error", key)
+		}
+	}
+
+	if got := string(doc.Bytes()); got != "A=1\n" {
+		t.Errorf("Bytes = %q after invalid sets, want the document unchanged", got)
+	}
+}
+
+func TestUnset(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		key         string
+		want        string
+		wantRemoved bool
+	}{
+		{
+			name:        "single key",
+			content:     "# comment\nA=1\nB=2\n",
+			key:         "A",
+			want:        "# comment\nB=2\n",
+			wantRemoved: true,
+		},
+		{
+			name:        "every duplicate",
+			content:     "A=1\nB=2\nA=3\n",
+			key:         "A",
+			want:        "B=2\n",
+			wantRemoved: true,
+		},
+		{
+			name:        "multiline value",
+			content:     "A=\"first\nsecond\"\nB=2\n",
+			key:         "A",
+			want:        "B=2\n",
+			wantRemoved: true,
+		},
+		{
+			name:    "missing key",
+			content: "A=1\n",
+			key:     "B",
+			want:    "A=1\n",
+		},
+		{
+			name:    "comments mentioning the key stay",
+			content: "# A=old\nB=2\n",
+			key:     "A",
+			want:    "# A=old\nB=2\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := parseString(t, tt.content)
+
+			if removed := doc.Unset(tt.key); removed != tt.wantRemoved {
+				t.Errorf("Unset = %v, want %v", removed, tt.wantRemoved)
+			}
+			if got := string(doc.Bytes()); got != tt.want {
+				t.Errorf("Bytes = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuoteValueRoundTrip(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"", ""},
+		{"plain", "plain"},
+		{"postgres://user@host:5432/db", "postgres://user@host:5432/db"},
+		{"two words", "'two words'"},
+		{"has # hash", "'has # hash'"},
+		{"$NOT_EXPANDED", "'$NOT_EXPANDED'"},
+		{`back\slash "quotes"`, `'back\slash "quotes"'`},
+		{"it's", `"it's"`},
+		{"first\nsecond", `"first\nsecond"`},
+		{"line\r\nending", `"line\r\nending"`},
+		{"it's $HOME `cmd` !bang \\ end", `"it's \$HOME \` + "`cmd\\`" + ` \!bang \\ end"`},
+	}
+
+	for _, tt := range tests {
+		quoted := QuoteValue(tt.value)
+		if quoted != tt.want {
+			t.Errorf("QuoteValue(%q) = %s, want %s", tt.value, quoted, tt.want)
+		}
+
+		// Whatever the quoting, godotenv must read the value back unchanged
+		doc := parseString(t, "")
+		if err := doc.Set("KEY", tt.value); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+
+		reparsed := parseString(t, string(doc.Bytes()))
+		if got, _ := reparsed.Get("KEY"); got != tt.value {
+			t.Errorf("value %q was read back as %q from %q", tt.value, got, doc.Bytes())
+		}
+	}
+}
+
+func TestMarkConflict(t *testing.T) {
+	doc := parseString(t, "A=1\nB=local\n")
+
+	doc.MarkConflict("B", "remote", true)
+	doc.MarkConflict("C", "", false)
+
+	want := "A=1\n<<<<<<< local\nB=local\n=======\nB=remote\n>>>>>>> remote\n" +
+		"<<<<<<< local\n=======\n>>>>>>> remote\n"
+	if got := string(doc.Bytes()); got != want {
+		t.Errorf("Bytes = %q, want %q", got, want)
+	}
+
+	if !HasConflictMarkers(doc.Bytes()) {
+		t.Error("HasConflictMarkers = false after MarkConflict")
+	}
+	if HasConflictMarkers([]byte("A=<<<<<<< local\n")) {
+		t.Error("HasConflictMarkers = true for markers inside a value")
+	}
+}
@@ -0,0 +1,296 @@
+package dotenv
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	FormatJSON          = "json"
+	FormatYAML          = "yaml"
+	FormatShell         = "shell"
+	FormatDockerEnv     = "docker-env"
+	FormatK8sSecret     = "k8s-secret"
+	FormatSystemd       = "systemd"
+	FormatGitHubActions = "github-actions"
+)
+
+// ExportFormats lists every format Export understands
+var ExportFormats = []string{
+	FormatJSON,
+	FormatYAML,
+	FormatShell,
+	FormatDockerEnv,
+	FormatK8sSecret,
+	FormatSystemd,
+	FormatGitHubActions,
+}
+
+var (
+	// yamlPlainKeyRegex matches keys YAML reads as strings without quotes
+	yamlPlainKeyRegex  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+	yamlReservedRegex  = regexp.MustCompile(`^(?i:y|yes|n|no|true|false|on|off|null)$`)
+	k8sNameInvalidRune = regexp.MustCompile(`[^a-z0-9.-]+`)
+	// identifierRegex matches the names shells and systemd accept for variables
+	identifierRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// variable is a key and its value in the order of the document
+type variable struct {
+	key   string
+	value string
+}
+
+// Export converts the document to format, name is the nickname of the file and
+// is used where the format needs a name, such as the Kubernetes Secret
+func Export(doc *Document, format, name string) ([]byte, error) {
+	variables, err := orderedVariables(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case FormatJSON:
+		return exportJSON(variables)
+	case FormatYAML:
+		return exportYAML(variables)
+	case FormatShell:
+		return exportShell(variables)
+	case FormatDockerEnv:
+		return exportDockerEnv(variables)
+	case FormatK8sSecret:
+		return exportK8sSecret(variables, name)
+	case FormatSystemd:
+		return exportSystemd(variables)
+	case FormatGitHubActions:
+		return exportGitHubActions(variables)
+	default:
+		return nil, fmt.Errorf("unknown format %s, use one of: %s", format, strings.Join(ExportFormats, ", "))
+	}
+}
+
+func orderedVariables(doc *Document) ([]variable, error) {
+	values, err := doc.Values()
+	if err != nil {
+		return nil, err
+	}
+
+	var variables []variable
+	for _, key := range doc.Keys() {
+		variables = append(variables, variable{key: key, value: values[key]})
+	}
+
+	return variables, nil
+}
+
+// jsonString encodes value as a JSON string, which is also a valid YAML double-quoted string
+func jsonString(value string) (string, error) {
+	var buffer bytes.Buffer
+
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(buffer.String(), "\n"), nil
+}
+
+func exportJSON(variables []variable) ([]byte, error) {
+	var buffer bytes.Buffer
+	buffer.WriteString("{")
+
+	// Write the object by hand to keep the order of the document
+	for index, current := range variables {
+		key, err := jsonString(current.key)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := jsonString(current.value)
+		if err != nil {
+			return nil, err
+		}
+
+		if index > 0 {
+			buffer.WriteString(",")
+		}
+		fmt.Fprintf(&buffer, "\n  %s: %s", key, value)
+	}
+
+	if len(variables) > 0 {
+		buffer.WriteString("\n")
+	}
+	buffer.WriteString("}\n")
+
+	return buffer.Bytes(), nil
+}
+
+func exportYAML(variables []variable) ([]byte, error) {
+	var buffer bytes.Buffer
+
+	for _, current := range variables {
+		value, err := jsonString(current.value)
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := yamlKey(current.key)
+		if err != nil {
+			return nil, err
+		}
+
+		fmt.Fprintf(&buffer, "%s: %s\n", key, value)
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// yamlKey quotes the keys YAML would otherwise read as booleans, nulls or numbers
+func yamlKey(key string) (string, error) {
+	if yamlPlainKeyRegex.MatchString(key) && !yamlReservedRegex.MatchString(key) {
+		return key, nil
+	}
+
+	return jsonString(key)
+}
+
+// shellQuote wraps value in single quotes, the only character to take care of is the quote itself
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+func exportShell(variables []variable) ([]byte, error) {
+	var buffer bytes.Buffer
+
+	for _, current := range variables {
+		if err := checkIdentifier(current.key, FormatShell); err != nil {
+			return nil, err
+		}
+
+		fmt.Fprintf(&buffer, "export %s=%s\n", current.key, shellQuote(current.value))
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// checkIdentifier rejects the keys format can't name a variable with, such as
+// dotted keys, instead of writing a file that fails where it is loaded
+func checkIdentifier(key, format string) error {
+	if !identifierRegex.MatchString(key) {
+		return fmt.Errorf("%s isn't a valid variable name for the %s format, rename it with letters, digits and _ only", key, format)
+	}
+
+	return nil
+}
+
+// exportDockerEnv writes the --env-file format, which takes values literally
+// and has no way to quote or escape them
+func exportDockerEnv(variables []variable) ([]byte, error) {
+	var buffer bytes.Buffer
+
+	for _, current := range variables {
+		if strings.ContainsAny(current.value, "\n\r") {
+			return nil, fmt.Errorf("%s has a multi-line value, which docker env files can't hold", current.key)
+		}
+
+		fmt.Fprintf(&buffer, "%s=%s\n", current.key, current.value)
+	}
+
+	return buffer.Bytes(), nil
+}
+
+func exportK8sSecret(variables []variable, name string) ([]byte, error) {
+	var buffer bytes.Buffer
+
+	buffer.WriteString("apiVersion: v1\n")
+	buffer.WriteString("kind: Secret\n")
+	buffer.WriteString("metadata:\n")
+	fmt.Fprintf(&buffer, "  name: %s\n", K8sSecretName(name))
+	buffer.WriteString("type: Opaque\n")
+
+	if len(variables) == 0 {
+		buffer.WriteString("data: {}\n")
+		return buffer.Bytes(), nil
+	}
+
+	buffer.WriteString("data:\n")
+	for _, current := range variables {
+		key, err := yamlKey(current.key)
+		if err != nil {
+			return nil, err
+		}
+
+		fmt.Fprintf(&buffer, "  %s: %s\n", key, base64.StdEncoding.EncodeToString([]byte(current.value)))
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// K8sSecretName turns a nickname into a valid Kubernetes object name
+func K8sSecretName(name string) string {
+	secretName := k8sNameInvalidRune.ReplaceAllString(strings.ToLower(name), "-")
+	secretName = strings.Trim(secretName, ".-")
+
+	if len(secretName) > 253 {
+		secretName = strings.Trim(secretName[:253], ".-")
+	}
+
+	if secretName == "" {
+		return "denv-secret"
+	}
+
+	return secretName
+}
+
+// exportSystemd writes an EnvironmentFile, double quotes keep spaces and new
+// lines while the backslash escapes the quote, itself, the backtick and the dollar
+func exportSystemd(variables []variable) ([]byte, error) {
+	var buffer bytes.Buffer
+
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "`", "\\`", `$`, `\$`)
+
+	for _, current := range variables {
+		if err := checkIdentifier(current.key, FormatSystemd); err != nil {
+			return nil, err
+		}
+
+		value := current.value
+		if !plainValueRegex.MatchString(value) {
+			value = `"` + replacer.Replace(value) + `"`
+		}
+
+		fmt.Fprintf(&buffer, "%s=%s\n", current.key, value)
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// exportGitHubActions writes the $GITHUB_ENV format, multi-line values use a
+// heredoc with a random delimiter so no value can end it early
+func exportGitHubActions(variables []variable) ([]byte, error) {
+	var buffer bytes.Buffer
+
+	for _, current := range variables {
+		if !strings.ContainsAny(current.value, "\n\r") {
+			fmt.Fprintf(&buffer, "%s=%s\n", current.key, current.value)
+			continue
+		}
+
+		random := make([]byte, 16)
+		if _, err := rand.Read(random); err != nil {
+			return nil, err
+		}
+
+		delimiter := "ghadelimiter_" + hex.EncodeToString(random)
+		fmt.Fprintf(&buffer, "%s<<%s\n%s\n%s\n", current.key, delimiter, current.value, delimiter)
+	}
+
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,162 @@
+package dotenv
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// exportJSONSource imports source, a JSON object, and exports it to format
+func exportJSONSource(t *testing.T, source, format string) (string, error) {
+	t.Helper()
+
+	doc, err := Import([]byte(source), FormatJSON, nil)
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+
+	content, err := Export(doc, format, "App Secrets.env")
+	return string(content), err
+}
+
+func TestExport(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		source string
+		want   string
+	}{
+		{
+			name:   "json keeps the order",
+			format: FormatJSON,
+			source: `{"Z": "last", "A": "<tag> & \"quotes\""}`,
+			want:   "{\n  \"Z\": \"last\",\n  \"A\": \"<tag> & \\\"quotes\\\"\"\n}\n",
+		},
+		{
+			name:   "empty json",
+			format: FormatJSON,
+			source: `{}`,
+			want:   "{}\n",
+		},
+		{
+			name:   "yaml quotes reserved and numeric keys",
+			format: FormatYAML,
+			source: `{"PLAIN": "1", "yes": "a", "Off": "b", "null": "c", "1_NUM": "d", "dotted.key": "e", "MULTI": "x\ny"}`,
+			want:   "PLAIN: \"1\"\n\"yes\": \"a\"\n\"Off\": \"b\"\n\"null\": \"c\"\n\"1_NUM\": \"d\"\ndotted.key: \"e\"\nMULTI: \"x\\ny\"\n",
+		},
+		{
+			name:   "shell quotes",
+			format: FormatShell,
+			source: `{"A": "plain", "B": "it's $HOME", "C": "x\ny", "EMPTY": ""}`,
+			want:   "export A='plain'\nexport B='it'\\''s $HOME'\nexport C='x\ny'\nexport EMPTY=''\n",
+		},
+		{
+			name:   "docker env takes values literally",
+			format: FormatDockerEnv,
+			source: `{"A": "with space", "B": "\"quoted\" # not a comment"}`,
+			want:   "A=with space\nB=\"quoted\" # not a comment\n",
+		},
+		{
+			name:   "systemd escapes",
+			format: FormatSystemd,
+			source: "{\"PLAIN\": \"a/b:c@d\", \"DOLLAR\": \"$HOME\", \"TICK\": \"`cmd`\", \"QUOTE\": \"say \\\"hi\\\" \\\\ back\", \"LINES\": \"x\\ny\"}",
+			want:   "PLAIN=a/b:c@d\nDOLLAR=\"\\$HOME\"\nTICK=\"\\`cmd\\`\"\nQUOTE=\"say \\\"hi\\\" \\\\ back\"\nLINES=\"x\ny\"\n",
+		},
+		{
+			name:   "k8s secret is base64 encoded",
+			format: FormatK8sSecret,
+			source: `{"TOKEN": "line1\nline2", "yes": "y"}`,
+			want: "apiVersion: v1\nkind: Secret\nmetadata:\n  name: app-secrets.env\ntype: Opaque\ndata:\n" +
+				"  TOKEN: bGluZTEKbGluZTI=\n  \"yes\": eQ==\n",
+		},
+		{
+			name:   "empty k8s secret",
+			format: FormatK8sSecret,
+			source: `{}`,
+			want:   "apiVersion: v1\nkind: Secret\nmetadata:\n  name: app-secrets.env\ntype: Opaque\ndata: {}\n",
+		},
+		{
+			name:   "github actions single lines",
+			format: FormatGitHubActions,
+			source: `{"A": "1", "B": "two words"}`,
+			want:   "A=1\nB=two words\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := exportJSONSource(t, tt.source, tt.format)
+			if err != nil {
+				t.Fatalf("Export: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Export =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExportGitHubActionsHeredoc(t *testing.T) {
+	got, err := exportJSONSource(t, `{"CERT": "first\nsecond", "AFTER": "1"}`, FormatGitHubActions)
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	heredoc := regexp.MustCompile(`^CERT<<(ghadelimiter_[0-9a-f]{32})\nfirst\nsecond\n(ghadelimiter_[0-9a-f]{32})\nAFTER=1\n$`)
+	match := heredoc.FindStringSubmatch(got)
+	if match == nil {
+		t.Fatalf("Export = %q, want a heredoc", got)
+	}
+	if match[1] != match[2] {
+		t.Errorf("heredoc opened with %s and closed with %s", match[1], match[2])
+	}
+
+	// Every value gets its own delimiter
+	again, err := exportJSONSource(t, `{"CERT": "first\nsecond"}`, FormatGitHubActions)
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if strings.Contains(again, match[1]) {
+		t.Error("the delimiter was reused by another export")
+	}
+}
+
+func TestExportErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		source string
+	}{
+		{"unknown format", "toml", `{"A": "1"}`},
+		{"docker env multi-line value", FormatDockerEnv, `{"A": "x\ny"}`},
+		{"docker env carriage return", FormatDockerEnv, `{"A": "x\ry"}`},
+		{"shell dotted key", FormatShell, `{"db.host": "x"}`},
+		{"shell key starting with a digit", FormatShell, `{"1_NUM": "x"}`},
+		{"systemd dotted key", FormatSystemd, `{"db.host": "x"}`},
+		{"systemd key starting with a digit", FormatSystemd, `{"1_NUM": "x"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := exportJSONSource(t, tt.source, tt.format); err == nil {
+				t.Errorf("Export = %q, want an error", got)
+			}
+		})
+	}
+}
+
+func TestK8sSecretName(t *testing.T) {
+	tests := map[string]string{
+		"prod.env":               "prod.env",
+		"Team/App Secrets.env":   "team-app-secrets.env",
+		"--.weird_name.--":       "weird-name",
+		"***":                    "denv-secret",
+		strings.Repeat("a", 300): strings.Repeat("a", 253),
+	}
+
+	for name, want := range tests {
+		if got := K8sSecretName(name); got != want {
+			t.Errorf("K8sSecretName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package dotenv
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ImportFormats lists every format Import understands
+var ImportFormats = []string{
+	FormatJSON,
+	FormatYAML,
+	FormatK8sSecret,
+	FormatDockerEnv,
+}
+
+var keyInvalidRune = regexp.MustCompile(`[^A-Za-z0-9_.]`)
+
+// Import parses content written in format into a dotenv document, keeping the
+// order of the source. Nested objects are flattened joining their keys with _.
+// lookupEnv fills the bare names of docker env files.
+func Import(content []byte, format string, lookupEnv func(string) (string, bool)) (*Document, error) {
+	var variables []variable
+	var err error
+
+	switch format {
+	case FormatJSON:
+		variables, err = importJSON(content)
+	case FormatYAML:
+		variables, err = importYAML(content)
+	case FormatK8sSecret:
+		variables, err = importK8sSecret(content)
+	case FormatDockerEnv:
+		variables, err = importDockerEnv(content, lookupEnv)
+	default:
+		return nil, fmt.Errorf("unknown format %s, use one of: %s", format, strings.Join(ImportFormats, ", "))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &Document{trailingNewline: true}
+	for _, current := range variables {
+		if err := doc.Set(normalizeKey(current.key), current.value); err != nil {
+			return nil, err
+		}
+	}
+
+	return doc, nil
+}
+
+// normalizeKey replaces the characters dotenv doesn't allow in names, such as
+// the dashes of Kubernetes keys
+func normalizeKey(key string) string {
+	return keyInvalidRune.ReplaceAllString(key, "_")
+}
+
+func importJSON(content []byte) ([]variable, error) {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON: %v", err)
+	}
+
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return nil, errors.New("the JSON document must be an object")
+	}
+
+	return jsonObject(decoder, "")
+}
+
+// jsonObject reads the members of an object whose opening brace was already read
+func jsonObject(decoder *json.Decoder, prefix string) ([]variable, error) {
+	var variables []variable
+
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, fmt.Errorf("invalid JSON: %v", err)
+		}
+
+		key := prefix + token.(string)
+
+		token, err = decoder.Token()
+		if err != nil {
+			return nil, fmt.Errorf("invalid JSON: %v", err)
+		}
+
+		switch value := token.(type) {
+		case json.Delim:
+			if value != '{' {
+				return nil, fmt.Errorf("%s is an array, which can't be stored in a dotenv file", key)
+			}
+
+			nested, err := jsonObject(decoder, key+"_")
+			if err != nil {
+				return nil, err
+			}
+			variables = append(variables, nested...)
+		case nil:
+			variables = append(variables, variable{key: key, value: ""})
+		default:
+			variables = append(variables, variable{key: key, value: fmt.Sprint(value)})
+		}
+	}
+
+	// Consume the closing brace
+	if _, err := decoder.Token(); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %v", err)
+	}
+
+	return variables, nil
+}
+
+func importYAML(content []byte) ([]variable, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(content, &root); err != nil {
+		return nil, fmt.Errorf("invalid YAML: %v", err)
+	}
+
+	if len(root.Content) == 0 {
+		return nil, nil
+	}
+
+	return yamlMapping(root.Content[0], "")
+}
+
+func yamlMapping(node *yaml.Node, prefix string) ([]variable, error) {
+	if node.Kind != yaml.MappingNode {
+		return nil, errors.New("the YAML document must be a mapping")
+	}
+
+	var variables []variable
+
+	// Mapping nodes alternate keys and values
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := prefix + node.Content[i].Value
+		value := node.Content[i+1]
+
+		if value.Kind == yaml.AliasNode {
+			value = value.Alias
+		}
+
+		switch value.Kind {
+		case yaml.MappingNode:
+			nested, err := yamlMapping(value, key+"_")
+			if err != nil {
+				return nil, err
+			}
+			variables = append(variables, nested...)
+		case yaml.ScalarNode:
+			scalar := value.Value
+			if value.Tag == "!!null" {
+				scalar = ""
+			}
+			variables = append(variables, variable{key: key, value: scalar})
+		default:
+			return nil, fmt.Errorf("%s is a list, which can't be stored in a dotenv file", key)
+		}
+	}
+
+	return variables, nil
+}
+
+// k8sSecret holds the fields of a manifest document that matter to the import
+type k8sSecret struct {
+	Kind       string    `yaml:"kind"`
+	Data       yaml.Node `yaml:"data"`
+	StringData yaml.Node `yaml:"stringData"`
+}
+
+// importK8sSecret reads the first Secret of a manifest, stringData wins over
+// data like it does when Kubernetes applies it
+func importK8sSecret(content []byte) ([]variable, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+
+	for {
+		var secret k8sSecret
+		err := decoder.Decode(&secret)
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("no Secret found in the manifest")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid YAML: %v", err)
+		}
+
+		if secret.Kind != "Secret" {
+			continue
+		}
+
+		var variables []variable
+
+		if secret.Data.Kind == yaml.MappingNode {
+			encoded, err := yamlMapping(&secret.Data, "")
+			if err != nil {
+				return nil, err
+			}
+
+			for _, current := range encoded {
+				decoded, err := base64.StdEncoding.DecodeString(current.value)
+				if err != nil {
+					return nil, fmt.Errorf("%s is not valid base64: %v", current.key, err)
+				}
+				variables = append(variables, variable{key: current.key, value: string(decoded)})
+			}
+		}
+
+		if secret.StringData.Kind == yaml.MappingNode {
+			plain, err := yamlMapping(&secret.StringData, "")
+			if err != nil {
+				return nil, err
+			}
+			variables = append(variables, plain...)
+		}
+
+		return variables, nil
+	}
+}
+
+// importDockerEnv reads the --env-file format, values are taken literally and a
+// bare name takes its value from lookupEnv like docker does with its environment
+func importDockerEnv(content []byte, lookupEnv func(string) (string, bool)) ([]variable, error) {
+	var variables []variable
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimLeft(scanner.Text(), " \t")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, found := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !found {
+			envValue, exists := lookupEnv(key)
+			if !exists {
+				continue
+			}
+			value = envValue
+		}
+
+		variables = append(variables, variable{key: key, value: value})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return variables, nil
+}
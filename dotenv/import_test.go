@@ -0,0 +1,188 @@
+package dotenv
+
+import (
+	"reflect"
+	"testing"
+)
+
+// lookupMap is a lookupEnv reading from a fixed environment
+func lookupMap(env map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, exists := env[key]
+		return value, exists
+	}
+}
+
+func TestImport(t *testing.T) {
+	// Only the environment handed to Import is read, not the process one
+	t.Setenv("DENV_IMPORT_PROCESS", "leaked")
+	lookupEnv := lookupMap(map[string]string{"DENV_IMPORT_TEST": "from the environment"})
+
+	tests := []struct {
+		name       string
+		format     string
+		content    string
+		wantKeys   []string
+		wantValues map[string]string
+	}{
+		{
+			name:       "json keeps the order of the source",
+			format:     FormatJSON,
+			content:    `{"Z": "last", "A": "first", "PORT": 8080, "RATIO": 0.5, "DEBUG": true, "EMPTY": null}`,
+			wantKeys:   []string{"Z", "A", "PORT", "RATIO", "DEBUG", "EMPTY"},
+			wantValues: map[string]string{"Z": "last", "A": "first", "PORT": "8080", "RATIO": "0.5", "DEBUG": "true", "EMPTY": ""},
+		},
+		{
+			name:       "json nested objects are flattened",
+			format:     FormatJSON,
+			content:    `{"db": {"host": "localhost", "auth": {"user": "admin"}}, "big": 12345678901234567890}`,
+			wantKeys:   []string{"db_host", "db_auth_user", "big"},
+			wantValues: map[string]string{"db_host": "localhost", "db_auth_user": "admin", "big": "12345678901234567890"},
+		},
+		{
+			name:       "json keys are normalized",
+			format:     FormatJSON,
+			content:    `{"api-key": "x", "with space": "y"}`,
+			wantKeys:   []string{"api_key", "with_space"},
+			wantValues: map[string]string{"api_key": "x", "with_space": "y"},
+		},
+		{
+			name:     "yaml",
+			format:   FormatYAML,
+			content:  "B: 1\nA: \"two words\"\nDB:\n  HOST: localhost\nMULTI: |\n  first\n  second\nEMPTY:\n",
+			wantKeys: []string{"B", "A", "DB_HOST", "MULTI", "EMPTY"},
+			wantValues: map[string]string{
+				"B": "1", "A": "two words", "DB_HOST": "localhost", "MULTI": "first\nsecond\n", "EMPTY": "",
+			},
+		},
+		{
+			name:       "yaml anchors",
+			format:     FormatYAML,
+			content:    "base: &base\n  HOST: localhost\ncopy: *base\n",
+			wantKeys:   []string{"base_HOST", "copy_HOST"},
+			wantValues: map[string]string{"base_HOST": "localhost", "copy_HOST": "localhost"},
+		},
+		{
+			name:       "empty yaml",
+			format:     FormatYAML,
+			wantValues: map[string]string{},
+		},
+		{
+			name:   "k8s secret data is decoded",
+			format: FormatK8sSecret,
+			content: `apiVersion: v1
+kind: Secret
+metadata:
+  name: app
+data:
+  db-password: c2VjcmV0
+  TOKEN: bGluZTEKbGluZTI=
+`,
+			wantKeys:   []string{"db_password", "TOKEN"},
+			wantValues: map[string]string{"db_password": "secret", "TOKEN": "line1\nline2"},
+		},
+		{
+			name:   "k8s stringData wins over data",
+			format: FormatK8sSecret,
+			content: `kind: ConfigMap
+data:
+  IGNORED: "yes"
+---
+kind: Secret
+data:
+  A: ZW5jb2RlZA==
+stringData:
+  A: plain
+  B: other
+`,
+			wantKeys:   []string{"A", "B"},
+			wantValues: map[string]string{"A": "plain", "B": "other"},
+		},
+		{
+			name:   "docker env file",
+			format: FormatDockerEnv,
+			content: `# comment
+  A=1
+B="quotes are kept"
+C=value # not a comment
+DENV_IMPORT_TEST
+DENV_IMPORT_MISSING
+DENV_IMPORT_PROCESS
+EMPTY=
+`,
+			wantKeys: []string{"A", "B", "C", "DENV_IMPORT_TEST", "EMPTY"},
+			wantValues: map[string]string{
+				"A":                "1",
+				"B":                `"quotes are kept"`,
+				"C":                "value # not a comment",
+				"DENV_IMPORT_TEST": "from the environment",
+				"EMPTY":            "",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := Import([]byte(tt.content), tt.format, lookupEnv)
+			if err != nil {
+				t.Fatalf("Import: %v", err)
+			}
+
+			if keys := doc.Keys(); !reflect.DeepEqual(keys, tt.wantKeys) {
+				t.Errorf("Keys = %v, want %v", keys, tt.wantKeys)
+			}
+
+			values, err := doc.Values()
+			if err != nil {
+				t.Fatalf("Values: %v", err)
+			}
+			if !reflect.DeepEqual(values, tt.wantValues) {
+				t.Errorf("Values = %q, want %q", values, tt.wantValues)
+			}
+		})
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		content string
+	}{
+		{"unknown format", "toml", "A = 1"},
+		{"invalid json", FormatJSON, `{"A": `},
+		{"json array", FormatJSON, `["A"]`},
+		{"json nested array", FormatJSON, `{"A": [1, 2]}`},
+		{"invalid yaml", FormatYAML, "A: [unclosed"},
+		{"yaml list", FormatYAML, "- A\n- B\n"},
+		{"yaml nested list", FormatYAML, "A:\n  - 1\n"},
+		{"no secret in the manifest", FormatK8sSecret, "kind: ConfigMap\ndata:\n  A: b\n"},
+		{"invalid base64", FormatK8sSecret, "kind: Secret\ndata:\n  A: not base64!\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Import([]byte(tt.content), tt.format, lookupMap(nil)); err == nil {
+				t.Error("Import succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestNormalizeKey(t *testing.T) {
+	tests := map[string]string{
+		"PLAIN_KEY":    "PLAIN_KEY",
+		"dotted.key":   "dotted.key",
+		"dashed-key":   "dashed_key",
+		"with space":   "with_space",
+		"a/b:c":        "a_b_c",
+		"accentué":     "accentu_",
+		"1_STARTS_NUM": "1_STARTS_NUM",
+	}
+
+	for key, want := range tests {
+		if got := normalizeKey(key); got != want {
+			t.Errorf("normalizeKey(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
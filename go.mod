@@ -3,7 +3,16 @@ module github.com/robertokbr/denv
 go 1.19
 
 require (
-	github.com/aws/aws-sdk-go v1.50.23 // indirect
-	github.com/jmespath/go-jmespath v0.4.0 // indirect
+	filippo.io/age v1.2.1
+	github.com/aws/aws-sdk-go v1.50.23
 	github.com/joho/godotenv v1.5.1
+	golang.org/x/crypto v0.24.0
+	golang.org/x/sys v0.21.0
+	golang.org/x/term v0.21.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	filippo.io/edwards25519 v1.1.0 // indirect
+	github.com/jmespath/go-jmespath v0.4.0 // indirect
 )